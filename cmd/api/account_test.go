@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"rental-saas/internal/auth"
+	"rental-saas/internal/mailer/mailtest"
+	"rental-saas/internal/middleware"
+)
+
+var tokenLink = regexp.MustCompile(`\?token=(\S+)`)
+
+func useMailServer(t *testing.T) *mailtest.Server {
+	srv := mailtest.NewServer(t)
+	t.Setenv("SMTP_HOST", srv.Host)
+	t.Setenv("SMTP_PORT", srv.Port)
+	t.Setenv("SMTP_USERNAME", "")
+	t.Setenv("SMTP_TLS", "")
+	t.Setenv("MAIL_TRANSPORT", "smtp")
+	t.Setenv("SMTP_FROM", "noreply@example.com")
+	t.Setenv("APP_BASE_URL", "http://app.test")
+	return srv
+}
+
+func postJSON(t *testing.T, h http.HandlerFunc, tenantID string, body interface{}, ctxValues map[interface{}]interface{}) *httptest.ResponseRecorder {
+	raw, _ := json.Marshal(body)
+	req := httptest.NewRequest("POST", "/", bytes.NewBuffer(raw))
+	ctx := context.WithValue(req.Context(), middleware.TenantKey, tenantID)
+	for k, v := range ctxValues {
+		ctx = context.WithValue(ctx, k, v)
+	}
+	w := httptest.NewRecorder()
+	h(w, req.WithContext(ctx))
+	return w
+}
+
+func mailedToken(t *testing.T, srv *mailtest.Server, n int) string {
+	msgs := srv.Wait(n, 2*time.Second)
+	if len(msgs) < n {
+		t.Fatalf("expected %d emails, got %d", n, len(msgs))
+	}
+	m := tokenLink.FindStringSubmatch(msgs[n-1].Body())
+	if m == nil {
+		t.Fatalf("no token link in email: %q", msgs[n-1].Data)
+	}
+	token, err := url.QueryUnescape(m[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestInviteAcceptAndResetPassword(t *testing.T) {
+	tenantID := "test_tenant"
+	srv := useMailServer(t)
+	email := fmt.Sprintf("invitee-%d@example.com", time.Now().UnixNano())
+	owner := map[interface{}]interface{}{auth.RoleKey: auth.RoleOwner}
+
+	// Invite
+	w := postJSON(t, auth.InviteUserHandler, tenantID, auth.InviteRequest{Email: email, Role: "agent"}, owner)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("invite: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	inviteToken := mailedToken(t, srv, 1)
+
+	// Pending invitee cannot log in yet
+	w = postJSON(t, auth.LoginHandler, tenantID, auth.LoginRequest{Email: email, Password: ""}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("login before accept: expected 401, got %d", w.Code)
+	}
+
+	// Accept sets the password and signs in; the token is single-use
+	w = postJSON(t, auth.AcceptInviteHandler, tenantID, auth.SetPasswordRequest{Token: inviteToken, Password: "first-password"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("accept: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	w = postJSON(t, auth.AcceptInviteHandler, tenantID, auth.SetPasswordRequest{Token: inviteToken, Password: "other-password"}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("second accept: expected 400, got %d", w.Code)
+	}
+
+	// Inviting an active user is a conflict
+	w = postJSON(t, auth.InviteUserHandler, tenantID, auth.InviteRequest{Email: email, Role: "agent"}, owner)
+	if w.Code != http.StatusConflict {
+		t.Errorf("re-invite active user: expected 409, got %d", w.Code)
+	}
+
+	// Forgot password is silent for unknown addresses
+	w = postJSON(t, auth.ForgotPasswordHandler, tenantID, auth.ForgotPasswordRequest{Email: "nobody@example.com"}, nil)
+	if w.Code != http.StatusAccepted {
+		t.Errorf("forgot unknown: expected 202, got %d", w.Code)
+	}
+
+	w = postJSON(t, auth.ForgotPasswordHandler, tenantID, auth.ForgotPasswordRequest{Email: email}, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("forgot: expected 202, got %d", w.Code)
+	}
+	resetToken := mailedToken(t, srv, 2)
+
+	w = postJSON(t, auth.ResetPasswordHandler, tenantID, auth.SetPasswordRequest{Token: resetToken, Password: "short"}, nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("weak password: expected 400, got %d", w.Code)
+	}
+	w = postJSON(t, auth.ResetPasswordHandler, tenantID, auth.SetPasswordRequest{Token: resetToken, Password: "second-password"}, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("reset: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = postJSON(t, auth.LoginHandler, tenantID, auth.LoginRequest{Email: email, Password: "first-password"}, nil)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("login with old password: expected 401, got %d", w.Code)
+	}
+	w = postJSON(t, auth.LoginHandler, tenantID, auth.LoginRequest{Email: email, Password: "second-password"}, nil)
+	if w.Code != http.StatusOK {
+		t.Errorf("login with new password: expected 200, got %d", w.Code)
+	}
+}
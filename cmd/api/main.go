@@ -14,11 +14,13 @@ import (
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
 	"rental-saas/internal/auth"
+	"rental-saas/internal/compliance"
 	"rental-saas/internal/database"
 	"rental-saas/internal/handlers"
 	"rental-saas/internal/middleware"
 	"rental-saas/internal/repository"
 	"rental-saas/internal/storage"
+	"rental-saas/internal/webhooks"
 )
 
 func main() {
@@ -38,6 +40,8 @@ func main() {
 	// Initialize Repositories and Handlers
 	carRepo := repository.NewCarRepository(database.DB)
 	carHandler := handlers.NewCarHandler(carRepo, minioClient)
+	inspectionHandler := handlers.NewInspectionHandler(minioClient)
+	documentHandler := handlers.NewDocumentHandler(minioClient)
 
 	// Widget Handler
 	widgetHandler := handlers.NewWidgetHandler()
@@ -58,15 +62,32 @@ func main() {
 		r.Use(httprate.LimitByIP(10, 1*time.Minute))
 
 		r.Get("/api/public/cars", widgetHandler.GetPublicCars)
+		r.Get("/api/public/quote", widgetHandler.GetPublicQuote)
+		r.Get("/api/public/branches", widgetHandler.GetPublicBranches)
+		r.Get("/api/public/classes", widgetHandler.GetPublicClasses)
+		r.Get("/api/public/extras", widgetHandler.GetPublicExtras)
 		r.Post("/api/public/book", widgetHandler.PublicBook)
 	})
 
+	// --- Platform API Group (Operators, not tenant-scoped) ---
+	r.Route("/api/platform", func(r chi.Router) {
+		r.Use(httprate.LimitByIP(20, 1*time.Minute))
+
+		r.Post("/login", auth.PlatformLoginHandler)
+
+		r.Group(func(r chi.Router) {
+			r.Use(auth.PlatformAdminMiddleware)
+
+			r.Post("/tenants", handlers.NewPlatformHandler().CreateTenant)
+		})
+	})
+
 	// --- Tenant / Protected API Group ---
 	r.Group(func(r chi.Router) {
 		// Restricted CORS
 		r.Use(cors.Handler(cors.Options{
 			AllowedOrigins:   []string{"http://localhost:5173", "http://localhost:3000", "http://admin.localhost:5173", os.Getenv("FRONTEND_URL")},
-			AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 			AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 			ExposedHeaders:   []string{"Link"},
 			AllowCredentials: true,
@@ -98,29 +119,146 @@ func main() {
 		// Routes
 		r.Route("/api", func(r chi.Router) {
 			r.Post("/auth/login", auth.LoginHandler)
+			r.Post("/auth/refresh", auth.RefreshHandler)
+			r.Post("/auth/invite/accept", auth.AcceptInviteHandler)
+			r.Post("/auth/password/forgot", auth.ForgotPasswordHandler)
+			r.Post("/auth/password/reset", auth.ResetPasswordHandler)
 			
 			// Protected Routes
 			r.Group(func(r chi.Router) {
 				r.Use(auth.AuthMiddleware)
 				// TenantMiddleware is already applied in the parent group
 				
-				r.Get("/cars", carHandler.ListCars)
-				r.Post("/cars", carHandler.CreateCar)
-				r.Put("/cars/{id}", carHandler.UpdateCar)
-
-				r.Post("/payments/intent", handlers.NewPaymentHandler().CreatePaymentIntent)
-				r.Post("/bookings", handlers.NewBookingHandler().CreateBooking)
-				r.Post("/bookings/{id}/return", handlers.NewBookingHandler().ReturnCar)
-				r.Get("/dashboard/stats", handlers.NewDashboardHandler().GetDashboardStats)
-				r.Get("/bookings/{id}/invoice", handlers.NewInvoiceHandler().GenerateInvoice)
-				r.Post("/settings/webhooks", handlers.NewSettingsHandler().RegisterWebhook)
-				r.Get("/settings/webhooks", handlers.NewSettingsHandler().ListWebhooks)
+				r.Post("/auth/logout", auth.LogoutHandler)
+				r.Post("/auth/password/change", auth.ChangePasswordHandler)
+
+				// Users may manage their own sessions; other users' need PermManageUsers (checked in the handler)
+				r.Get("/users/{id}/sessions", auth.ListSessionsHandler)
+				r.Post("/users/{id}/sessions/revoke", auth.RevokeAllSessionsHandler)
+				r.Delete("/users/{id}/sessions/{sessionID}", auth.RevokeSessionHandler)
+
+				// Permissions per role are defined in internal/auth/rbac.go
+				view := r.With(auth.Require(auth.PermView))
+				view.Get("/cars", carHandler.ListCars)
+				view.Get("/availability", handlers.NewBookingHandler().GetAvailability)
+				view.Get("/customers", handlers.NewCustomerHandler().ListCustomers)
+				view.Get("/customers/{id}", handlers.NewCustomerHandler().GetCustomer)
+				view.Get("/customers/{id}/bookings", handlers.NewCustomerHandler().GetCustomerBookings)
+				view.Get("/dashboard/stats", handlers.NewDashboardHandler().GetDashboardStats)
+				view.Get("/bookings/{id}/invoice", handlers.NewInvoiceHandler().GenerateInvoice)
+				view.Get("/bookings/{id}/refunds", handlers.NewPaymentHandler().ListRefunds)
+				view.Get("/invoices", handlers.NewInvoiceHandler().ListInvoices)
+				view.Get("/invoices/settings", handlers.NewInvoiceHandler().GetSettings)
+				view.Get("/invoices/{id}", handlers.NewInvoiceHandler().GetInvoice)
+				view.Get("/invoices/{id}/pdf", handlers.NewInvoiceHandler().DownloadInvoice)
+				view.Get("/bookings/{id}/inspections", inspectionHandler.GetInspections)
+				view.Get("/bookings/{id}/damage-report", inspectionHandler.DamageReport)
+				view.Get("/pricing/quote", handlers.NewPricingHandler().GetQuote)
+				view.Get("/pricing/rules", handlers.NewPricingHandler().ListRules)
+				view.Get("/pricing/settings", handlers.NewPricingHandler().GetSettings)
+				view.Get("/pricing/tax", handlers.NewPricingHandler().GetTax)
+				view.Get("/maintenance/plans", handlers.NewMaintenanceHandler().ListPlans)
+				view.Get("/maintenance/due", handlers.NewMaintenanceHandler().ListDue)
+				view.Get("/maintenance/work-orders", handlers.NewMaintenanceHandler().ListWorkOrders)
+				view.Get("/cars/{id}/documents", documentHandler.ListDocuments)
+				view.Get("/documents/expiring", documentHandler.ListExpiring)
+				view.Get("/documents/{id}/file", documentHandler.DownloadDocument)
+				view.Get("/branches", handlers.NewBranchHandler().ListBranches)
+				view.Get("/one-way-fees", handlers.NewBranchHandler().ListFees)
+				view.Get("/classes", handlers.NewClassHandler().ListClasses)
+				view.Get("/classes/availability", handlers.NewClassHandler().GetClassAvailability)
+				view.Get("/extras", handlers.NewExtraHandler().ListExtras)
+
+				// daily_rate_cents additionally requires PermManagePricing (checked in the handler)
+				fleet := r.With(auth.Require(auth.PermManageFleet))
+				fleet.Post("/cars", carHandler.CreateCar)
+				fleet.Put("/cars/{id}", carHandler.UpdateCar)
+				fleet.Post("/maintenance/plans", handlers.NewMaintenanceHandler().CreatePlan)
+				fleet.Put("/maintenance/plans/{id}", handlers.NewMaintenanceHandler().UpdatePlan)
+				fleet.Delete("/maintenance/plans/{id}", handlers.NewMaintenanceHandler().DeletePlan)
+				fleet.Post("/maintenance/work-orders", handlers.NewMaintenanceHandler().CreateWorkOrder)
+				fleet.Post("/maintenance/work-orders/{id}/start", handlers.NewMaintenanceHandler().StartWorkOrder)
+				fleet.Post("/maintenance/work-orders/{id}/close", handlers.NewMaintenanceHandler().CloseWorkOrder)
+				fleet.Post("/maintenance/work-orders/{id}/cancel", handlers.NewMaintenanceHandler().CancelWorkOrder)
+				fleet.Post("/cars/{id}/documents", documentHandler.UploadDocument)
+				fleet.Put("/documents/{id}", documentHandler.UpdateDocument)
+				fleet.Delete("/documents/{id}", documentHandler.DeleteDocument)
+				fleet.Post("/branches", handlers.NewBranchHandler().CreateBranch)
+				fleet.Put("/branches/{id}", handlers.NewBranchHandler().UpdateBranch)
+				fleet.Delete("/branches/{id}", handlers.NewBranchHandler().DeleteBranch)
+				fleet.Post("/classes", handlers.NewClassHandler().CreateClass)
+				fleet.Put("/classes/{id}", handlers.NewClassHandler().UpdateClass)
+				fleet.Delete("/classes/{id}", handlers.NewClassHandler().DeleteClass)
+
+				pricing := r.With(auth.Require(auth.PermManagePricing))
+				pricing.Post("/pricing/rules", handlers.NewPricingHandler().CreateRule)
+				pricing.Put("/pricing/rules/{id}", handlers.NewPricingHandler().UpdateRule)
+				pricing.Delete("/pricing/rules/{id}", handlers.NewPricingHandler().DeleteRule)
+				pricing.Put("/pricing/settings", handlers.NewPricingHandler().UpdateSettings)
+				pricing.Put("/pricing/tax", handlers.NewPricingHandler().UpdateTax)
+				pricing.Put("/invoices/settings", handlers.NewInvoiceHandler().UpdateSettings)
+				pricing.Put("/one-way-fees", handlers.NewBranchHandler().SetFee)
+				pricing.Delete("/one-way-fees/{id}", handlers.NewBranchHandler().DeleteFee)
+				pricing.Post("/extras", handlers.NewExtraHandler().CreateExtra)
+				pricing.Put("/extras/{id}", handlers.NewExtraHandler().UpdateExtra)
+				pricing.Delete("/extras/{id}", handlers.NewExtraHandler().DeleteExtra)
+
+				customers := r.With(auth.Require(auth.PermManageCustomers))
+				customers.Post("/customers", handlers.NewCustomerHandler().CreateCustomer)
+				customers.Put("/customers/{id}", handlers.NewCustomerHandler().UpdateCustomer)
+				customers.Delete("/customers/{id}", handlers.NewCustomerHandler().DeleteCustomer)
+				customers.Post("/customers/{id}/merge", handlers.NewCustomerHandler().MergeCustomers)
+
+				booking := r.With(auth.Require(auth.PermCreateBookings))
+				booking.Post("/bookings", handlers.NewBookingHandler().CreateBooking)
+				booking.Post("/payments/intent", handlers.NewPaymentHandler().CreatePaymentIntent)
+
+				handover := r.With(auth.Require(auth.PermHandover))
+				handover.Post("/bookings/{id}/pickup", handlers.NewBookingHandler().PickupBooking)
+				handover.Post("/bookings/{id}/return", handlers.NewBookingHandler().ReturnCar)
+				handover.Post("/bookings/{id}/inspections", inspectionHandler.CreateInspection)
+				handover.Post("/inspections/{id}/photos", inspectionHandler.UploadPhoto)
+
+				lifecycle := r.With(auth.Require(auth.PermManageBookings))
+				lifecycle.Post("/bookings/{id}/confirm", handlers.NewBookingHandler().ConfirmBooking)
+				lifecycle.Post("/bookings/{id}/cancel", handlers.NewBookingHandler().CancelBooking)
+				lifecycle.Post("/bookings/{id}/no-show", handlers.NewBookingHandler().MarkNoShow)
+				lifecycle.Post("/bookings/{id}/assign", handlers.NewBookingHandler().AssignCar)
+				lifecycle.Post("/bookings/{id}/invoices", handlers.NewInvoiceHandler().IssueInvoice)
+				lifecycle.Post("/bookings/{id}/refunds", handlers.NewPaymentHandler().CreateRefund)
+				lifecycle.Post("/invoices/{id}/credit-note", handlers.NewInvoiceHandler().CreateCreditNote)
+
+				users := r.With(auth.Require(auth.PermManageUsers))
+				users.Post("/users/invite", auth.InviteUserHandler)
+
+				webhooks := r.With(auth.Require(auth.PermManageWebhooks))
+				webhooks.Post("/settings/webhooks", handlers.NewSettingsHandler().RegisterWebhook)
+				webhooks.Get("/settings/webhooks", handlers.NewSettingsHandler().ListWebhooks)
+				webhooks.Get("/settings/webhooks/events", handlers.NewSettingsHandler().ListWebhookEvents)
+				webhooks.Patch("/settings/webhooks/{id}", handlers.NewSettingsHandler().UpdateWebhook)
+				webhooks.Delete("/settings/webhooks/{id}", handlers.NewSettingsHandler().DeleteWebhook)
+				webhooks.Post("/settings/webhooks/{id}/rotate-secret", handlers.NewSettingsHandler().RotateWebhookSecret)
+				webhooks.Post("/settings/webhooks/{id}/test", handlers.NewSettingsHandler().TestWebhook)
+				webhooks.Get("/settings/webhooks/{id}/deliveries", handlers.NewSettingsHandler().ListDeliveries)
+				webhooks.Get("/settings/webhooks/{id}/deliveries/{deliveryID}", handlers.NewSettingsHandler().GetDelivery)
+				webhooks.Post("/settings/webhooks/{id}/deliveries/{deliveryID}/redeliver", handlers.NewSettingsHandler().RedeliverDelivery)
 			})
 		})
 
 		r.Post("/api/webhooks/stripe", handlers.NewPaymentHandler().HandleStripeWebhook)
 	})
 
+	// Webhook outbox worker; WEBHOOK_WORKER=off leaves delivery to another process
+	workerCtx, stopWorker := context.WithCancel(context.Background())
+	defer stopWorker()
+	if os.Getenv("WEBHOOK_WORKER") != "off" {
+		go webhooks.NewWorker(database.DB).Run(workerCtx)
+	}
+	// Daily document expiry check; COMPLIANCE_CHECK=off leaves it to another process
+	if os.Getenv("COMPLIANCE_CHECK") != "off" {
+		go compliance.NewChecker(database.DB).Run(workerCtx)
+	}
+
 	// Start Server
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -144,6 +282,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	fmt.Println("Shutting down server...")
+	stopWorker()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
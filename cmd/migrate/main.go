@@ -0,0 +1,157 @@
+// Command migrate applies the embedded SQL migrations to the public schema and
+// to every tenant schema registered in public.tenants.
+//
+//	migrate up [-tenant SCHEMA] [-concurrency N] [-dry-run]
+//	migrate status
+//	migrate down -schema SCHEMA -to VERSION [-dry-run]
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/migrate"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "up":
+		up(os.Args[2:])
+	case "status":
+		status()
+	case "down":
+		down(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up [-tenant SCHEMA] [-concurrency N] [-dry-run]")
+	fmt.Fprintln(os.Stderr, "       migrate status")
+	fmt.Fprintln(os.Stderr, "       migrate down -schema SCHEMA -to VERSION [-dry-run]")
+}
+
+func newMigrator() *migrate.Migrator {
+	if err := database.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := migrate.New(database.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load migrations: %v\n", err)
+		os.Exit(1)
+	}
+	m.Logf = func(format string, args ...interface{}) {
+		fmt.Printf(format+"\n", args...)
+	}
+	return m
+}
+
+func up(args []string) {
+	fs := flag.NewFlagSet("up", flag.ExitOnError)
+	tenant := fs.String("tenant", "", "only migrate this tenant schema")
+	concurrency := fs.Int("concurrency", 4, "tenant schemas migrated in parallel")
+	dryRun := fs.Bool("dry-run", false, "apply inside a rolled back transaction")
+	fs.Parse(args)
+
+	m := newMigrator()
+	defer database.DB.Close()
+	m.Concurrency = *concurrency
+	m.DryRun = *dryRun
+	ctx := context.Background()
+
+	if *tenant != "" {
+		if _, err := m.MigrateTenant(ctx, *tenant); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", *tenant, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if _, err := m.MigratePublic(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "public: %v\n", err)
+		os.Exit(1)
+	}
+
+	results, err := m.MigrateAllTenants(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list tenants: %v\n", err)
+		os.Exit(1)
+	}
+
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+			fmt.Fprintf(os.Stderr, "%s: %v\n", res.Schema, res.Err)
+		}
+	}
+	fmt.Printf("Migrated %d tenant schemas (%d failed)\n", len(results), failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func status() {
+	m := newMigrator()
+	defer database.DB.Close()
+
+	statuses, err := m.Status(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read status: %v\n", err)
+		os.Exit(1)
+	}
+
+	behind := 0
+	fmt.Printf("%-32s %-7s %8s %8s  %s\n", "SCHEMA", "SCOPE", "CURRENT", "LATEST", "PENDING")
+	for _, s := range statuses {
+		detail := fmt.Sprint(s.Pending)
+		if s.Error != "" {
+			detail = "error: " + s.Error
+		}
+		if s.Behind() {
+			behind++
+		}
+		fmt.Printf("%-32s %-7s %8d %8d  %s\n", s.Schema, s.Scope, s.Current, s.Latest, detail)
+	}
+	if behind > 0 {
+		fmt.Printf("%d schema(s) behind\n", behind)
+		os.Exit(1)
+	}
+}
+
+func down(args []string) {
+	fs := flag.NewFlagSet("down", flag.ExitOnError)
+	schema := fs.String("schema", "", "schema to roll back")
+	to := fs.Int("to", -1, "version to roll back to (exclusive)")
+	dryRun := fs.Bool("dry-run", false, "roll back inside a transaction that is then discarded")
+	fs.Parse(args)
+
+	if *schema == "" || *to < 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	m := newMigrator()
+	defer database.DB.Close()
+	m.DryRun = *dryRun
+
+	scope := migrate.ScopeTenant
+	if *schema == "public" {
+		scope = migrate.ScopePublic
+	}
+	if _, err := m.Rollback(context.Background(), *schema, scope, *to); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *schema, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,122 @@
+// Command provision bootstraps tenants and platform operators.
+//
+//	provision tenant -name "Hertz Downtown" -subdomain hertz -admin-email owner@hertz.example
+//	provision platform-admin -email ops@rental.example
+//
+// When no password is given one is generated and printed once.
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/provisioning"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "tenant":
+		provisionTenant(os.Args[2:])
+	case "platform-admin":
+		createPlatformAdmin(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: provision tenant -name NAME -subdomain SUB -admin-email EMAIL [-admin-password PASS]")
+	fmt.Fprintln(os.Stderr, "       provision platform-admin -email EMAIL [-password PASS] [-role admin|support]")
+}
+
+func provisionTenant(args []string) {
+	fs := flag.NewFlagSet("tenant", flag.ExitOnError)
+	name := fs.String("name", "", "display name of the tenant")
+	subdomain := fs.String("subdomain", "", "subdomain the tenant is served on")
+	adminEmail := fs.String("admin-email", "", "email of the first admin user")
+	adminPassword := fs.String("admin-password", os.Getenv("PROVISION_ADMIN_PASSWORD"), "password of the first admin user (generated if empty)")
+	fs.Parse(args)
+
+	password, generated := passwordOrGenerate(*adminPassword)
+
+	connect()
+	defer database.DB.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	result, err := provisioning.ProvisionTenant(ctx, database.DB, provisioning.TenantRequest{
+		Name:          *name,
+		Subdomain:     *subdomain,
+		AdminEmail:    *adminEmail,
+		AdminPassword: password,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Provisioning failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("Tenant provisioned")
+	fmt.Printf("  Tenant ID:  %s\n", result.TenantID)
+	fmt.Printf("  Subdomain:  %s\n", result.Subdomain)
+	fmt.Printf("  Schema:     %s\n", result.SchemaName)
+	fmt.Printf("  Admin:      %s\n", *adminEmail)
+	if generated {
+		fmt.Printf("  Password:   %s (shown once, change it after first login)\n", password)
+	}
+}
+
+func createPlatformAdmin(args []string) {
+	fs := flag.NewFlagSet("platform-admin", flag.ExitOnError)
+	email := fs.String("email", "", "email of the platform operator")
+	pass := fs.String("password", os.Getenv("PROVISION_ADMIN_PASSWORD"), "password (generated if empty)")
+	role := fs.String("role", "admin", "admin or support")
+	fs.Parse(args)
+
+	password, generated := passwordOrGenerate(*pass)
+
+	connect()
+	defer database.DB.Close()
+
+	id, err := provisioning.CreatePlatformAdmin(context.Background(), database.DB, *email, password, *role)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create platform user: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Platform %s created: %s (%s)\n", *role, *email, id)
+	if generated {
+		fmt.Printf("Password: %s (shown once)\n", password)
+	}
+}
+
+func connect() {
+	if err := database.Connect(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to database: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func passwordOrGenerate(password string) (string, bool) {
+	if password != "" {
+		return password, false
+	}
+	buf := make([]byte, 18)
+	if _, err := rand.Read(buf); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to generate password: %v\n", err)
+		os.Exit(1)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), true
+}
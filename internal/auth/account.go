@@ -0,0 +1,445 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/mailer"
+	"rental-saas/internal/middleware"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Staff accounts are created by invitation: an owner enters an email and role,
+// the invitee receives a single-use link and chooses their own password. The
+// same token machinery backs forgot-password. Tokens are stored hashed in
+// user_tokens and are consumed on first use.
+
+const (
+	InviteTokenTTL = 72 * time.Hour
+	ResetTokenTTL  = 1 * time.Hour
+
+	MinPasswordLength = 8
+
+	purposeInvite = "invite"
+	purposeReset  = "password_reset"
+)
+
+var (
+	errTokenInvalid = errors.New("token invalid, expired or already used")
+	errUserExists   = errors.New("a user with this email already exists")
+)
+
+func validatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("password must be at least %d characters", MinPasswordLength)
+	}
+	if len(password) > 72 {
+		// bcrypt ignores everything past 72 bytes
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// issueUserToken replaces any outstanding token of the same purpose for the
+// user, so only the most recent email works.
+func issueUserToken(ctx context.Context, tx pgx.Tx, userID, purpose, createdBy string, ttl time.Duration) (string, error) {
+	token, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE user_tokens SET used_at = NOW()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, userID, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO user_tokens (user_id, purpose, token_hash, created_by, expires_at)
+		VALUES ($1, $2, $3, NULLIF($4, '')::uuid, $5)
+	`, userID, purpose, hashToken(token), createdBy, time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeUserToken marks the token used and returns its user. The row lock
+// makes concurrent redemptions of the same token fail for all but one caller.
+func consumeUserToken(ctx context.Context, tx pgx.Tx, token, purpose string) (User, error) {
+	var user User
+	var tokenID string
+	err := tx.QueryRow(ctx, `
+		SELECT t.id, u.id, u.email, u.role
+		FROM user_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.purpose = $2 AND t.used_at IS NULL AND t.expires_at > NOW()
+		FOR UPDATE OF t
+	`, hashToken(token), purpose).Scan(&tokenID, &user.ID, &user.Email, &user.Role)
+	if err == pgx.ErrNoRows {
+		return User{}, errTokenInvalid
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE user_tokens SET used_at = NOW() WHERE id = $1`, tokenID)
+	return user, err
+}
+
+func setPassword(ctx context.Context, tx pgx.Tx, userID, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE users SET password_hash = $1, password_changed_at = NOW() WHERE id = $2
+	`, string(hash), userID)
+	return err
+}
+
+// accountLink builds the link put into account emails. APP_BASE_URL may contain
+// {subdomain}, e.g. https://{subdomain}.yourdomain.com. The request Host is never
+// used, since a forged Host would let an attacker receive someone's reset token.
+func accountLink(ctx context.Context, tenantID, path, token string) (string, error) {
+	base := os.Getenv("APP_BASE_URL")
+	if base == "" {
+		base = "http://localhost:5173"
+	}
+	if strings.Contains(base, "{subdomain}") {
+		var subdomain string
+		err := database.DB.QueryRow(ctx, "SELECT subdomain FROM public.tenants WHERE schema_name = $1", tenantID).Scan(&subdomain)
+		if err != nil {
+			return "", fmt.Errorf("resolve subdomain for %s: %w", tenantID, err)
+		}
+		base = strings.ReplaceAll(base, "{subdomain}", subdomain)
+	}
+	return strings.TrimRight(base, "/") + path + "?token=" + url.QueryEscape(token), nil
+}
+
+func sendAccountMail(to, subject, body string) error {
+	return mailer.New().Send(mailer.Message{To: []string{to}, Subject: subject, Text: body})
+}
+
+func writeJSONError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+type InviteRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+// POST /api/users/invite
+// Creates the user without a password and emails a link to set one. Inviting
+// an address that is still pending sends a fresh link and voids the old one.
+func InviteUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req InviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	if req.Role == "" {
+		req.Role = string(RoleAgent)
+	}
+	if !strings.Contains(req.Email, "@") {
+		writeJSONError(w, http.StatusBadRequest, "valid email is required")
+		return
+	}
+	if !Role(req.Role).Valid() {
+		writeJSONError(w, http.StatusBadRequest, "unknown role: "+req.Role)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var userID, token string
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var hasPassword bool
+		err := tx.QueryRow(r.Context(), `
+			SELECT id, password_hash IS NOT NULL FROM users WHERE email = $1 FOR UPDATE
+		`, req.Email).Scan(&userID, &hasPassword)
+		switch {
+		case err == pgx.ErrNoRows:
+			err = tx.QueryRow(r.Context(), `
+				INSERT INTO users (email, role, invited_at) VALUES ($1, $2, NOW()) RETURNING id
+			`, req.Email, req.Role).Scan(&userID)
+			if err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case hasPassword:
+			return errUserExists
+		default:
+			_, err = tx.Exec(r.Context(), `
+				UPDATE users SET role = $1, invited_at = NOW() WHERE id = $2
+			`, req.Role, userID)
+			if err != nil {
+				return err
+			}
+		}
+
+		token, err = issueUserToken(r.Context(), tx, userID, purposeInvite, UserIDFromContext(r.Context()), InviteTokenTTL)
+		return err
+	})
+	if err == errUserExists {
+		writeJSONError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to invite user: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	link, err := accountLink(r.Context(), tenantID, "/accept-invite", token)
+	if err == nil {
+		err = sendAccountMail(req.Email, "You have been invited",
+			fmt.Sprintf("You have been invited to join your team's rental dashboard as %s.\n\n"+
+				"Set your password here (the link expires in %d hours and works once):\n%s\n",
+				req.Role, int(InviteTokenTTL.Hours()), link))
+	}
+	if err != nil {
+		// The user row and token exist; re-inviting sends a fresh link.
+		log.Printf("Invite: failed to send invitation to %s: %v\n", req.Email, err)
+		writeJSONError(w, http.StatusBadGateway, "user created but the invitation email could not be sent")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data": map[string]string{
+			"id":    userID,
+			"email": req.Email,
+			"role":  req.Role,
+		},
+	})
+}
+
+type SetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// POST /api/auth/invite/accept
+// Sets the invitee's password and signs them in.
+func AcceptInviteHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var resp LoginResponse
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		user, err := consumeUserToken(r.Context(), tx, req.Token, purposeInvite)
+		if err != nil {
+			return err
+		}
+		if err := setPassword(r.Context(), tx, user.ID, req.Password); err != nil {
+			return err
+		}
+		resp, err = startSession(r, tx, tenantID, user)
+		return err
+	})
+	if err == errTokenInvalid {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to accept invitation", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+// POST /api/auth/password/forgot
+// Always answers 202 so the endpoint cannot be used to discover accounts.
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ForgotPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var token string
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		// Pending invitees have no password to reset; they use their invite link
+		var userID string
+		err := tx.QueryRow(r.Context(), `
+			SELECT id FROM users WHERE lower(email) = $1 AND password_hash IS NOT NULL
+		`, email).Scan(&userID)
+		if err != nil {
+			return err
+		}
+		token, err = issueUserToken(r.Context(), tx, userID, purposeReset, "", ResetTokenTTL)
+		return err
+	})
+
+	switch {
+	case err == pgx.ErrNoRows:
+	case err != nil:
+		log.Printf("ForgotPassword: tenant %s: %v\n", tenantID, err)
+	default:
+		link, err := accountLink(r.Context(), tenantID, "/reset-password", token)
+		if err == nil {
+			err = sendAccountMail(email, "Reset your password",
+				fmt.Sprintf("Someone asked to reset the password for this account.\n\n"+
+					"Choose a new password here (the link expires in %d minutes and works once):\n%s\n\n"+
+					"If this wasn't you, you can ignore this email.\n",
+					int(ResetTokenTTL.Minutes()), link))
+		}
+		if err != nil {
+			log.Printf("ForgotPassword: failed to send reset email: %v\n", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// POST /api/auth/password/reset
+// Sets a new password from an emailed token and signs the user out everywhere.
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req SetPasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.Password); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		user, err := consumeUserToken(r.Context(), tx, req.Token, purposeReset)
+		if err != nil {
+			return err
+		}
+		if err := setPassword(r.Context(), tx, user.ID, req.Password); err != nil {
+			return err
+		}
+		_, err = RevokeUserSessions(r.Context(), tx, user.ID, "", "password_reset")
+		return err
+	})
+	if err == errTokenInvalid {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
+// POST /api/auth/password/change
+// Requires the current password; every other session of the user is revoked.
+func ChangePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req ChangePasswordRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := validatePassword(req.NewPassword); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	userID := UserIDFromContext(r.Context())
+	sessionID, _ := r.Context().Value(SessionIDKey).(string)
+
+	errWrongPassword := errors.New("current password is incorrect")
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var hash string
+		err := tx.QueryRow(r.Context(), `
+			SELECT COALESCE(password_hash, '') FROM users WHERE id = $1 FOR UPDATE
+		`, userID).Scan(&hash)
+		if err != nil {
+			return err
+		}
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(req.CurrentPassword)) != nil {
+			return errWrongPassword
+		}
+		if err := setPassword(r.Context(), tx, userID, req.NewPassword); err != nil {
+			return err
+		}
+		_, err = RevokeUserSessions(r.Context(), tx, userID, sessionID, "password_changed")
+		return err
+	})
+	if err == errWrongPassword {
+		writeJSONError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to change password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
@@ -0,0 +1,21 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidatePassword(t *testing.T) {
+	cases := map[string]bool{
+		"":                      false,
+		"short":                 false,
+		"long-enough":           true,
+		strings.Repeat("a", 72): true,
+		strings.Repeat("a", 73): false,
+	}
+	for pw, ok := range cases {
+		if err := validatePassword(pw); (err == nil) != ok {
+			t.Errorf("validatePassword(%d chars) = %v, want ok=%v", len(pw), err, ok)
+		}
+	}
+}
@@ -3,37 +3,27 @@ package auth
 import (
 	"context"
 	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
 	"rental-saas/internal/database"
 	"rental-saas/internal/middleware"
 
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
-func init() {
-	if len(jwtSecret) == 0 {
-		jwtSecret = []byte("default-secret-change-me")
-	}
-}
-
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
 type LoginResponse struct {
-	Token string `json:"token"`
-	User  User   `json:"user"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"` // access token lifetime in seconds
+	User         User   `json:"user"`
 }
 
 type User struct {
@@ -43,7 +33,6 @@ type User struct {
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Login Debug: Handler started\n")
 	var req LoginRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
@@ -52,60 +41,51 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 
 	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
 	if !ok || tenantID == "" {
-		log.Printf("Login Debug: Tenant context missing\n")
 		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
 		return
 	}
-	log.Printf("Login Debug: Attempting login for %s in tenant %s\n", req.Email, tenantID)
 
 	var user User
 	var passwordHash string
 
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		return tx.QueryRow(r.Context(), "SELECT id, email, password_hash, role FROM users WHERE email = $1", req.Email).
+		return tx.QueryRow(r.Context(), "SELECT id, email, COALESCE(password_hash, ''), role FROM users WHERE email = $1", req.Email).
 			Scan(&user.ID, &user.Email, &passwordHash, &user.Role)
 	})
 
 	if err != nil {
 		if err == pgx.ErrNoRows {
-			log.Printf("Login Debug: User not found in DB\n")
 			w.WriteHeader(http.StatusUnauthorized)
 			json.NewEncoder(w).Encode(map[string]string{"error": "User not found"})
 			return
 		}
-		log.Printf("Login Debug: Database error: %v\n", err)
+		log.Printf("Login: database error in tenant %s: %v\n", tenantID, err)
 		w.WriteHeader(http.StatusInternalServerError)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database error: " + err.Error()})
 		return
 	}
 
+	// Invited users without a password yet have an empty hash and can never match
 	if err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)); err != nil {
-		log.Printf("Login Debug: Password mismatch. Hash: %s, Input: %s\n", passwordHash, req.Password)
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]string{"error": "Password mismatch"})
 		return
 	}
-	log.Printf("Login Debug: Login successful for %s\n", user.Email)
-
-	// Generate JWT
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id":   user.ID,
-		"email":     user.Email,
-		"tenant_id": tenantID,
-		"exp":       time.Now().Add(24 * time.Hour).Unix(),
-	})
 
-	tokenString, err := token.SignedString(jwtSecret)
+	// Start a server-side session; the access token references it by sid
+	var resp LoginResponse
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		resp, err = startSession(r, tx, tenantID, user)
+		return err
+	})
 	if err != nil {
 		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(LoginResponse{
-		Token: tokenString,
-		User:  user,
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -122,25 +102,12 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := parseToken(parts[1])
+		if err != nil {
 			http.Error(w, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "Invalid token claims", http.StatusUnauthorized)
-			return
-		}
-
 		// Security Check: Tenant Isolation
 		tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
 		if !ok {
@@ -154,8 +121,28 @@ func AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		// Inject user_id into context
-		ctx := context.WithValue(r.Context(), "user_id", claims["user_id"])
+		// Revocation Check: the session behind the token must still be live
+		sessionID, _ := claims["sid"].(string)
+		if sessionID == "" {
+			http.Error(w, "Token has no session", http.StatusUnauthorized)
+			return
+		}
+		active, err := sessionActive(r.Context(), tenantID, sessionID)
+		if err != nil {
+			http.Error(w, "Failed to verify session", http.StatusInternalServerError)
+			return
+		}
+		if !active {
+			http.Error(w, "Session revoked or expired", http.StatusUnauthorized)
+			return
+		}
+
+		// Inject user_id, role and session into context for the permission layer (see rbac.go)
+		userID, _ := claims["user_id"].(string)
+		role, _ := claims["role"].(string)
+		ctx := context.WithValue(r.Context(), UserIDKey, userID)
+		ctx = context.WithValue(ctx, RoleKey, Role(role))
+		ctx = context.WithValue(ctx, SessionIDKey, sessionID)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"crypto/rand"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signing keys are configured as JWT_KEYS="kid1:secret1,kid2:secret2". The key
+// named by JWT_ACTIVE_KID (default: the first entry) signs new tokens; every
+// listed key still verifies, so a key can be rotated out by first adding the
+// new one as active and removing the old one once its tokens have expired.
+// A bare JWT_SECRET is accepted as a single key with kid "default".
+
+type signingKey struct {
+	ID     string
+	Secret []byte
+}
+
+type keyRing struct {
+	active signingKey
+	byID   map[string]signingKey
+}
+
+var keys = loadKeyRing()
+
+func loadKeyRing() *keyRing {
+	ring := &keyRing{byID: map[string]signingKey{}}
+
+	var order []string
+	for _, entry := range strings.Split(os.Getenv("JWT_KEYS"), ",") {
+		kid, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || kid == "" || secret == "" {
+			continue
+		}
+		ring.byID[kid] = signingKey{ID: kid, Secret: []byte(secret)}
+		order = append(order, kid)
+	}
+	if secret := os.Getenv("JWT_SECRET"); secret != "" {
+		if _, exists := ring.byID["default"]; !exists {
+			ring.byID["default"] = signingKey{ID: "default", Secret: []byte(secret)}
+			order = append(order, "default")
+		}
+	}
+
+	if len(order) == 0 {
+		// No shared fallback secret: tokens signed with a random key die with the process.
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(fmt.Sprintf("failed to generate ephemeral JWT key: %v", err))
+		}
+		log.Println("WARNING: JWT_KEYS/JWT_SECRET not set; using an ephemeral signing key. Tokens will not survive a restart.")
+		ring.byID["ephemeral"] = signingKey{ID: "ephemeral", Secret: secret}
+		order = append(order, "ephemeral")
+	}
+
+	ring.active = ring.byID[order[0]]
+	if kid := os.Getenv("JWT_ACTIVE_KID"); kid != "" {
+		key, ok := ring.byID[kid]
+		if !ok {
+			panic(fmt.Sprintf("JWT_ACTIVE_KID %q is not listed in JWT_KEYS", kid))
+		}
+		ring.active = key
+	}
+	return ring
+}
+
+func signToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token.Header["kid"] = keys.active.ID
+	return token.SignedString(keys.active.Secret)
+}
+
+// parseToken verifies the signature with the key named in the kid header.
+// Tokens without a kid predate key rotation and are checked against "default".
+func parseToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			kid = "default"
+		}
+		key, ok := keys.byID[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		return key.Secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid token: %v", err)
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid token claims")
+	}
+	return claims, nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyRotation(t *testing.T) {
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("JWT_KEYS", "old:old-secret")
+	t.Setenv("JWT_ACTIVE_KID", "")
+	keys = loadKeyRing()
+
+	claims := jwt.MapClaims{"user_id": "u1", "exp": time.Now().Add(time.Minute).Unix()}
+	oldToken, err := signToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: new key signs, old key still verifies
+	t.Setenv("JWT_KEYS", "old:old-secret,new:new-secret")
+	t.Setenv("JWT_ACTIVE_KID", "new")
+	keys = loadKeyRing()
+
+	if _, err := parseToken(oldToken); err != nil {
+		t.Fatalf("token signed with retired-but-listed key rejected: %v", err)
+	}
+	newToken, err := signToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, _ := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	if parsed.Header["kid"] != "new" {
+		t.Errorf("expected kid new, got %v", parsed.Header["kid"])
+	}
+
+	// Drop the old key: its tokens stop verifying
+	t.Setenv("JWT_KEYS", "new:new-secret")
+	keys = loadKeyRing()
+	if _, err := parseToken(oldToken); err == nil {
+		t.Error("token signed with removed key accepted")
+	}
+	if _, err := parseToken(newToken); err != nil {
+		t.Errorf("token signed with active key rejected: %v", err)
+	}
+}
+
+func TestLegacyTokenWithoutKid(t *testing.T) {
+	t.Setenv("JWT_KEYS", "")
+	t.Setenv("JWT_ACTIVE_KID", "")
+	t.Setenv("JWT_SECRET", "legacy")
+	keys = loadKeyRing()
+
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("legacy"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseToken(legacy); err != nil {
+		t.Errorf("token without kid rejected: %v", err)
+	}
+}
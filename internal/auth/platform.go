@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"rental-saas/internal/database"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Platform users live in public.users_global and operate across tenants
+// (provisioning, support). Their tokens never carry a tenant_id, so they are
+// rejected by AuthMiddleware and vice versa.
+
+const PlatformUserKey contextKey = "platform_user_id"
+
+func PlatformLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	var user User
+	var passwordHash string
+	err := database.DB.QueryRow(r.Context(),
+		"SELECT id, email, password_hash, role FROM public.users_global WHERE email = $1",
+		strings.ToLower(req.Email)).Scan(&user.ID, &user.Email, &passwordHash, &user.Role)
+	if err != nil && err != pgx.ErrNoRows {
+		http.Error(w, "Database error", http.StatusInternalServerError)
+		return
+	}
+	if err == pgx.ErrNoRows || bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(req.Password)) != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Invalid credentials"})
+		return
+	}
+
+	tokenString, err := signToken(jwt.MapClaims{
+		"platform_user_id": user.ID,
+		"email":            user.Email,
+		"platform_role":    user.Role,
+		"exp":              time.Now().Add(1 * time.Hour).Unix(),
+	})
+	if err != nil {
+		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(LoginResponse{
+		Token: tokenString,
+		User:  user,
+	})
+}
+
+// PlatformAdminMiddleware only lets through tokens issued by PlatformLoginHandler
+// to a users_global row with the admin role.
+func PlatformAdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.Header.Get("Authorization"), " ")
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			return
+		}
+
+		claims, err := parseToken(parts[1])
+		if err != nil {
+			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			return
+		}
+		if role, _ := claims["platform_role"].(string); role != "admin" {
+			http.Error(w, "Platform admin role required", http.StatusForbidden)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), PlatformUserKey, claims["platform_user_id"])
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+type Role string
+
+const (
+	RoleOwner    Role = "owner"
+	RoleManager  Role = "manager"
+	RoleAgent    Role = "agent"
+	RoleReadOnly Role = "read_only"
+)
+
+type Permission string
+
+const (
+	PermView            Permission = "view"
+	PermCreateBookings  Permission = "bookings.create"
+	PermHandover        Permission = "bookings.handover" // pickup and return
+	PermManageBookings  Permission = "bookings.manage"   // confirm, cancel, no-show
+	PermManageCustomers Permission = "customers.manage"
+	PermManageFleet     Permission = "fleet.manage"
+	PermManagePricing   Permission = "pricing.manage"
+	PermManageWebhooks  Permission = "webhooks.manage"
+	PermManageUsers     Permission = "users.manage"
+)
+
+var rolePermissions = map[Role][]Permission{
+	RoleOwner: {
+		PermView, PermCreateBookings, PermHandover, PermManageBookings, PermManageCustomers,
+		PermManageFleet, PermManagePricing, PermManageWebhooks, PermManageUsers,
+	},
+	RoleManager: {
+		PermView, PermCreateBookings, PermHandover, PermManageBookings, PermManageCustomers,
+		PermManageFleet, PermManagePricing, PermManageWebhooks,
+	},
+	RoleAgent:    {PermView, PermCreateBookings, PermHandover},
+	RoleReadOnly: {PermView},
+}
+
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+type contextKey string
+
+const (
+	UserIDKey contextKey = "user_id"
+	RoleKey   contextKey = "role"
+)
+
+// RoleFromContext returns the role AuthMiddleware put on the request.
+func RoleFromContext(ctx context.Context) Role {
+	role, _ := ctx.Value(RoleKey).(Role)
+	return role
+}
+
+func UserIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(UserIDKey).(string)
+	return id
+}
+
+func HasPermission(ctx context.Context, p Permission) bool {
+	return RoleFromContext(ctx).Can(p)
+}
+
+// Require rejects requests whose role lacks the permission. It must run after AuthMiddleware.
+func Require(p Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasPermission(r.Context(), p) {
+				http.Error(w, "Insufficient permissions", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
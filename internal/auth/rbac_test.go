@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{RoleOwner, PermManageUsers, true},
+		{RoleManager, PermManageUsers, false},
+		{RoleManager, PermManageWebhooks, true},
+		{RoleManager, PermManagePricing, true},
+		{RoleAgent, PermCreateBookings, true},
+		{RoleAgent, PermHandover, true},
+		{RoleAgent, PermManageWebhooks, false},
+		{RoleAgent, PermManagePricing, false},
+		{RoleAgent, PermManageBookings, false},
+		{RoleReadOnly, PermView, true},
+		{RoleReadOnly, PermCreateBookings, false},
+		{Role("staff"), PermView, false},
+	}
+	for _, tc := range cases {
+		if got := tc.role.Can(tc.perm); got != tc.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+}
+
+func TestRequireMiddleware(t *testing.T) {
+	handler := Require(PermManageWebhooks)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for role, want := range map[Role]int{
+		RoleManager:  http.StatusOK,
+		RoleAgent:    http.StatusForbidden,
+		RoleReadOnly: http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("POST", "/settings/webhooks", nil)
+		req = req.WithContext(context.WithValue(req.Context(), RoleKey, role))
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("%s: expected %d, got %d", role, want, w.Code)
+		}
+	}
+}
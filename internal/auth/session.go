@@ -0,0 +1,368 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+// Access tokens are short-lived JWTs carrying the session id (sid). Refresh
+// tokens are opaque, stored hashed in the tenant's sessions table and rotated
+// on every use; presenting an already-rotated refresh token revokes the whole
+// session, since it means the token was copied.
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+const SessionIDKey contextKey = "session_id"
+
+var errRefreshInvalid = errors.New("refresh token invalid or expired")
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func issueAccessToken(tenantID, sessionID string, user User) (string, error) {
+	return signToken(jwt.MapClaims{
+		"user_id":   user.ID,
+		"email":     user.Email,
+		"tenant_id": tenantID,
+		"role":      user.Role,
+		"sid":       sessionID,
+		"exp":       time.Now().Add(AccessTokenTTL).Unix(),
+	})
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// startSession creates a session row for the user and returns a fresh token pair.
+func startSession(r *http.Request, tx pgx.Tx, tenantID string, user User) (LoginResponse, error) {
+	refreshToken, err := newOpaqueToken()
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	var sessionID string
+	err = tx.QueryRow(r.Context(), `
+		INSERT INTO sessions (user_id, refresh_token_hash, user_agent, ip_address, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`, user.ID, hashToken(refreshToken), r.UserAgent(), clientIP(r), time.Now().Add(RefreshTokenTTL)).Scan(&sessionID)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	accessToken, err := issueAccessToken(tenantID, sessionID, user)
+	if err != nil {
+		return LoginResponse{}, err
+	}
+
+	return LoginResponse{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(AccessTokenTTL.Seconds()),
+		User:         user,
+	}, nil
+}
+
+func sessionActive(ctx context.Context, tenantID, sessionID string) (bool, error) {
+	var active bool
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			SELECT EXISTS (
+				SELECT 1 FROM sessions WHERE id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			)
+		`, sessionID).Scan(&active)
+	})
+	return active, err
+}
+
+// RevokeUserSessions ends every live session of the user, e.g. when staff leave
+// or change their password. exceptSessionID may be empty.
+func RevokeUserSessions(ctx context.Context, tx pgx.Tx, userID, exceptSessionID, reason string) (int64, error) {
+	tag, err := tx.Exec(ctx, `
+		UPDATE sessions SET revoked_at = NOW(), revoked_reason = $3
+		WHERE user_id = $1 AND revoked_at IS NULL AND id::text <> $2
+	`, userID, exceptSessionID, reason)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// POST /api/auth/refresh
+func RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	presented := hashToken(req.RefreshToken)
+	var resp LoginResponse
+	reused := false
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var sessionID string
+		var user User
+		var current string
+		err := tx.QueryRow(r.Context(), `
+			SELECT s.id, s.refresh_token_hash, u.id, u.email, u.role
+			FROM sessions s
+			JOIN users u ON u.id = s.user_id
+			WHERE (s.refresh_token_hash = $1 OR s.previous_token_hash = $1)
+			AND s.revoked_at IS NULL AND s.expires_at > NOW()
+			FOR UPDATE OF s
+		`, presented).Scan(&sessionID, &current, &user.ID, &user.Email, &user.Role)
+		if err == pgx.ErrNoRows {
+			return errRefreshInvalid
+		}
+		if err != nil {
+			return err
+		}
+
+		// Reuse of a rotated token: someone else holds a copy. Kill the session.
+		if current != presented {
+			reused = true
+			_, err := tx.Exec(r.Context(), `
+				UPDATE sessions SET revoked_at = NOW(), revoked_reason = 'refresh_token_reuse' WHERE id = $1
+			`, sessionID)
+			return err
+		}
+
+		next, err := newOpaqueToken()
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.Context(), `
+			UPDATE sessions
+			SET previous_token_hash = refresh_token_hash, refresh_token_hash = $1, last_used_at = NOW()
+			WHERE id = $2
+		`, hashToken(next), sessionID)
+		if err != nil {
+			return err
+		}
+
+		access, err := issueAccessToken(tenantID, sessionID, user)
+		if err != nil {
+			return err
+		}
+		resp = LoginResponse{
+			Token:        access,
+			RefreshToken: next,
+			ExpiresIn:    int(AccessTokenTTL.Seconds()),
+			User:         user,
+		}
+		return nil
+	})
+
+	if err == errRefreshInvalid || (err == nil && reused) {
+		http.Error(w, errRefreshInvalid.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to refresh session", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/auth/logout
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	sessionID, _ := r.Context().Value(SessionIDKey).(string)
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(r.Context(), `
+			UPDATE sessions SET revoked_at = NOW(), revoked_reason = 'logout'
+			WHERE id = $1 AND revoked_at IS NULL
+		`, sessionID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to log out", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type Session struct {
+	ID         string     `json:"id"`
+	UserAgent  string     `json:"user_agent"`
+	IPAddress  string     `json:"ip_address"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	Current    bool       `json:"current"`
+}
+
+// canManageUser allows users to manage their own sessions and owners everyone's.
+func canManageUser(r *http.Request, userID string) bool {
+	return UserIDFromContext(r.Context()) == userID || HasPermission(r.Context(), PermManageUsers)
+}
+
+// GET /api/users/{id}/sessions
+func ListSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	if !canManageUser(r, userID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	currentID, _ := r.Context().Value(SessionIDKey).(string)
+
+	sessions := []Session{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT id, COALESCE(user_agent, ''), COALESCE(ip_address, ''), created_at, last_used_at, expires_at
+			FROM sessions
+			WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+			ORDER BY COALESCE(last_used_at, created_at) DESC
+		`, userID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var s Session
+			if err := rows.Scan(&s.ID, &s.UserAgent, &s.IPAddress, &s.CreatedAt, &s.LastUsedAt, &s.ExpiresAt); err != nil {
+				return err
+			}
+			s.Current = s.ID == currentID
+			sessions = append(sessions, s)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   sessions,
+	})
+}
+
+// POST /api/users/{id}/sessions/revoke
+// Signs the user out everywhere; access tokens stop working on their next request.
+func RevokeAllSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	if !canManageUser(r, userID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var revoked int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		revoked, err = RevokeUserSessions(r.Context(), tx, userID, "", "revoked_all")
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to revoke sessions: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "success",
+		"revoked": revoked,
+	})
+}
+
+// DELETE /api/users/{id}/sessions/{sessionID}
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "id")
+	if !canManageUser(r, userID) {
+		http.Error(w, "Insufficient permissions", http.StatusForbidden)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var found bool
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), `
+			UPDATE sessions SET revoked_at = NOW(), revoked_reason = 'revoked'
+			WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+		`, chi.URLParam(r, "sessionID"), userID)
+		found = tag.RowsAffected() > 0
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to revoke session: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,296 @@
+package availability
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	ErrCarNotFound  = errors.New("car not found")
+	ErrUnavailable  = errors.New("car is not available for the requested period")
+	ErrInvalidRange = errors.New("end time must be after start time")
+	ErrNonCompliant = errors.New("car has an expired mandatory document")
+	ErrClassFull    = errors.New("no car of the class is left for the requested period")
+)
+
+// BlockingStatuses are the booking states that hold a car for their range.
+// Keep in sync with the bookings_no_overlap exclusion constraint.
+var BlockingStatuses = []string{"pending", "confirmed", "active"}
+
+// MaintenanceBlockingStatuses are the work order states that hold a car for
+// their scheduled window.
+var MaintenanceBlockingStatuses = []string{"scheduled", "in_progress"}
+
+// bookingOverlapSQL matches bookings of car $1 whose [start, end) range intersects [$2, $3).
+const bookingOverlapSQL = `
+	SELECT EXISTS (
+		SELECT 1 FROM bookings
+		WHERE car_id = $1
+		AND status = ANY($4)
+		AND tstzrange(start_time, end_time, '[)') && tstzrange($2, $3, '[)')
+	)`
+
+// maintenanceOverlapSQL matches work orders of car $1 scheduled within [$2, $3).
+const maintenanceOverlapSQL = `
+	SELECT EXISTS (
+		SELECT 1 FROM work_orders
+		WHERE car_id = $1
+		AND status = ANY($4)
+		AND tstzrange(scheduled_start, scheduled_end, '[)') && tstzrange($2, $3, '[)')
+	)`
+
+// lapsedKindsSQL matches the kinds of mandatory document of car c.id that have
+// all expired before the date in param: the car may not be rented until one is
+// replaced. Documents are valid through their expiry date.
+func lapsedKindsSQL(param string) string {
+	return `
+	SELECT 1 FROM car_documents d
+	WHERE d.car_id = c.id AND d.mandatory
+	GROUP BY d.kind
+	HAVING MAX(COALESCE(d.expires_on, 'infinity')) < ` + param + `::date`
+}
+
+func ValidateRange(start, end time.Time) error {
+	if start.IsZero() || end.IsZero() || !end.After(start) {
+		return ErrInvalidRange
+	}
+	return nil
+}
+
+// unassignedOverlapSQL counts the bookings of class $1 still waiting for a car
+// whose range intersects [$2, $3), other than booking $5 (empty for none). With a
+// branch in $6 only those picked up there count.
+const unassignedOverlapSQL = `
+	SELECT COUNT(*) FROM bookings
+	WHERE class_id = $1 AND car_id IS NULL
+	AND status = ANY($4)
+	AND tstzrange(start_time, end_time, '[)') && tstzrange($2, $3, '[)')
+	AND id::text <> $5
+	AND ($6::uuid IS NULL OR pickup_branch_id = $6)`
+
+// ReserveCar locks the car row and checks that no blocking booking or
+// scheduled maintenance overlaps the requested range, and that the car's
+// mandatory documents are valid until it ends. The lock serialises bookings
+// for the same car so the caller can insert right after; the exclusion
+// constraint remains the backstop for bookings. A car of a vehicle class
+// cannot be taken if its class bookings still waiting for a car would be
+// left without one.
+func ReserveCar(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time) error {
+	return reserveCar(ctx, tx, carID, start, end, "")
+}
+
+// AssignCar is ReserveCar for giving a car to a class booking: the booking
+// itself no longer counts against the class once it has the car.
+func AssignCar(ctx context.Context, tx pgx.Tx, carID, bookingID string, start, end time.Time) error {
+	return reserveCar(ctx, tx, carID, start, end, bookingID)
+}
+
+func reserveCar(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time, bookingID string) error {
+	if err := lockCar(ctx, tx, carID, start, end); err != nil {
+		return err
+	}
+
+	var lapsed bool
+	err := tx.QueryRow(ctx, "SELECT EXISTS ("+lapsedKindsSQL("$1")+") FROM cars c WHERE c.id = $2", end, carID).Scan(&lapsed)
+	if err != nil {
+		return err
+	}
+	if lapsed {
+		return ErrNonCompliant
+	}
+
+	var taken bool
+	if err := tx.QueryRow(ctx, bookingOverlapSQL, carID, start, end, BlockingStatuses).Scan(&taken); err != nil {
+		return err
+	}
+	if !taken {
+		err := tx.QueryRow(ctx, maintenanceOverlapSQL, carID, start, end, MaintenanceBlockingStatuses).Scan(&taken)
+		if err != nil {
+			return err
+		}
+	}
+	if taken {
+		return ErrUnavailable
+	}
+	return keepsClassCapacity(ctx, tx, carID, start, end, bookingID)
+}
+
+// keepsClassCapacity checks that once the car is taken for [start, end) the
+// other free cars of its class still cover the class bookings waiting for a
+// car in that range. It locks the class row, which class reservations also
+// take.
+func keepsClassCapacity(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time, bookingID string) error {
+	var classID *string
+	if err := tx.QueryRow(ctx, "SELECT class_id FROM cars WHERE id = $1", carID).Scan(&classID); err != nil {
+		return err
+	}
+	if classID == nil {
+		return nil
+	}
+	if err := LockClass(ctx, tx, *classID); err != nil {
+		return err
+	}
+
+	held, err := UnassignedClassBookings(ctx, tx, *classID, start, end, nil, bookingID)
+	if err != nil || held == 0 {
+		return err
+	}
+	free, err := FreeCarIDs(ctx, tx, start, end)
+	if err != nil {
+		return err
+	}
+	others, err := ClassCarIDs(ctx, tx, free, *classID)
+	if err != nil {
+		return err
+	}
+	spare := 0
+	for _, id := range others {
+		if id != carID {
+			spare++
+		}
+	}
+	if spare < held {
+		return ErrClassFull
+	}
+	return nil
+}
+
+// LockClass locks a vehicle class row, serialising the reservations that
+// draw on its capacity.
+func LockClass(ctx context.Context, tx pgx.Tx, classID string) error {
+	var id string
+	return tx.QueryRow(ctx, "SELECT id FROM vehicle_classes WHERE id = $1 FOR UPDATE", classID).Scan(&id)
+}
+
+// UnassignedClassBookings counts the blocking bookings of the class that have
+// no car yet and overlap [start, end), leaving out booking except. With a
+// pickup branch only the bookings picked up there count.
+func UnassignedClassBookings(ctx context.Context, tx pgx.Tx, classID string, start, end time.Time, pickupBranchID *string, except string) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, unassignedOverlapSQL, classID, start, end, BlockingStatuses, except, pickupBranchID).Scan(&n)
+	return n, err
+}
+
+// ClassCarIDs keeps the cars of ids that belong to the class.
+func ClassCarIDs(ctx context.Context, tx pgx.Tx, ids []string, classID string) ([]string, error) {
+	rows, err := tx.Query(ctx, "SELECT id FROM cars WHERE id = ANY($1) AND class_id = $2 ORDER BY id", ids, classID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kept := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		kept = append(kept, id)
+	}
+	return kept, rows.Err()
+}
+
+// ReserveForMaintenance is ReserveCar for a work order: it takes the same lock
+// and fails if a booking holds the car during the window. Work orders may
+// overlap each other.
+func ReserveForMaintenance(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time) error {
+	if err := lockCar(ctx, tx, carID, start, end); err != nil {
+		return err
+	}
+
+	var taken bool
+	if err := tx.QueryRow(ctx, bookingOverlapSQL, carID, start, end, BlockingStatuses).Scan(&taken); err != nil {
+		return err
+	}
+	if taken {
+		return ErrUnavailable
+	}
+	return nil
+}
+
+func lockCar(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time) error {
+	if err := ValidateRange(start, end); err != nil {
+		return err
+	}
+
+	var id string
+	err := tx.QueryRow(ctx, "SELECT id FROM cars WHERE id = $1 FOR UPDATE", carID).Scan(&id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return ErrCarNotFound
+		}
+		return fmt.Errorf("%w: %v", ErrCarNotFound, err)
+	}
+	return nil
+}
+
+// FreeCarIDs returns every car without a blocking booking or scheduled
+// maintenance in [start, end) whose mandatory documents are valid until end.
+func FreeCarIDs(ctx context.Context, tx pgx.Tx, start, end time.Time) ([]string, error) {
+	if err := ValidateRange(start, end); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.id FROM cars c
+		WHERE NOT EXISTS (
+			SELECT 1 FROM bookings b
+			WHERE b.car_id = c.id
+			AND b.status = ANY($3)
+			AND tstzrange(b.start_time, b.end_time, '[)') && tstzrange($1, $2, '[)')
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM work_orders wo
+			WHERE wo.car_id = c.id
+			AND wo.status = ANY($4)
+			AND tstzrange(wo.scheduled_start, wo.scheduled_end, '[)') && tstzrange($1, $2, '[)')
+		)
+		AND NOT EXISTS (`+lapsedKindsSQL("$2")+`)
+	`, start, end, BlockingStatuses, MaintenanceBlockingStatuses)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// NonCompliantCarIDs returns the cars that cannot be rented on the date of at
+// because a kind of mandatory document has expired.
+func NonCompliantCarIDs(ctx context.Context, tx pgx.Tx, at time.Time) ([]string, error) {
+	rows, err := tx.Query(ctx, "SELECT c.id FROM cars c WHERE EXISTS ("+lapsedKindsSQL("$1")+")", at)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// IsConflict reports whether err is the exclusion constraint rejecting an
+// overlapping booking, i.e. a race that slipped past ReserveCar.
+func IsConflict(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23P01"
+}
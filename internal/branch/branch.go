@@ -0,0 +1,141 @@
+// Package branch models a tenant's rental locations: which branch each car is
+// at, when a branch is open, and what a one-way rental between two branches
+// costs. Tenants without branches rent from a single implicit lot, and every
+// branch column is left NULL for them.
+package branch
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // branch time zones must resolve on hosts without zoneinfo
+)
+
+var (
+	ErrNotFound         = errors.New("branch not found")
+	ErrClosed           = errors.New("branch is closed at that time")
+	ErrWrongBranch      = errors.New("car is not at the pickup branch at that time")
+	ErrNeededElsewhere  = errors.New("car must be back at another branch for its next booking")
+	ErrOneWayNotOffered = errors.New("one-way rentals are not offered between these branches")
+)
+
+// Weekdays are the keys of OpeningHours, indexed by time.Weekday.
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Hours is one day's opening time and closing time, "15:04" in the branch's
+// time zone. Cars can be picked up and dropped off at both.
+type Hours struct {
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// OpeningHours maps weekdays to their hours; a missing day is closed. With no
+// days at all the branch is always open.
+type OpeningHours map[string]Hours
+
+func (oh OpeningHours) Validate() error {
+	for day, h := range oh {
+		if !validWeekday(day) {
+			return fmt.Errorf("opening_hours: unknown day %q, use %s", day, strings.Join(Weekdays, ", "))
+		}
+		open, err1 := time.Parse("15:04", h.Open)
+		closing, err2 := time.Parse("15:04", h.Close)
+		if err1 != nil || err2 != nil {
+			return fmt.Errorf("opening_hours: %s times must be HH:MM", day)
+		}
+		if !closing.After(open) {
+			return fmt.Errorf("opening_hours: %s closes before it opens", day)
+		}
+	}
+	return nil
+}
+
+// IsOpen reports whether the hours include the wall-clock time of t.
+func (oh OpeningHours) IsOpen(t time.Time) bool {
+	if len(oh) == 0 {
+		return true
+	}
+	h, ok := oh[Weekdays[t.Weekday()]]
+	if !ok {
+		return false
+	}
+	clock := t.Format("15:04")
+	return clock >= h.Open && clock <= h.Close
+}
+
+func validWeekday(day string) bool {
+	for _, d := range Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+type Branch struct {
+	ID           string       `json:"id"`
+	Name         string       `json:"name"`
+	Address      string       `json:"address"`
+	Latitude     *float64     `json:"latitude"`
+	Longitude    *float64     `json:"longitude"`
+	Timezone     string       `json:"timezone"`
+	OpeningHours OpeningHours `json:"opening_hours"`
+	Active       bool         `json:"active"`
+}
+
+func (b Branch) Validate() error {
+	if strings.TrimSpace(b.Name) == "" {
+		return errors.New("name is required")
+	}
+	if (b.Latitude == nil) != (b.Longitude == nil) {
+		return errors.New("latitude and longitude go together")
+	}
+	if b.Latitude != nil && (*b.Latitude < -90 || *b.Latitude > 90 || *b.Longitude < -180 || *b.Longitude > 180) {
+		return errors.New("latitude must be within ±90 and longitude within ±180")
+	}
+	if _, err := time.LoadLocation(b.Timezone); err != nil || b.Timezone == "" {
+		return fmt.Errorf("unknown timezone %q", b.Timezone)
+	}
+	return b.OpeningHours.Validate()
+}
+
+// OpenAt reports whether the branch is open at t, in its own time zone.
+func (b Branch) OpenAt(t time.Time) bool {
+	loc, err := time.LoadLocation(b.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return b.OpeningHours.IsOpen(t.In(loc))
+}
+
+// Route is where a rental starts and ends. Both are nil for tenants without
+// branches.
+type Route struct {
+	PickupBranchID  *string `json:"pickup_branch_id"`
+	DropoffBranchID *string `json:"dropoff_branch_id"`
+}
+
+// OneWay reports whether the rental ends at a different branch than it starts.
+func (r Route) OneWay() bool {
+	return r.PickupBranchID != nil && r.DropoffBranchID != nil && *r.PickupBranchID != *r.DropoffBranchID
+}
+
+// Fee is the charge for a one-way rental from one branch to another. Fees are
+// per direction; a pair without one is not offered.
+type Fee struct {
+	ID           string `json:"id"`
+	FromBranchID string `json:"from_branch_id"`
+	ToBranchID   string `json:"to_branch_id"`
+	FeeCents     int    `json:"fee_cents"`
+}
+
+func (f Fee) Validate() error {
+	if f.FromBranchID == f.ToBranchID {
+		return errors.New("from_branch_id and to_branch_id must differ")
+	}
+	if f.FeeCents < 0 {
+		return errors.New("fee_cents cannot be negative")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package branch
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpeningHours(t *testing.T) {
+	oh := OpeningHours{"mon": {Open: "08:00", Close: "18:00"}}
+	monday := time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)
+	cases := map[time.Time]bool{
+		monday.Add(7*time.Hour + 59*time.Minute):    false,
+		monday.Add(8 * time.Hour):                   true,
+		monday.Add(18 * time.Hour):                  true, // drop-off at closing time
+		monday.Add(18*time.Hour + time.Minute):      false,
+		monday.AddDate(0, 0, 1).Add(12 * time.Hour): false, // no hours on tuesday
+	}
+	for at, want := range cases {
+		if got := oh.IsOpen(at); got != want {
+			t.Errorf("open at %s: %v, want %v", at.Format("Mon 15:04"), got, want)
+		}
+	}
+	if !(OpeningHours{}).IsOpen(monday) {
+		t.Error("branch without hours should always be open")
+	}
+}
+
+func TestOpenAtUsesBranchTimezone(t *testing.T) {
+	b := Branch{Timezone: "Europe/Paris", OpeningHours: OpeningHours{"mon": {Open: "08:00", Close: "18:00"}}}
+	// 07:30 UTC is 08:30 in Paris in March
+	if !b.OpenAt(time.Date(2025, 3, 3, 7, 30, 0, 0, time.UTC)) {
+		t.Error("branch should be open at 08:30 local time")
+	}
+	if b.OpenAt(time.Date(2025, 3, 3, 17, 30, 0, 0, time.UTC)) {
+		t.Error("branch should be closed at 18:30 local time")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	lat, lng := 48.85, 2.35
+	bad := 120.0
+	cases := []struct {
+		branch Branch
+		want   string // the start of the error
+	}{
+		{Branch{Timezone: "UTC"}, "name is required"},
+		{Branch{Name: "Airport", Timezone: "Mars/Olympus"}, `unknown timezone "Mars/Olympus"`},
+		{Branch{Name: "Airport", Timezone: "UTC", Latitude: &lat}, "latitude and longitude go together"},
+		{Branch{Name: "Airport", Timezone: "UTC", Latitude: &bad, Longitude: &lng}, "latitude must be within"},
+		{Branch{Name: "Airport", Timezone: "UTC", OpeningHours: OpeningHours{"monday": {Open: "08:00", Close: "18:00"}}}, `opening_hours: unknown day "monday"`},
+		{Branch{Name: "Airport", Timezone: "UTC", OpeningHours: OpeningHours{"mon": {Open: "18:00", Close: "08:00"}}}, "opening_hours: mon closes before it opens"},
+		{Branch{Name: "Airport", Timezone: "UTC", OpeningHours: OpeningHours{"mon": {Open: "8am", Close: "18:00"}}}, "opening_hours: mon times must be HH:MM"},
+	}
+	for _, c := range cases {
+		if err := c.branch.Validate(); err == nil || !strings.HasPrefix(err.Error(), c.want) {
+			t.Errorf("%+v: %v, want %q", c.branch, err, c.want)
+		}
+	}
+	ok := Branch{Name: "Airport", Timezone: "Europe/Paris", Latitude: &lat, Longitude: &lng,
+		OpeningHours: OpeningHours{"sat": {Open: "09:00", Close: "13:00"}}}
+	if err := ok.Validate(); err != nil {
+		t.Errorf("valid branch rejected: %v", err)
+	}
+}
+
+func TestRouteOneWay(t *testing.T) {
+	a, b := "a", "b"
+	cases := []struct {
+		route Route
+		want  bool
+	}{
+		{Route{}, false},
+		{Route{PickupBranchID: &a, DropoffBranchID: &a}, false},
+		{Route{PickupBranchID: &a, DropoffBranchID: &b}, true},
+		{Route{PickupBranchID: &a}, false},
+	}
+	for _, c := range cases {
+		if got := c.route.OneWay(); got != c.want {
+			t.Errorf("%+v: one-way %v, want %v", c.route, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package branch
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/pricing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const Columns = `id, name, address, latitude, longitude, timezone, opening_hours, active`
+
+func Scan(row pgx.Row, b *Branch) error {
+	return row.Scan(&b.ID, &b.Name, &b.Address, &b.Latitude, &b.Longitude, &b.Timezone, &b.OpeningHours, &b.Active)
+}
+
+// Load returns an active branch.
+func Load(ctx context.Context, tx pgx.Tx, id string) (Branch, error) {
+	var b Branch
+	if _, err := uuid.Parse(id); err != nil {
+		return b, ErrNotFound
+	}
+	err := Scan(tx.QueryRow(ctx, "SELECT "+Columns+" FROM branches WHERE id = $1 AND active", id), &b)
+	if err == pgx.ErrNoRows {
+		return b, ErrNotFound
+	}
+	return b, err
+}
+
+// locationSQL is the branch car c.id will be at on $1: the drop-off of the
+// last booking that holds it and ends by then, or its current branch.
+const locationSQL = `
+	COALESCE((
+		SELECT b.dropoff_branch_id FROM bookings b
+		WHERE b.car_id = c.id AND b.status = ANY($2) AND b.end_time <= $1
+		ORDER BY b.end_time DESC LIMIT 1
+	), c.branch_id)`
+
+// At returns the branch the car will be at on t, or nil if it has none.
+func At(ctx context.Context, tx pgx.Tx, carID string, t time.Time) (*string, error) {
+	var id *string
+	err := tx.QueryRow(ctx, "SELECT "+locationSQL+" FROM cars c WHERE c.id = $3", t, availability.BlockingStatuses, carID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return nil, availability.ErrCarNotFound
+	}
+	return id, err
+}
+
+// CarsAt keeps the cars of ids that will be at the branch on t.
+func CarsAt(ctx context.Context, tx pgx.Tx, ids []string, branchID string, t time.Time) ([]string, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT c.id FROM cars c
+		WHERE c.id = ANY($3) AND `+locationSQL+` = $4
+	`, t, availability.BlockingStatuses, ids, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	kept := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		kept = append(kept, id)
+	}
+	return kept, rows.Err()
+}
+
+// Resolve completes and checks the route of a rental of carID over
+// [start, end). Pickup defaults to where the car will be at start and drop-off
+// to the pickup branch. The car must be at the pickup branch, both branches
+// must be open at the handover times, and a booking that follows must be able
+// to pick the car up where this one leaves it. Call it after
+// availability.ReserveCar, whose lock keeps the car's bookings stable.
+func Resolve(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time, r Route) (Route, error) {
+	at, err := At(ctx, tx, carID, start)
+	if err != nil {
+		return r, err
+	}
+	if r.PickupBranchID == nil {
+		r.PickupBranchID = at
+	}
+	if r.DropoffBranchID == nil {
+		r.DropoffBranchID = r.PickupBranchID
+	}
+	if r.PickupBranchID != nil && at != nil && *at != *r.PickupBranchID {
+		return r, ErrWrongBranch
+	}
+	if err := CheckHours(ctx, tx, r, start, end); err != nil {
+		return r, err
+	}
+	if r.DropoffBranchID == nil {
+		return r, nil
+	}
+
+	var next *string
+	err = tx.QueryRow(ctx, `
+		SELECT pickup_branch_id FROM bookings
+		WHERE car_id = $1 AND status = ANY($2) AND start_time >= $3
+		ORDER BY start_time LIMIT 1
+	`, carID, availability.BlockingStatuses, end).Scan(&next)
+	if err != nil && err != pgx.ErrNoRows {
+		return r, err
+	}
+	if next != nil && *next != *r.DropoffBranchID {
+		return r, ErrNeededElsewhere
+	}
+	return r, nil
+}
+
+// CheckHours checks that the route's branches are active and open at pickup
+// and drop-off.
+func CheckHours(ctx context.Context, tx pgx.Tx, r Route, start, end time.Time) error {
+	legs := []struct {
+		id   *string
+		at   time.Time
+		what string
+	}{{r.PickupBranchID, start, "pickup"}, {r.DropoffBranchID, end, "drop-off"}}
+	for _, leg := range legs {
+		if leg.id == nil {
+			continue
+		}
+		b, err := Load(ctx, tx, *leg.id)
+		if err != nil {
+			return err
+		}
+		if !b.OpenAt(leg.at) {
+			return fmt.Errorf("%w: %s at %s", ErrClosed, b.Name, leg.what)
+		}
+	}
+	return nil
+}
+
+// OneWayFee returns the charge line for a one-way route; ok is false for a
+// round trip. Pairs without a configured fee are not offered.
+func OneWayFee(ctx context.Context, tx pgx.Tx, r Route) (line pricing.Line, ok bool, err error) {
+	if !r.OneWay() {
+		return pricing.Line{}, false, nil
+	}
+	var fee int
+	var from, to string
+	err = tx.QueryRow(ctx, `
+		SELECT f.fee_cents, bf.name, bt.name
+		FROM one_way_fees f
+		JOIN branches bf ON bf.id = f.from_branch_id
+		JOIN branches bt ON bt.id = f.to_branch_id
+		WHERE f.from_branch_id = $1 AND f.to_branch_id = $2
+	`, *r.PickupBranchID, *r.DropoffBranchID).Scan(&fee, &from, &to)
+	if err == pgx.ErrNoRows {
+		return pricing.Line{}, false, ErrOneWayNotOffered
+	}
+	if err != nil {
+		return pricing.Line{}, false, err
+	}
+	return pricing.Line{
+		Kind:            pricing.LineOneWay,
+		Description:     fmt.Sprintf("One-way fee (%s to %s)", from, to),
+		Quantity:        1,
+		UnitAmountCents: fee,
+		AmountCents:     fee,
+	}, true, nil
+}
@@ -0,0 +1,165 @@
+package compliance
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/mailer"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Checker emails each tenant's owners and managers about documents reaching a
+// warning stage. Each stage is sent once per document; rows are claimed with
+// SKIP LOCKED so several API instances may run one.
+type Checker struct {
+	DB       *pgxpool.Pool
+	Interval time.Duration
+}
+
+func NewChecker(db *pgxpool.Pool) *Checker {
+	return &Checker{DB: db, Interval: 24 * time.Hour}
+}
+
+// Run checks once at startup and then every Interval until ctx is cancelled.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := c.RunOnce(ctx, time.Now()); err != nil {
+			log.Printf("compliance: checker: %v\n", err)
+		} else if n > 0 {
+			log.Printf("compliance: warned about %d documents\n", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce checks every tenant and returns how many documents were warned about.
+func (c *Checker) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	// The public schema doubles as the tenant for localhost in development
+	rows, err := c.DB.Query(ctx, `
+		SELECT schema_name FROM public.tenants
+		UNION
+		SELECT 'public' WHERE to_regclass('public.car_documents') IS NOT NULL
+		ORDER BY 1
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var schemas []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		schemas = append(schemas, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, schema := range schemas {
+		n, err := CheckTenant(ctx, schema, now)
+		total += n
+		if err != nil {
+			log.Printf("compliance: tenant %s: %v\n", schema, err)
+		}
+	}
+	return total, nil
+}
+
+// CheckTenant sends one email listing the tenant's documents that reached a
+// new warning stage on the date of now. The stages are recorded in the same
+// transaction, so a failed send is retried on the next run.
+func CheckTenant(ctx context.Context, tenantID string, now time.Time) (int, error) {
+	var warned []Expiring
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		expiring, err := ListExpiring(ctx, tx, now, true)
+		if err != nil {
+			return err
+		}
+		for _, e := range expiring {
+			if e.needsWarning() {
+				warned = append(warned, e)
+			}
+		}
+		if len(warned) == 0 {
+			return nil
+		}
+
+		for _, e := range warned {
+			if _, err := tx.Exec(ctx, "UPDATE car_documents SET warned_stage = $1 WHERE id = $2", e.State, e.ID); err != nil {
+				return err
+			}
+		}
+
+		recipients, err := staffEmails(ctx, tx)
+		if err != nil || len(recipients) == 0 {
+			return err
+		}
+		return mailer.New().Send(mailer.Message{
+			To:      recipients,
+			Subject: fmt.Sprintf("%d car documents need attention", len(warned)),
+			Text:    warningText(warned),
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(warned), nil
+}
+
+func staffEmails(ctx context.Context, tx pgx.Tx) ([]string, error) {
+	rows, err := tx.Query(ctx, "SELECT email FROM users WHERE role IN ('owner', 'manager') ORDER BY email")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var emails []string
+	for rows.Next() {
+		var e string
+		if err := rows.Scan(&e); err != nil {
+			return nil, err
+		}
+		emails = append(emails, e)
+	}
+	return emails, rows.Err()
+}
+
+func warningText(docs []Expiring) string {
+	var b strings.Builder
+	b.WriteString("The following car documents have expired or expire soon:\n\n")
+	for _, d := range docs {
+		title := d.Kind
+		if d.Title != "" {
+			title += " (" + d.Title + ")"
+		}
+		var when string
+		switch left := *d.DaysRemaining; {
+		case left < 0:
+			when = "expired on " + d.ExpiresOn.String()
+		case left == 0:
+			when = "expires today"
+		default:
+			when = fmt.Sprintf("expires on %s, in %d days", d.ExpiresOn, left)
+		}
+		fmt.Fprintf(&b, "- %s: %s %s\n", d.LicensePlate, title, when)
+		if d.Mandatory && d.State == StateExpired {
+			b.WriteString("  This car cannot be booked until a valid document is uploaded.\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,136 @@
+// Package compliance keeps each car's registration, insurance and inspection
+// certificates and tracks when they expire. A document is valid through its
+// expiry date. Staff are warned WarnDays and FinalWarnDays before that, and
+// again once it has expired; a car whose mandatory documents of some kind have
+// all expired cannot be booked.
+package compliance
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rental-saas/internal/pricing"
+)
+
+// Document kinds.
+const (
+	KindRegistration          = "registration"
+	KindInsurance             = "insurance"
+	KindInspectionCertificate = "inspection_certificate"
+	KindOther                 = "other"
+)
+
+// Kinds lists every document kind, in display order.
+var Kinds = []string{KindRegistration, KindInsurance, KindInspectionCertificate, KindOther}
+
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// MandatoryByDefault reports whether a new document of this kind blocks
+// bookings once it expires, unless the uploader says otherwise.
+func MandatoryByDefault(kind string) bool {
+	return kind != KindOther
+}
+
+// Staff are warned this many days before a document expires.
+const (
+	WarnDays      = 30
+	FinalWarnDays = 7
+)
+
+// Expiry states, which double as the warning stages.
+const (
+	StateValid    = "valid"
+	StateExpiring = "expiring"  // within WarnDays
+	StateFinal    = "final"     // within FinalWarnDays
+	StateExpired  = "expired"   // past its expiry date
+	StateNoExpiry = "no_expiry" // never expires
+)
+
+func stageRank(state string) int {
+	switch state {
+	case StateExpiring:
+		return 1
+	case StateFinal:
+		return 2
+	case StateExpired:
+		return 3
+	}
+	return 0
+}
+
+type Document struct {
+	ID          string        `json:"id"`
+	CarID       string        `json:"car_id"`
+	Kind        string        `json:"kind"`
+	Title       string        `json:"title"`
+	Filename    string        `json:"filename"`
+	ContentType string        `json:"content_type"`
+	IssuedOn    *pricing.Date `json:"issued_on"`
+	ExpiresOn   *pricing.Date `json:"expires_on"`
+	Mandatory   bool          `json:"mandatory"`
+	UploadedBy  *string       `json:"uploaded_by"`
+	CreatedAt   time.Time     `json:"created_at"`
+
+	// Filled by SetExpiry
+	State         string `json:"state"`
+	DaysRemaining *int   `json:"days_remaining,omitempty"`
+
+	ObjectName  string  `json:"-"` // served by the API, never by its storage URL
+	WarnedStage *string `json:"-"`
+}
+
+func (d Document) Validate() error {
+	if !ValidKind(d.Kind) {
+		return fmt.Errorf("kind must be one of %s", strings.Join(Kinds, ", "))
+	}
+	if d.IssuedOn != nil && d.ExpiresOn != nil && d.ExpiresOn.Before(d.IssuedOn.Time) {
+		return errors.New("expires_on cannot be before issued_on")
+	}
+	return nil
+}
+
+// Expiry works out a document's state on the date of now and the days left
+// until it expires (negative once expired).
+func Expiry(expiresOn *pricing.Date, now time.Time) (state string, daysRemaining *int) {
+	if expiresOn == nil || expiresOn.IsZero() {
+		return StateNoExpiry, nil
+	}
+	y, m, day := now.Date()
+	today := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+	left := int(expiresOn.Sub(today).Hours() / 24)
+	switch {
+	case left < 0:
+		state = StateExpired
+	case left <= FinalWarnDays:
+		state = StateFinal
+	case left <= WarnDays:
+		state = StateExpiring
+	default:
+		state = StateValid
+	}
+	return state, &left
+}
+
+// SetExpiry fills State and DaysRemaining for the date of now.
+func (d *Document) SetExpiry(now time.Time) {
+	d.State, d.DaysRemaining = Expiry(d.ExpiresOn, now)
+}
+
+// needsWarning reports whether the document has reached a warning stage that
+// staff have not been told about yet.
+func (d Document) needsWarning() bool {
+	warned := ""
+	if d.WarnedStage != nil {
+		warned = *d.WarnedStage
+	}
+	return stageRank(d.State) > stageRank(warned)
+}
@@ -0,0 +1,99 @@
+package compliance
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"rental-saas/internal/pricing"
+)
+
+func date(s string) *pricing.Date {
+	d, _ := pricing.ParseDate(s)
+	return &d
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2025, 3, 1, 18, 30, 0, 0, time.UTC)
+	cases := map[string]string{
+		"2025-05-01": StateValid,
+		"2025-03-31": StateExpiring,
+		"2025-03-08": StateFinal,
+		"2025-03-01": StateFinal, // valid through its expiry date
+		"2025-02-28": StateExpired,
+	}
+	for day, want := range cases {
+		if state, _ := Expiry(date(day), now); state != want {
+			t.Errorf("expiring %s: %s, want %s", day, state, want)
+		}
+	}
+	if state, left := Expiry(nil, now); state != StateNoExpiry || left != nil {
+		t.Errorf("no expiry date: %s %v", state, left)
+	}
+	if _, left := Expiry(date("2025-02-26"), now); *left != -3 {
+		t.Errorf("days remaining %d, want -3", *left)
+	}
+}
+
+func TestWarnsOncePerStage(t *testing.T) {
+	d := Document{ExpiresOn: date("2025-03-20")}
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	d.SetExpiry(now)
+	if !d.needsWarning() {
+		t.Fatal("first warning not sent")
+	}
+	stage := d.State
+	d.WarnedStage = &stage
+	if d.needsWarning() {
+		t.Error("same stage warned twice")
+	}
+
+	d.SetExpiry(now.AddDate(0, 0, 15))
+	if d.State != StateFinal || !d.needsWarning() {
+		t.Errorf("final warning not sent: %s", d.State)
+	}
+	d.SetExpiry(now.AddDate(0, 0, 20))
+	if d.State != StateExpired || !d.needsWarning() {
+		t.Errorf("expired warning not sent: %s", d.State)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := (Document{Kind: KindInsurance, IssuedOn: date("2025-01-01"), ExpiresOn: date("2026-01-01")}).Validate(); err != nil {
+		t.Error(err)
+	}
+	// Each document is rejected for the field named
+	for field, d := range map[string]Document{
+		"kind":       {Kind: "passport"},
+		"expires_on": {Kind: KindRegistration, IssuedOn: date("2025-01-01"), ExpiresOn: date("2024-12-31")},
+	} {
+		if err := d.Validate(); err == nil || !strings.HasPrefix(err.Error(), field+" ") {
+			t.Errorf("%+v: %v, want an error about %s", d, err, field)
+		}
+	}
+	if MandatoryByDefault(KindOther) || !MandatoryByDefault(KindInsurance) {
+		t.Error("only registration, insurance and inspection certificates are mandatory by default")
+	}
+}
+
+func TestWarningText(t *testing.T) {
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	docs := []Expiring{
+		{Document: Document{Kind: KindInsurance, ExpiresOn: date("2025-02-27"), Mandatory: true}, LicensePlate: "AB-123"},
+		{Document: Document{Kind: KindOther, Title: "Parking permit", ExpiresOn: date("2025-03-08")}, LicensePlate: "CD-456"},
+	}
+	for i := range docs {
+		docs[i].SetExpiry(now)
+	}
+	text := warningText(docs)
+	for _, want := range []string{
+		"AB-123: insurance expired on 2025-02-27",
+		"cannot be booked",
+		"CD-456: other (Parking permit) expires on 2025-03-08, in 7 days",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %q in:\n%s", want, text)
+		}
+	}
+}
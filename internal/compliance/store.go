@@ -0,0 +1,61 @@
+package compliance
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const Columns = `id, car_id, kind, title, filename, content_type, issued_on, expires_on, mandatory, uploaded_by,
+	created_at, object_name, warned_stage`
+
+func Scan(row pgx.Row, d *Document) error {
+	return row.Scan(&d.ID, &d.CarID, &d.Kind, &d.Title, &d.Filename, &d.ContentType, &d.IssuedOn, &d.ExpiresOn,
+		&d.Mandatory, &d.UploadedBy, &d.CreatedAt, &d.ObjectName, &d.WarnedStage)
+}
+
+type Expiring struct {
+	Document
+	LicensePlate string `json:"license_plate"`
+}
+
+// ListExpiring returns the documents that have expired or expire within
+// WarnDays of now, soonest first, with their state set. A document replaced by
+// a newer one of the same kind is left out. With lock the rows are locked,
+// skipping any another transaction holds.
+func ListExpiring(ctx context.Context, tx pgx.Tx, now time.Time, lock bool) ([]Expiring, error) {
+	query := `
+		SELECT d.id, d.car_id, d.kind, d.title, d.filename, d.content_type, d.issued_on, d.expires_on,
+		       d.mandatory, d.uploaded_by, d.created_at, d.object_name, d.warned_stage, c.license_plate
+		FROM car_documents d JOIN cars c ON c.id = d.car_id
+		WHERE d.expires_on IS NOT NULL AND d.expires_on <= $1::date
+		AND NOT EXISTS (
+			SELECT 1 FROM car_documents n
+			WHERE n.car_id = d.car_id AND n.kind = d.kind AND n.kind <> 'other' AND n.id <> d.id
+			AND (n.expires_on IS NULL OR n.expires_on > d.expires_on)
+		)
+		ORDER BY d.expires_on, c.license_plate`
+	if lock {
+		query += " FOR UPDATE OF d SKIP LOCKED"
+	}
+	rows, err := tx.Query(ctx, query, now.AddDate(0, 0, WarnDays))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []Expiring{}
+	for rows.Next() {
+		var e Expiring
+		d := &e.Document
+		err := rows.Scan(&d.ID, &d.CarID, &d.Kind, &d.Title, &d.Filename, &d.ContentType, &d.IssuedOn, &d.ExpiresOn,
+			&d.Mandatory, &d.UploadedBy, &d.CreatedAt, &d.ObjectName, &d.WarnedStage, &e.LicensePlate)
+		if err != nil {
+			return nil, err
+		}
+		d.SetExpiry(now)
+		docs = append(docs, e)
+	}
+	return docs, rows.Err()
+}
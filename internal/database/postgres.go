@@ -10,6 +10,8 @@ import (
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/tracelog"
+
+	"rental-saas/internal/migrate"
 )
 
 var DB *pgxpool.Pool
@@ -59,6 +61,19 @@ func Connect() error {
 		return fmt.Errorf("unable to ping database: %v", err)
 	}
 
+	// Refuse to serve traffic against schemas older than the binary expects
+	if os.Getenv("DB_REQUIRE_CURRENT_SCHEMA") == "true" {
+		migrator, err := migrate.New(DB)
+		if err != nil {
+			DB.Close()
+			return fmt.Errorf("unable to load migrations: %v", err)
+		}
+		if err := migrator.CheckCurrent(context.Background()); err != nil {
+			DB.Close()
+			return fmt.Errorf("schema check failed (run ./migrate up): %w", err)
+		}
+	}
+
 	fmt.Println("Connected to PostgreSQL")
 	return nil
 }
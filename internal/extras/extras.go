@@ -0,0 +1,109 @@
+// Package extras sells add-ons with a rental: child seats, GPS units, extra
+// drivers, snow chains. Each is priced per day or once per rental; one with
+// an inventory runs out for a period once the blocking bookings overlapping
+// it hold all of its units, the way a car is taken by one booking.
+package extras
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"rental-saas/internal/pricing"
+)
+
+// What an extra's price is charged for.
+const (
+	PerDay    = "day"
+	PerRental = "rental"
+)
+
+var (
+	ErrNotFound         = errors.New("extra not found")
+	ErrUnavailable      = errors.New("extra is not available for the requested period")
+	ErrInvalidSelection = errors.New("invalid extras selection")
+)
+
+type Extra struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PriceCents  int    `json:"price_cents"`
+	PricePer    string `json:"price_per"`
+	// Inventory is how many units the tenant has; nil means unlimited.
+	Inventory *int `json:"inventory"`
+	Active    bool `json:"active"`
+}
+
+func (e Extra) Validate() error {
+	if strings.TrimSpace(e.Name) == "" {
+		return errors.New("name is required")
+	}
+	if e.PriceCents < 0 {
+		return errors.New("price_cents cannot be negative")
+	}
+	if e.PricePer != PerDay && e.PricePer != PerRental {
+		return errors.New("price_per must be day or rental")
+	}
+	if e.Inventory != nil && *e.Inventory < 0 {
+		return errors.New("inventory cannot be negative")
+	}
+	return nil
+}
+
+// Line is the charge for quantity units of the extra over a rental of days.
+func (e Extra) Line(quantity, days int) pricing.Line {
+	desc := e.Name
+	if e.PricePer == PerDay {
+		desc += fmt.Sprintf(" (%d x %d days)", quantity, days)
+		quantity *= days
+	}
+	return pricing.Line{
+		Kind: pricing.LineExtra, Description: desc, Quantity: quantity,
+		UnitAmountCents: e.PriceCents, AmountCents: e.PriceCents * quantity,
+	}
+}
+
+// Left is how many units are free when booked are held; nil if unlimited.
+func (e Extra) Left(booked int) *int {
+	if e.Inventory == nil {
+		return nil
+	}
+	left := *e.Inventory - booked
+	if left < 0 {
+		left = 0
+	}
+	return &left
+}
+
+// Selection is a quantity of an extra taken with a booking.
+type Selection struct {
+	ExtraID  string `json:"extra_id"`
+	Quantity int    `json:"quantity"`
+}
+
+// Normalize checks a booking's selections and returns them in extra ID order,
+// which is the order they are locked in. A missing quantity means one.
+func Normalize(sels []Selection) ([]Selection, error) {
+	out := make([]Selection, 0, len(sels))
+	seen := map[string]bool{}
+	for _, s := range sels {
+		if s.ExtraID == "" {
+			return nil, fmt.Errorf("%w: extra_id is required", ErrInvalidSelection)
+		}
+		if seen[s.ExtraID] {
+			return nil, fmt.Errorf("%w: extra %s is selected twice", ErrInvalidSelection, s.ExtraID)
+		}
+		seen[s.ExtraID] = true
+		if s.Quantity == 0 {
+			s.Quantity = 1
+		}
+		if s.Quantity < 0 {
+			return nil, fmt.Errorf("%w: quantity must be positive", ErrInvalidSelection)
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ExtraID < out[j].ExtraID })
+	return out, nil
+}
@@ -0,0 +1,70 @@
+package extras
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLine(t *testing.T) {
+	seat := Extra{Name: "Child seat", PriceCents: 800, PricePer: PerDay}
+	if l := seat.Line(2, 5); l.Quantity != 10 || l.AmountCents != 8000 || l.Description != "Child seat (2 x 5 days)" {
+		t.Errorf("per day: %+v", l)
+	}
+	chains := Extra{Name: "Snow chains", PriceCents: 2500, PricePer: PerRental}
+	if l := chains.Line(1, 5); l.Quantity != 1 || l.AmountCents != 2500 || l.Description != "Snow chains" {
+		t.Errorf("per rental: %+v", l)
+	}
+}
+
+func TestLeft(t *testing.T) {
+	if (Extra{}).Left(7) != nil {
+		t.Error("extra without inventory should be unlimited")
+	}
+	three := 3
+	if left := (Extra{Inventory: &three}).Left(1); *left != 2 {
+		t.Errorf("3 units, 1 booked: %d left", *left)
+	}
+	// Inventory can be lowered below what is already booked
+	if left := (Extra{Inventory: &three}).Left(5); *left != 0 {
+		t.Errorf("3 units, 5 booked: %d left", *left)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	sels, err := Normalize([]Selection{{ExtraID: "b", Quantity: 2}, {ExtraID: "a"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sels[0] != (Selection{ExtraID: "a", Quantity: 1}) || sels[1] != (Selection{ExtraID: "b", Quantity: 2}) {
+		t.Errorf("got %+v", sels)
+	}
+
+	cases := map[string][]Selection{
+		"no id":    {{Quantity: 1}},
+		"twice":    {{ExtraID: "a"}, {ExtraID: "a", Quantity: 2}},
+		"negative": {{ExtraID: "a", Quantity: -1}},
+	}
+	for name, sels := range cases {
+		if _, err := Normalize(sels); !errors.Is(err, ErrInvalidSelection) {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	negative := -1
+	cases := map[string]Extra{
+		"name is required":                {PriceCents: 500, PricePer: PerDay},
+		"price_cents cannot be negative":  {Name: "GPS", PriceCents: -1, PricePer: PerDay},
+		"price_per must be day or rental": {Name: "GPS", PriceCents: 500, PricePer: "week"},
+		"inventory cannot be negative":    {Name: "GPS", PriceCents: 500, PricePer: PerDay, Inventory: &negative},
+	}
+	for want, e := range cases {
+		if err := e.Validate(); err == nil || err.Error() != want {
+			t.Errorf("%+v: %v, want %q", e, err, want)
+		}
+	}
+	if err := (Extra{Name: "Extra driver", PricePer: PerRental}).Validate(); err != nil {
+		t.Errorf("free extra rejected: %v", err)
+	}
+}
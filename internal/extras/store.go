@@ -0,0 +1,79 @@
+package extras
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/pricing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const Columns = `id, name, description, price_cents, price_per, inventory, active`
+
+func Scan(row pgx.Row, e *Extra) error {
+	return row.Scan(&e.ID, &e.Name, &e.Description, &e.PriceCents, &e.PricePer, &e.Inventory, &e.Active)
+}
+
+// Booked returns how many units of the extra the blocking bookings
+// overlapping [start, end) hold.
+func Booked(ctx context.Context, tx pgx.Tx, extraID string, start, end time.Time) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `
+		SELECT COALESCE(SUM(be.quantity), 0) FROM booking_extras be
+		JOIN bookings b ON b.id = be.booking_id
+		WHERE be.extra_id = $1
+		AND b.status = ANY($4)
+		AND tstzrange(b.start_time, b.end_time, '[)') && tstzrange($2, $3, '[)')
+	`, extraID, start, end, availability.BlockingStatuses).Scan(&n)
+	return n, err
+}
+
+// Reserve locks the selected extras, in the order Normalize leaves them, and
+// checks that each is active and has enough units left over [start, end). It
+// returns their charges for the rental; the caller saves the selections with
+// Save once the booking exists.
+func Reserve(ctx context.Context, tx pgx.Tx, sels []Selection, start, end time.Time) ([]pricing.Line, error) {
+	var lines []pricing.Line
+	for _, s := range sels {
+		if _, err := uuid.Parse(s.ExtraID); err != nil {
+			return nil, ErrNotFound
+		}
+		var e Extra
+		err := Scan(tx.QueryRow(ctx, "SELECT "+Columns+" FROM extras WHERE id = $1 AND active FOR UPDATE", s.ExtraID), &e)
+		if err == pgx.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if e.Inventory != nil {
+			booked, err := Booked(ctx, tx, e.ID, start, end)
+			if err != nil {
+				return nil, err
+			}
+			if left := e.Left(booked); *left < s.Quantity {
+				return nil, fmt.Errorf("%w: %d %s left", ErrUnavailable, *left, e.Name)
+			}
+		}
+		lines = append(lines, e.Line(s.Quantity, pricing.Days(start, end)))
+	}
+	return lines, nil
+}
+
+// Save records the extras a new booking takes.
+func Save(ctx context.Context, tx pgx.Tx, bookingID string, sels []Selection) error {
+	for _, s := range sels {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO booking_extras (booking_id, extra_id, quantity) VALUES ($1, $2, $3)
+		`, bookingID, s.ExtraID, s.Quantity)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
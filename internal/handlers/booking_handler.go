@@ -1,20 +1,30 @@
 package handlers
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"time"
 
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
 	"rental-saas/internal/database"
-	"rental-saas/internal/mailer"
+	"rental-saas/internal/extras"
+	"rental-saas/internal/inspection"
+	"rental-saas/internal/invoice"
+	"rental-saas/internal/maintenance"
 	"rental-saas/internal/middleware"
 	"rental-saas/internal/models"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+	"rental-saas/internal/vehicleclass"
+	"rental-saas/internal/webhooks"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
-	"github.com/jung-kurt/gofpdf"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/paymentintent"
 )
@@ -26,10 +36,16 @@ func NewBookingHandler() *BookingHandler {
 }
 
 type CreateBookingRequest struct {
+	// One of: a car, or a vehicle class whose car is assigned before pickup
 	CarID      string    `json:"car_id"`
+	ClassID    string    `json:"class_id"`
 	CustomerID string    `json:"customer_id"`
 	StartTime  time.Time `json:"start_time"`
 	EndTime    time.Time `json:"end_time"`
+	// Optional: pickup defaults to the car's branch, drop-off to pickup
+	branch.Route
+	// Optional: child seats, GPS units and the like from /api/extras
+	Extras []extras.Selection `json:"extras"`
 }
 
 func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
@@ -45,51 +61,244 @@ func (h *BookingHandler) CreateBooking(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		// 1. Pessimistic Locking: Lock the car row to prevent double booking
-		var status models.CarStatus
-		err := tx.QueryRow(r.Context(), "SELECT status FROM cars WHERE id = $1 FOR UPDATE", req.CarID).Scan(&status)
+	if err := availability.ValidateRange(req.StartTime, req.EndTime); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if (req.CarID == "") == (req.ClassID == "") {
+		http.Error(w, "Exactly one of car_id and class_id is required", http.StatusBadRequest)
+		return
+	}
+	sels, err := extras.Normalize(req.Extras)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var bookingID string
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		// 1. Lock the car (or class) and the extras and check they are free in the
+		// requested range, then price them with the current rules and taxes
+		rt, err := reserveRental(r.Context(), tx, req.CarID, req.ClassID, req.StartTime, req.EndTime, req.Route, sels, pricing.ChannelDashboard)
 		if err != nil {
-			return fmt.Errorf("car not found: %w", err)
+			return err
 		}
-
-		// 2. Check Availability
-		if status != models.CarStatusAvailable {
-			return fmt.Errorf("car is not available (status: %s)", status)
+		if err := rt.Quote.Bookable(); err != nil {
+			return err
 		}
 
-		// 3. Create Booking
-		var bookingID string
+		// 2. Create Booking. The car keeps its current status until pickup.
 		err = tx.QueryRow(r.Context(), `
-			INSERT INTO bookings (car_id, customer_id, start_time, end_time, status)
-			VALUES ($1, $2, $3, $4, 'pending')
+			INSERT INTO bookings (car_id, class_id, customer_id, start_time, end_time, status, total_amount_cents, pickup_branch_id, dropoff_branch_id)
+			VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, $4, $5, 'pending', $6, $7, $8)
 			RETURNING id
-		`, req.CarID, req.CustomerID, req.StartTime, req.EndTime).Scan(&bookingID)
+		`, req.CarID, req.ClassID, req.CustomerID, req.StartTime, req.EndTime, rt.Quote.TotalCents, rt.Route.PickupBranchID, rt.Route.DropoffBranchID).Scan(&bookingID)
+		if availability.IsConflict(err) {
+			return availability.ErrUnavailable
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create booking: %w", err)
 		}
+		if err := rt.save(r.Context(), tx, bookingID); err != nil {
+			return err
+		}
+
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCreated, bookingID, "dashboard")
+	})
+
+	if err != nil {
+		writeAvailabilityError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "booking_id": bookingID})
+}
+
+// rental is what reserveRental holds and prices for a new booking.
+type rental struct {
+	Route  branch.Route
+	Quote  pricing.Quote
+	Taxes  tax.Config
+	Extras []extras.Selection
+}
+
+// reserveRental holds what a new booking draws on, the car or a unit of its
+// vehicle class and the selected extras, completes its route and prices it
+// all on channel.
+func reserveRental(ctx context.Context, tx pgx.Tx, carID, classID string, start, end time.Time, route branch.Route, sels []extras.Selection, channel string) (rental, error) {
+	rt := rental{Route: route, Extras: sels}
+	var err error
+	if classID != "" {
+		_, rt.Route, err = vehicleclass.Reserve(ctx, tx, classID, start, end, route)
+	} else if err = availability.ReserveCar(ctx, tx, carID, start, end); err == nil {
+		rt.Route, err = branch.Resolve(ctx, tx, carID, start, end, route)
+	}
+	if err != nil {
+		return rt, err
+	}
+	lines, err := extras.Reserve(ctx, tx, sels, start, end)
+	if err != nil {
+		return rt, err
+	}
+	rt.Quote, rt.Taxes, err = quoteRental(ctx, tx, carID, classID, start, end, channel, rt.Route, lines)
+	return rt, err
+}
+
+// save records the rental on its new booking: the charges, the extras taken
+// and the tax configuration they were priced with.
+func (rt rental) save(ctx context.Context, tx pgx.Tx, bookingID string) error {
+	if err := pricing.SaveCharges(ctx, tx, bookingID, rt.Quote.Lines); err != nil {
+		return err
+	}
+	if err := extras.Save(ctx, tx, bookingID, rt.Extras); err != nil {
+		return err
+	}
+	return tax.Freeze(ctx, tx, bookingID, rt.Taxes)
+}
+
+// quoteRental prices a rental of a car like pricing.QuoteCar, or of a vehicle
+// class at its rate, adds the route's one-way fee and the charges of any
+// extras, and taxes it all with the tenant's current configuration.
+func quoteRental(ctx context.Context, tx pgx.Tx, carID, classID string, start, end time.Time, channel string, route branch.Route, extraLines []pricing.Line) (pricing.Quote, tax.Config, error) {
+	var quote pricing.Quote
+	var err error
+	if classID != "" {
+		var class vehicleclass.Class
+		if class, err = vehicleclass.Load(ctx, tx, classID); err == nil {
+			quote, err = vehicleclass.Quote(ctx, tx, class, start, end, channel)
+		}
+	} else {
+		quote, err = pricing.QuoteCar(ctx, tx, carID, start, end, channel)
+	}
+	if err != nil {
+		return quote, tax.Config{}, err
+	}
+	fee, ok, err := branch.OneWayFee(ctx, tx, route)
+	if err != nil {
+		return quote, tax.Config{}, err
+	}
+	if ok {
+		quote.Add(fee)
+	}
+	for _, l := range extraLines {
+		quote.Add(l)
+	}
+	taxes, err := tax.Load(ctx, tx)
+	if err != nil {
+		return quote, taxes, err
+	}
+	taxes.Apply(&quote)
+	return quote, taxes, nil
+}
+
+// writeAvailabilityError maps booking errors to status codes: 409 Conflict for
+// double booking, a full vehicle class, an extra that ran out, a car with
+// expired documents or at another branch, 404 for unknown cars, classes,
+// extras and branches.
+func writeAvailabilityError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, availability.ErrUnavailable), errors.Is(err, availability.ErrNonCompliant),
+		errors.Is(err, availability.ErrClassFull), errors.Is(err, extras.ErrUnavailable),
+		errors.Is(err, branch.ErrWrongBranch), errors.Is(err, branch.ErrNeededElsewhere):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, availability.ErrCarNotFound), errors.Is(err, pricing.ErrCarNotFound),
+		errors.Is(err, vehicleclass.ErrNotFound), errors.Is(err, extras.ErrNotFound),
+		errors.Is(err, branch.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, availability.ErrInvalidRange), errors.Is(err, pricing.ErrMinimumLength),
+		errors.Is(err, branch.ErrClosed), errors.Is(err, branch.ErrOneWayNotOffered),
+		errors.Is(err, vehicleclass.ErrPickupRequired), errors.Is(err, extras.ErrInvalidSelection):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+type AvailableCar struct {
+	ID             string `json:"id"`
+	Make           string `json:"make"`
+	Model          string `json:"model"`
+	LicensePlate   string `json:"license_plate"`
+	Status         string `json:"status"`
+	DailyRateCents int    `json:"daily_rate_cents"`
+}
+
+// GET /api/availability?start=...&end=... (RFC 3339)[&branch_id=...]
+// With branch_id only cars that will be at that branch at start are listed.
+func (h *BookingHandler) GetAvailability(w http.ResponseWriter, r *http.Request) {
+	start, err1 := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	end, err2 := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "start and end must be RFC 3339 timestamps", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
 
-		// 4. Update Car Status
-		_, err = tx.Exec(r.Context(), "UPDATE cars SET status = $1 WHERE id = $2", models.CarStatusRented, req.CarID)
+	cars := []AvailableCar{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		ids, err := availability.FreeCarIDs(r.Context(), tx, start, end)
 		if err != nil {
-			return fmt.Errorf("failed to update car status: %w", err)
+			return err
+		}
+		if branchID := r.URL.Query().Get("branch_id"); branchID != "" {
+			if _, err := branch.Load(r.Context(), tx, branchID); err != nil {
+				return err
+			}
+			if ids, err = branch.CarsAt(r.Context(), tx, ids, branchID, start); err != nil {
+				return err
+			}
 		}
 
-		return nil
+		rows, err := tx.Query(r.Context(), `
+			SELECT id, make, model, license_plate, status, daily_rate_cents
+			FROM cars
+			WHERE id = ANY($1)
+			ORDER BY make, model
+		`, ids)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c AvailableCar
+			if err := rows.Scan(&c.ID, &c.Make, &c.Model, &c.LicensePlate, &c.Status, &c.DailyRateCents); err != nil {
+				return err
+			}
+			cars = append(cars, c)
+		}
+		return rows.Err()
 	})
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusConflict) // 409 Conflict for double booking
+		writeAvailabilityError(w, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   cars,
+	})
 }
 
 type ReturnCarRequest struct {
-	FinalOdometer   int `json:"final_odometer"`
+	FinalOdometer int `json:"final_odometer"`
+	// A lump damage charge, for returns without a return inspection. With one,
+	// the new damage it found is billed item by item instead.
 	DamageCostCents int `json:"damage_cost_cents"`
+	// Fuel in eighths, or charge in percent for electric cars. A car returned
+	// below its pickup level is charged at the tenant's refuel price.
+	EnergyLevel *int `json:"energy_level"`
+	// The branch the car was left at, if not the booking's drop-off branch.
+	// The car moves there; no one-way fee is added.
+	BranchID *string `json:"branch_id"`
 }
 
 func (h *BookingHandler) ReturnCar(w http.ResponseWriter, r *http.Request) {
@@ -112,7 +321,8 @@ func (h *BookingHandler) ReturnCar(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var customerEmail, firstName, lastName, carMake, carModel string
+	var issued invoice.Invoice
+	var outstandingCents int
 
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
 		// 1. Fetch Booking and Payment Info with LOCK
@@ -121,126 +331,223 @@ func (h *BookingHandler) ReturnCar(w http.ResponseWriter, r *http.Request) {
 		var startTime, endTime time.Time
 		var status string
 		var stripeIntentID string
-		var dailyRateCents int
+		var carStatus string
+		var startOdometer, includedKm, carIncludedKmPerDay *int
+		var overageCentsPerKm, carOdometer, carOverageCentsPerKm int
+		var startEnergyLevel *int
+		var fuelType string
+		var dropoffBranchID *string
 
 		err := tx.QueryRow(r.Context(), `
-			SELECT b.car_id, b.start_time, b.end_time, b.status, p.stripe_intent_id, c.daily_rate_cents,
-			       cust.email, cust.first_name, cust.last_name, c.make, c.model
+			SELECT b.car_id, b.start_time, b.end_time, b.status, p.stripe_intent_id, c.status,
+			       b.start_odometer, b.included_km, COALESCE(b.overage_cents_per_km, 0),
+			       c.odometer, c.included_km_per_day, c.overage_cents_per_km,
+			       b.start_energy_level, c.fuel_type, b.dropoff_branch_id
 			FROM bookings b
 			JOIN payments p ON b.id = p.booking_id
 			JOIN cars c ON b.car_id = c.id
-			JOIN customers cust ON b.customer_id = cust.id
 			WHERE b.id = $1
 			FOR UPDATE OF b
-		`, bookingID).Scan(&carID, &startTime, &endTime, &status, &stripeIntentID, &dailyRateCents,
-			&customerEmail, &firstName, &lastName, &carMake, &carModel)
+		`, bookingID).Scan(&carID, &startTime, &endTime, &status, &stripeIntentID, &carStatus,
+			&startOdometer, &includedKm, &overageCentsPerKm,
+			&carOdometer, &carIncludedKmPerDay, &carOverageCentsPerKm,
+			&startEnergyLevel, &fuelType, &dropoffBranchID)
 
 		if err != nil {
 			return fmt.Errorf("booking not found or payment missing: %w", err)
 		}
 
-		// 2. State Machine Validation
-		// Can only return a car that is currently 'active' (or 'rented' depending on terminology, assuming 'active' based on context, but previous code checked != completed. Let's be stricter.)
-		// If the previous status was 'pending', we shouldn't be able to return it? 
-		// Let's assume 'confirmed' or 'active' is the state. The CreateBooking sets it to 'pending'. 
-		// Payment webhook likely sets it to 'active'.
-		// For safety, we ensure it is NOT 'completed' and NOT 'cancelled'.
-		if status == "completed" {
-			return fmt.Errorf("booking already completed")
+		// 2. State Machine Validation: only a picked-up (active) booking can be returned
+		if err := models.ValidateBookingTransition(models.BookingStatus(status), models.BookingStatusCompleted); err != nil {
+			return err
+		}
+
+		// 3. Calculate Final Price from the charges frozen at booking time.
+		// Bookings made before itemised pricing are priced and frozen now.
+		charges, err := pricing.Charges(r.Context(), tx, bookingID)
+		if err != nil {
+			return err
+		}
+		var settlement []pricing.Line
+		if len(charges) == 0 {
+			quote, err := pricing.QuoteCar(r.Context(), tx, carID, startTime, endTime, "")
+			if err != nil {
+				return err
+			}
+			settlement = quote.Lines
+		}
+
+		// Bookings picked up before mileage tracking are held to the car's current allowance
+		mileage := pricing.Allowance(carOdometer, carIncludedKmPerDay, carOverageCentsPerKm, pricing.Days(startTime, endTime))
+		if startOdometer != nil {
+			mileage = pricing.Mileage{StartOdometer: *startOdometer, IncludedKm: includedKm, OverageCentsPerKm: overageCentsPerKm}
 		}
-		if status == "cancelled" {
-			return fmt.Errorf("booking is cancelled")
+		overage, ok, err := mileage.Overage(req.FinalOdometer)
+		if err != nil {
+			return err
+		}
+		if ok {
+			settlement = append(settlement, overage)
+		}
+		if req.EnergyLevel != nil {
+			if err := pricing.ValidateLevel(fuelType, *req.EnergyLevel); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidEnergyLevel, err)
+			}
+			if startEnergyLevel != nil {
+				settings, err := pricing.LoadSettings(r.Context(), tx)
+				if err != nil {
+					return err
+				}
+				if refuel, ok := settings.Refuel(fuelType, *startEnergyLevel, *req.EnergyLevel); ok {
+					settlement = append(settlement, refuel)
+				}
+			}
 		}
 
-		// 3. Calculate Final Price
-		days := int(endTime.Sub(startTime).Hours() / 24)
-		if days < 1 {
-			days = 1
+		// Damage: the return inspection's new items, or the lump sum without one
+		damageCents, needsRepair := req.DamageCostCents, req.DamageCostCents > 0
+		_, returnReport, err := inspection.ForBooking(r.Context(), tx, bookingID)
+		if err != nil {
+			return err
+		}
+		if returnReport != nil {
+			if req.DamageCostCents > 0 {
+				return errDamageWithInspection
+			}
+			damageCents, needsRepair = 0, false
+			for _, item := range returnReport.Items {
+				if !item.New {
+					continue
+				}
+				damageCents += item.EstimatedCostCents
+				needsRepair = needsRepair || item.Severity.NeedsRepair()
+				if item.EstimatedCostCents > 0 {
+					desc := "Damage: " + item.Label()
+					if item.Description != "" {
+						desc += ", " + item.Description
+					}
+					settlement = append(settlement, pricing.Line{
+						Kind: pricing.LineDamage, Description: desc, Quantity: 1,
+						UnitAmountCents: item.EstimatedCostCents, AmountCents: item.EstimatedCostCents,
+					})
+				}
+			}
+		} else if req.DamageCostCents > 0 {
+			settlement = append(settlement, pricing.Line{
+				Kind: pricing.LineDamage, Description: "Damage", Quantity: 1,
+				UnitAmountCents: req.DamageCostCents, AmountCents: req.DamageCostCents,
+			})
 		}
-		rentalCost := days * dailyRateCents
-		finalAmount := rentalCost + req.DamageCostCents
+		if req.BranchID != nil {
+			if _, err := branch.Load(r.Context(), tx, *req.BranchID); err != nil {
+				return err
+			}
+			dropoffBranchID = req.BranchID
+		}
+		if err := pricing.SaveCharges(r.Context(), tx, bookingID, settlement); err != nil {
+			return err
+		}
+		// Taxes cover the whole rental, damage and other settlement charges included
+		_, charged, err := tax.Settle(r.Context(), tx, bookingID)
+		if err != nil {
+			return err
+		}
+		finalAmount := pricing.Total(charged)
 
 		// 4. Capture Stripe Payment
 		// Note: We are inside a DB transaction. If this fails, we rollback.
-		// If this succeeds but DB commit fails, we have an issue. 
+		// If this succeeds but DB commit fails, we have an issue.
 		// Ideally we would use Idempotency Keys with Stripe based on the booking ID.
-		params := &stripe.PaymentIntentCaptureParams{
-			AmountToCapture: stripe.Int64(int64(finalAmount)),
-		}
-		// Idempotency key to prevent double capture on retry
-		params.IdempotencyKey = stripe.String("capture_" + bookingID)
-		
-		_, err = paymentintent.Capture(stripeIntentID, params)
+		// Stripe cannot capture more than the card holds: what the charges
+		// added at return take beyond it is left outstanding on the payment
+		// instead, so the return always completes
+		pi, err := paymentintent.Get(stripeIntentID, nil)
 		if err != nil {
-			return fmt.Errorf("failed to capture payment: %w", err)
+			return fmt.Errorf("failed to load payment: %w", err)
+		}
+		var captureCents int
+		captureCents, outstandingCents = pricing.Capture(finalAmount, int(pi.AmountCapturable))
+		if captureCents > 0 {
+			params := &stripe.PaymentIntentCaptureParams{
+				AmountToCapture: stripe.Int64(int64(captureCents)),
+			}
+			params.AddMetadata("tax_amount", strconv.Itoa(tax.Amount(charged)))
+			params.AddMetadata("outstanding_amount", strconv.Itoa(outstandingCents))
+			// Idempotency key to prevent double capture on retry. It carries the
+			// amount, so a return corrected after a failed capture is not answered
+			// with the stored failure; an intent is captured once whatever the key.
+			params.IdempotencyKey = stripe.String(fmt.Sprintf("capture_%s_%d", bookingID, captureCents))
+
+			if _, err := paymentintent.Capture(stripeIntentID, params); err != nil {
+				return fmt.Errorf("failed to capture payment: %w", err)
+			}
 		}
 
 		// 5. Update Database Records
 		// Update Booking
 		_, err = tx.Exec(r.Context(), `
 			UPDATE bookings 
-			SET status = 'completed', final_odometer = $1, damage_cost_cents = $2, total_amount_cents = $3
-			WHERE id = $4
-		`, req.FinalOdometer, req.DamageCostCents, finalAmount, bookingID)
+			SET status = 'completed', final_odometer = $1, damage_cost_cents = $2, total_amount_cents = $3,
+			    final_energy_level = $4, dropoff_branch_id = $5, returned_at = NOW()
+			WHERE id = $6
+		`, req.FinalOdometer, damageCents, finalAmount, req.EnergyLevel, dropoffBranchID, bookingID)
 		if err != nil {
 			return err
 		}
 
-		// Update Car Status
+		// Update Car Status: damage that needs a repair takes the car out of service
 		newCarStatus := models.CarStatusAvailable
-		if req.DamageCostCents > 0 {
+		if needsRepair {
 			newCarStatus = models.CarStatusMaintenance
 		}
 		_, err = tx.Exec(r.Context(), `
 			UPDATE cars 
-			SET status = $1, odometer = $2 
-			WHERE id = $3
-		`, newCarStatus, req.FinalOdometer, carID)
+			SET status = $1, odometer = $2, energy_level = COALESCE($3, energy_level), branch_id = COALESCE($4, branch_id)
+			WHERE id = $5
+		`, newCarStatus, req.FinalOdometer, req.EnergyLevel, dropoffBranchID, carID)
 		if err != nil {
 			return err
 		}
 
 		// Update Payment Status
 		_, err = tx.Exec(r.Context(), `
-			UPDATE payments 
-			SET status = 'captured', amount_cents = $1 
-			WHERE stripe_intent_id = $2
-		`, finalAmount, stripeIntentID)
+			UPDATE payments
+			SET status = 'captured', amount_cents = $1, outstanding_cents = $2
+			WHERE stripe_intent_id = $3
+		`, captureCents, outstandingCents, stripeIntentID)
 		if err != nil {
 			return err
 		}
 
-		return nil
+		// The invoice is issued with the capture, so its number is never lost
+		// to a return that rolls back
+		if issued, err = invoice.Issue(r.Context(), tx, bookingID, time.Now()); err != nil {
+			return err
+		}
+
+		if err := webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCompleted, bookingID, ""); err != nil {
+			return err
+		}
+		if err := webhooks.EmitPayment(r.Context(), tx, webhooks.EventPaymentCaptured, stripeIntentID); err != nil {
+			return err
+		}
+		if err := webhooks.EmitCarStatusChanged(r.Context(), tx, carID, carStatus, string(newCarStatus), "return"); err != nil {
+			return err
+		}
+		return maintenance.CheckDue(r.Context(), tx, carID, time.Now())
 	})
 
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		writeLifecycleError(w, err)
 		return
 	}
 
-	// Send Email Async
-	go func() {
-		pdf := gofpdf.New("P", "mm", "A4", "")
-		pdf.AddPage()
-		pdf.SetFont("Arial", "B", 16)
-		pdf.Cell(40, 10, fmt.Sprintf("Invoice - %s", tenantID))
-		pdf.Ln(12)
-		pdf.SetFont("Arial", "", 12)
-		pdf.Cell(40, 10, fmt.Sprintf("Customer: %s %s", firstName, lastName))
-		pdf.Ln(8)
-		pdf.Cell(40, 10, fmt.Sprintf("Vehicle: %s %s", carMake, carModel))
-		pdf.Ln(12)
-		pdf.Cell(40, 10, "Total Paid: Captured") // Simplified for async
-
-		var buf bytes.Buffer
-		if err := pdf.Output(&buf); err == nil {
-			m := mailer.NewSMTPMailer()
-			m.SendInvoice(customerEmail, buf.Bytes())
-		} else {
-			fmt.Printf("Failed to generate PDF for email: %v\n", err)
-		}
-	}()
-
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(`{"status":"success"}`))
+	emailInvoice(issued)
+
+	// outstanding_amount_cents is what the card could not cover, to collect from the customer
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":                   "success",
+		"outstanding_amount_cents": outstandingCents,
+	})
 }
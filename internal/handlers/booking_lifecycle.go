@@ -0,0 +1,484 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
+	"rental-saas/internal/database"
+	"rental-saas/internal/maintenance"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/models"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/vehicleclass"
+	"rental-saas/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/stripe/stripe-go/v76"
+	"github.com/stripe/stripe-go/v76/paymentintent"
+)
+
+var (
+	errBookingNotFound      = errors.New("booking not found")
+	errPaymentNotAuthorized = errors.New("payment has not been authorized")
+	errCarNotReady          = errors.New("car is not ready for pickup")
+	errNoShowTooEarly       = errors.New("booking cannot be marked as no-show before its start time")
+	errOdometerBehind       = errors.New("odometer reading is lower than the car's last recorded reading")
+	errInvalidEnergyLevel   = errors.New("invalid fuel or charge level")
+	errDamageWithInspection = errors.New("damage_cost_cents cannot be given when a return inspection is recorded")
+	errNoCarAssigned        = errors.New("booking has no car assigned yet")
+	errAssignAfterPickup    = errors.New("a car can only be assigned before pickup")
+	errClassRequired        = errors.New("car_id is required for a booking without a vehicle class")
+)
+
+// lockBooking loads the booking row FOR UPDATE and validates the move to next.
+// carID is empty for a class booking still waiting for its car.
+func lockBooking(ctx context.Context, tx pgx.Tx, bookingID string, next models.BookingStatus) (carID string, startTime time.Time, err error) {
+	var status models.BookingStatus
+	var car *string
+	err = tx.QueryRow(ctx, `
+		SELECT status, car_id, start_time FROM bookings WHERE id = $1 FOR UPDATE
+	`, bookingID).Scan(&status, &car, &startTime)
+	if err == pgx.ErrNoRows {
+		return "", time.Time{}, errBookingNotFound
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if car != nil {
+		carID = *car
+	}
+	return carID, startTime, models.ValidateBookingTransition(status, next)
+}
+
+// voidBookingPayment cancels any open (uncaptured) Stripe intent for the booking.
+func voidBookingPayment(ctx context.Context, tx pgx.Tx, bookingID, reason string) error {
+	var intentID string
+	err := tx.QueryRow(ctx, `
+		SELECT stripe_intent_id FROM payments
+		WHERE booking_id = $1 AND status IN ('pending_auth', 'authorized')
+		FOR UPDATE
+	`, bookingID).Scan(&intentID)
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	params := &stripe.PaymentIntentCancelParams{
+		CancellationReason: stripe.String(reason),
+	}
+	params.IdempotencyKey = stripe.String("cancel_" + bookingID)
+	if _, err := paymentintent.Cancel(intentID, params); err != nil {
+		return fmt.Errorf("failed to void payment: %w", err)
+	}
+
+	_, err = tx.Exec(ctx, "UPDATE payments SET status = 'voided' WHERE stripe_intent_id = $1", intentID)
+	return err
+}
+
+// writeLifecycleError maps lifecycle errors to the ERR_* JSON shape used by CarHandler.UpdateCar.
+func writeLifecycleError(w http.ResponseWriter, err error) {
+	var te *models.TransitionError
+	switch {
+	case errors.As(err, &te):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_INVALID_TRANSITION", fmt.Sprintf("Booking cannot go from %s to %s.", te.From, te.To))
+	case errors.Is(err, errBookingNotFound), errors.Is(err, branch.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	case errors.Is(err, errPaymentNotAuthorized):
+		writeErrorCode(w, http.StatusConflict, "ERR_PAYMENT_NOT_AUTHORIZED", err.Error())
+	case errors.Is(err, errCarNotReady):
+		writeErrorCode(w, http.StatusConflict, "ERR_CAR_NOT_READY", err.Error())
+	case errors.Is(err, errNoCarAssigned):
+		writeErrorCode(w, http.StatusConflict, "ERR_CAR_NOT_ASSIGNED", err.Error())
+	case errors.Is(err, errNoShowTooEarly):
+		writeErrorCode(w, http.StatusConflict, "ERR_TOO_EARLY", err.Error())
+	case errors.Is(err, errOdometerBehind), errors.Is(err, pricing.ErrOdometerBackwards):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_INVALID_ODOMETER", err.Error())
+	case errors.Is(err, errInvalidEnergyLevel):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_INVALID_ENERGY_LEVEL", err.Error())
+	case errors.Is(err, errDamageWithInspection):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_DAMAGE_WITH_INSPECTION", err.Error())
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":  "error",
+		"code":    code,
+		"message": message,
+	})
+}
+
+func writeBookingStatus(w http.ResponseWriter, bookingID string, status models.BookingStatus) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":         "success",
+		"booking_id":     bookingID,
+		"booking_status": string(status),
+	})
+}
+
+// POST /api/bookings/{id}/confirm
+// Requires the booking's payment intent to be authorized (see HandleStripeWebhook).
+func (h *BookingHandler) ConfirmBooking(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, _, err := lockBooking(r.Context(), tx, bookingID, models.BookingStatusConfirmed); err != nil {
+			return err
+		}
+
+		var authorized bool
+		err := tx.QueryRow(r.Context(), `
+			SELECT EXISTS (SELECT 1 FROM payments WHERE booking_id = $1 AND status = 'authorized')
+		`, bookingID).Scan(&authorized)
+		if err != nil {
+			return err
+		}
+		if !authorized {
+			return errPaymentNotAuthorized
+		}
+
+		_, err = tx.Exec(r.Context(), `
+			UPDATE bookings SET status = $1, confirmed_at = NOW() WHERE id = $2
+		`, models.BookingStatusConfirmed, bookingID)
+		if err != nil {
+			return err
+		}
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingConfirmed, bookingID, "")
+	})
+
+	if err != nil {
+		writeLifecycleError(w, err)
+		return
+	}
+	writeBookingStatus(w, bookingID, models.BookingStatusConfirmed)
+}
+
+type PickupBookingRequest struct {
+	Odometer *int `json:"odometer"` // defaults to the car's last recorded reading
+	// Fuel in eighths, or charge in percent for electric cars; defaults to the last reading
+	EnergyLevel *int `json:"energy_level"`
+}
+
+// POST /api/bookings/{id}/pickup
+// Hands the car over: the booking becomes active and the car rented. The start
+// reading and the car's mileage allowance for the booked days are recorded on
+// the booking for ReturnCar to charge against, as is the fuel or charge level.
+// A class booking must have been assigned its car first (see AssignCar).
+func (h *BookingHandler) PickupBooking(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	var req PickupBookingRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		carID, _, err := lockBooking(r.Context(), tx, bookingID, models.BookingStatusActive)
+		if err != nil {
+			return err
+		}
+		if carID == "" {
+			return errNoCarAssigned
+		}
+
+		var carStatus models.CarStatus
+		var odometer, overageCentsPerKm int
+		var includedKmPerDay, energyLevel *int
+		var fuelType string
+		err = tx.QueryRow(r.Context(), `
+			SELECT status, odometer, included_km_per_day, overage_cents_per_km, fuel_type, energy_level
+			FROM cars WHERE id = $1 FOR UPDATE
+		`, carID).Scan(&carStatus, &odometer, &includedKmPerDay, &overageCentsPerKm, &fuelType, &energyLevel)
+		if err != nil {
+			return fmt.Errorf("car not found: %w", err)
+		}
+		if carStatus != models.CarStatusAvailable {
+			return fmt.Errorf("%w (status: %s)", errCarNotReady, carStatus)
+		}
+		if req.Odometer != nil {
+			if *req.Odometer < odometer {
+				return fmt.Errorf("%w (%d km)", errOdometerBehind, odometer)
+			}
+			odometer = *req.Odometer
+		}
+		if req.EnergyLevel != nil {
+			if err := pricing.ValidateLevel(fuelType, *req.EnergyLevel); err != nil {
+				return fmt.Errorf("%w: %v", errInvalidEnergyLevel, err)
+			}
+			energyLevel = req.EnergyLevel
+		}
+
+		var startTime, endTime time.Time
+		err = tx.QueryRow(r.Context(), "SELECT start_time, end_time FROM bookings WHERE id = $1", bookingID).Scan(&startTime, &endTime)
+		if err != nil {
+			return err
+		}
+		mileage := pricing.Allowance(odometer, includedKmPerDay, overageCentsPerKm, pricing.Days(startTime, endTime))
+
+		_, err = tx.Exec(r.Context(), `
+			UPDATE bookings
+			SET status = $1, picked_up_at = NOW(), start_odometer = $2, included_km = $3, overage_cents_per_km = $4,
+			    start_energy_level = $5
+			WHERE id = $6
+		`, models.BookingStatusActive, mileage.StartOdometer, mileage.IncludedKm, mileage.OverageCentsPerKm, energyLevel, bookingID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(r.Context(), `
+			UPDATE cars SET status = $1, odometer = $2, energy_level = $3, updated_at = NOW() WHERE id = $4
+		`, models.CarStatusRented, odometer, energyLevel, carID)
+		if err != nil {
+			return err
+		}
+		if err := webhooks.EmitCarStatusChanged(r.Context(), tx, carID, string(carStatus), string(models.CarStatusRented), "pickup"); err != nil {
+			return err
+		}
+		return maintenance.CheckDue(r.Context(), tx, carID, time.Now())
+	})
+
+	if err != nil {
+		writeLifecycleError(w, err)
+		return
+	}
+	writeBookingStatus(w, bookingID, models.BookingStatusActive)
+}
+
+type CancelBookingRequest struct {
+	Reason string `json:"reason"`
+}
+
+// POST /api/bookings/{id}/cancel
+// Cancels a booking that has not been picked up and voids its payment hold.
+func (h *BookingHandler) CancelBooking(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	var req CancelBookingRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, _, err := lockBooking(r.Context(), tx, bookingID, models.BookingStatusCancelled); err != nil {
+			return err
+		}
+
+		if err := voidBookingPayment(r.Context(), tx, bookingID, string(stripe.PaymentIntentCancellationReasonRequestedByCustomer)); err != nil {
+			return err
+		}
+
+		_, err := tx.Exec(r.Context(), `
+			UPDATE bookings SET status = $1, cancelled_at = NOW(), cancellation_reason = $2 WHERE id = $3
+		`, models.BookingStatusCancelled, req.Reason, bookingID)
+		if err != nil {
+			return err
+		}
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCancelled, bookingID, "")
+	})
+
+	if err != nil {
+		writeLifecycleError(w, err)
+		return
+	}
+	writeBookingStatus(w, bookingID, models.BookingStatusCancelled)
+}
+
+// POST /api/bookings/{id}/no-show
+// Closes a confirmed booking whose customer never turned up and releases the hold.
+func (h *BookingHandler) MarkNoShow(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		_, startTime, err := lockBooking(r.Context(), tx, bookingID, models.BookingStatusNoShow)
+		if err != nil {
+			return err
+		}
+		if time.Now().Before(startTime) {
+			return errNoShowTooEarly
+		}
+
+		if err := voidBookingPayment(r.Context(), tx, bookingID, string(stripe.PaymentIntentCancellationReasonAbandoned)); err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(r.Context(), `
+			UPDATE bookings SET status = $1, cancelled_at = NOW(), cancellation_reason = 'no_show' WHERE id = $2
+		`, models.BookingStatusNoShow, bookingID)
+		if err != nil {
+			return err
+		}
+		// No-shows are reported as cancellations with status no_show
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCancelled, bookingID, "")
+	})
+
+	if err != nil {
+		writeLifecycleError(w, err)
+		return
+	}
+	writeBookingStatus(w, bookingID, models.BookingStatusNoShow)
+}
+
+type AssignCarRequest struct {
+	// The car to give the booking; without one the best fit of its class is chosen
+	CarID string `json:"car_id"`
+}
+
+// POST /api/bookings/{id}/assign
+// Attaches a car to a pending or confirmed booking before pickup. A class
+// booking takes a car of its class; a booking that already has a car is moved
+// to the new one, keeping its price. The car must be free, at the pickup
+// branch, and not needed elsewhere after drop-off.
+func (h *BookingHandler) AssignCar(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	var req AssignCarRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	carID := req.CarID
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var status models.BookingStatus
+		var currentCarID, classID *string
+		var start, end time.Time
+		var route branch.Route
+		err := tx.QueryRow(r.Context(), `
+			SELECT status, car_id, class_id, start_time, end_time, pickup_branch_id, dropoff_branch_id
+			FROM bookings WHERE id = $1 FOR UPDATE
+		`, bookingID).Scan(&status, &currentCarID, &classID, &start, &end, &route.PickupBranchID, &route.DropoffBranchID)
+		if err == pgx.ErrNoRows {
+			return errBookingNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if status != models.BookingStatusPending && status != models.BookingStatusConfirmed {
+			return fmt.Errorf("%w (booking %s)", errAssignAfterPickup, status)
+		}
+
+		if carID == "" {
+			if classID == nil {
+				return errClassRequired
+			}
+			cands, err := vehicleclass.Candidates(r.Context(), tx, *classID, start, end, route.PickupBranchID)
+			if err != nil {
+				return err
+			}
+			var ok bool
+			if carID, ok = vehicleclass.BestFit(cands, start, end, route.DropoffBranchID); !ok {
+				return vehicleclass.ErrNoCarFits
+			}
+		} else if classID != nil {
+			var inClass bool
+			err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM cars WHERE id = $1 AND class_id = $2)", carID, *classID).Scan(&inClass)
+			if err != nil {
+				return err
+			}
+			if !inClass {
+				return vehicleclass.ErrCarNotInClass
+			}
+		}
+		if currentCarID != nil && *currentCarID == carID {
+			return nil
+		}
+
+		if err := availability.AssignCar(r.Context(), tx, carID, bookingID, start, end); err != nil {
+			return err
+		}
+		if route, err = branch.Resolve(r.Context(), tx, carID, start, end, route); err != nil {
+			return err
+		}
+		_, err = tx.Exec(r.Context(), `
+			UPDATE bookings SET car_id = $1, pickup_branch_id = $2, dropoff_branch_id = $3 WHERE id = $4
+		`, carID, route.PickupBranchID, route.DropoffBranchID, bookingID)
+		if availability.IsConflict(err) {
+			return availability.ErrUnavailable
+		}
+		if err != nil {
+			return err
+		}
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCarAssigned, bookingID, "")
+	})
+
+	if err != nil {
+		writeAssignError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"status":     "success",
+		"booking_id": bookingID,
+		"car_id":     carID,
+	})
+}
+
+func writeAssignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errAssignAfterPickup):
+		writeErrorCode(w, http.StatusConflict, "ERR_INVALID_STATE", err.Error())
+	case errors.Is(err, errClassRequired):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_CAR_REQUIRED", err.Error())
+	case errors.Is(err, vehicleclass.ErrCarNotInClass):
+		writeErrorCode(w, http.StatusConflict, "ERR_WRONG_CLASS", err.Error())
+	case errors.Is(err, vehicleclass.ErrNoCarFits):
+		writeErrorCode(w, http.StatusConflict, "ERR_NO_CAR_FITS", err.Error())
+	case errors.Is(err, availability.ErrCarNotFound):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	case errors.Is(err, availability.ErrUnavailable), errors.Is(err, availability.ErrClassFull),
+		errors.Is(err, availability.ErrNonCompliant),
+		errors.Is(err, branch.ErrWrongBranch), errors.Is(err, branch.ErrNeededElsewhere):
+		writeErrorCode(w, http.StatusConflict, "ERR_CAR_UNAVAILABLE", err.Error())
+	case errors.Is(err, branch.ErrClosed):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_BRANCH_CLOSED", err.Error())
+	default:
+		writeLifecycleError(w, err)
+	}
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var errBranchInUse = errors.New("branch still has cars or upcoming bookings; deactivate it instead")
+
+type BranchHandler struct{}
+
+func NewBranchHandler() *BranchHandler {
+	return &BranchHandler{}
+}
+
+// GET /api/branches
+// Every branch, inactive ones included.
+func (h *BranchHandler) ListBranches(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	branches := []branch.Branch{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), "SELECT "+branch.Columns+" FROM branches ORDER BY active DESC, name")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var b branch.Branch
+			if err := branch.Scan(rows, &b); err != nil {
+				return err
+			}
+			branches = append(branches, b)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list branches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   branches,
+	})
+}
+
+// POST /api/branches
+func (h *BranchHandler) CreateBranch(w http.ResponseWriter, r *http.Request) {
+	h.saveBranch(w, r, "")
+}
+
+// PUT /api/branches/{id}
+// An inactive branch is no longer offered for new bookings; existing ones keep it.
+func (h *BranchHandler) UpdateBranch(w http.ResponseWriter, r *http.Request) {
+	h.saveBranch(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *BranchHandler) saveBranch(w http.ResponseWriter, r *http.Request, id string) {
+	b := branch.Branch{Timezone: "UTC", Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&b); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if b.OpeningHours == nil {
+		b.OpeningHours = branch.OpeningHours{}
+	}
+	if err := b.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	args := []interface{}{b.Name, b.Address, b.Latitude, b.Longitude, b.Timezone, b.OpeningHours, b.Active}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if id == "" {
+			return tx.QueryRow(r.Context(), `
+				INSERT INTO branches (name, address, latitude, longitude, timezone, opening_hours, active)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING id
+			`, args...).Scan(&b.ID)
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return pgx.ErrNoRows
+		}
+		return tx.QueryRow(r.Context(), `
+			UPDATE branches SET name = $1, address = $2, latitude = $3, longitude = $4, timezone = $5,
+			       opening_hours = $6, active = $7, updated_at = NOW()
+			WHERE id = $8
+			RETURNING id
+		`, append(args, id)...).Scan(&b.ID)
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Branch not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save branch: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   b,
+	})
+}
+
+// DELETE /api/branches/{id}
+// Only a branch without cars or upcoming bookings can be deleted; past
+// bookings lose their reference to it.
+func (h *BranchHandler) DeleteBranch(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, err := uuid.Parse(id); err != nil {
+			return branch.ErrNotFound
+		}
+		var inUse bool
+		err := tx.QueryRow(r.Context(), `
+			SELECT EXISTS (SELECT 1 FROM cars WHERE branch_id = $1)
+			    OR EXISTS (SELECT 1 FROM bookings WHERE status = ANY($2) AND (pickup_branch_id = $1 OR dropoff_branch_id = $1))
+		`, id, availability.BlockingStatuses).Scan(&inUse)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return errBranchInUse
+		}
+		tag, err := tx.Exec(r.Context(), "DELETE FROM branches WHERE id = $1", id)
+		if err == nil && tag.RowsAffected() == 0 {
+			return branch.ErrNotFound
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, branch.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errBranchInUse):
+		writeErrorCode(w, http.StatusConflict, "ERR_BRANCH_IN_USE", err.Error())
+	case err != nil:
+		http.Error(w, "Failed to delete branch: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GET /api/one-way-fees
+func (h *BranchHandler) ListFees(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	fees := []branch.Fee{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT id, from_branch_id, to_branch_id, fee_cents FROM one_way_fees
+			ORDER BY from_branch_id, to_branch_id
+		`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var f branch.Fee
+			if err := rows.Scan(&f.ID, &f.FromBranchID, &f.ToBranchID, &f.FeeCents); err != nil {
+				return err
+			}
+			fees = append(fees, f)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list one-way fees: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   fees,
+	})
+}
+
+// PUT /api/one-way-fees
+// Sets the fee from one branch to another, offering that one-way route. The
+// reverse direction is priced separately.
+func (h *BranchHandler) SetFee(w http.ResponseWriter, r *http.Request) {
+	var f branch.Fee
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(f.FromBranchID); err != nil {
+		http.Error(w, "Invalid from_branch_id", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(f.ToBranchID); err != nil {
+		http.Error(w, "Invalid to_branch_id", http.StatusBadRequest)
+		return
+	}
+	if err := f.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(r.Context(), `
+			INSERT INTO one_way_fees (from_branch_id, to_branch_id, fee_cents)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (from_branch_id, to_branch_id) DO UPDATE SET fee_cents = EXCLUDED.fee_cents, updated_at = NOW()
+			RETURNING id
+		`, f.FromBranchID, f.ToBranchID, f.FeeCents).Scan(&f.ID)
+	})
+	if isForeignKeyViolation(err) {
+		http.Error(w, branch.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save one-way fee: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   f,
+	})
+}
+
+// DELETE /api/one-way-fees/{id}
+// Stops offering the route; bookings already made keep their fee.
+func (h *BranchHandler) DeleteFee(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(id); err != nil {
+		http.Error(w, "One-way fee not found", http.StatusNotFound)
+		return
+	}
+	var deleted int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), "DELETE FROM one_way_fees WHERE id = $1", id)
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete one-way fee: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "One-way fee not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,13 +2,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+
+	"rental-saas/internal/auth"
 	"rental-saas/internal/models"
+	"rental-saas/internal/pricing"
 	"rental-saas/internal/repository"
 	"rental-saas/internal/storage"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type CarHandler struct {
@@ -33,6 +39,31 @@ func (h *CarHandler) CreateCar(w http.ResponseWriter, r *http.Request) {
 	licensePlate := r.FormValue("license_plate")
 	status := r.FormValue("status")
 
+	var dailyRateCents int
+	if rate := r.FormValue("daily_rate_cents"); rate != "" {
+		dailyRateCents, err = strconv.Atoi(rate)
+		if err != nil || dailyRateCents <= 0 {
+			http.Error(w, "daily_rate_cents must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		if !auth.HasPermission(r.Context(), auth.PermManagePricing) {
+			writeErrorCode(w, http.StatusForbidden, "ERR_FORBIDDEN", "Only managers can set daily_rate_cents.")
+			return
+		}
+	}
+
+	car := &models.Car{FuelType: r.FormValue("fuel_type")}
+	if car.FuelType != "" && !pricing.ValidFuelType(car.FuelType) {
+		http.Error(w, "fuel_type must be petrol, diesel, hybrid or electric", http.StatusBadRequest)
+		return
+	}
+	if !applyMileageTerms(w, r, car) {
+		return
+	}
+	if !applyBranch(w, r, car) || !applyClass(w, r, car) {
+		return
+	}
+
 	var imageURL string
 	file, header, err := r.FormFile("image")
 	if err == nil {
@@ -47,15 +78,17 @@ func (h *CarHandler) CreateCar(w http.ResponseWriter, r *http.Request) {
 		imageURL = url
 	}
 
-	car := &models.Car{
-		Make:         make,
-		Model:        model,
-		LicensePlate: licensePlate,
-		Status:       models.CarStatus(status),
-		ImageURL:     imageURL,
-	}
+	car.Make = make
+	car.Model = model
+	car.LicensePlate = licensePlate
+	car.Status = models.CarStatus(status)
+	car.DailyRateCents = dailyRateCents
+	car.ImageURL = imageURL
 
-	if err := h.Repo.Create(r.Context(), car); err != nil {
+	if err := h.Repo.Create(r.Context(), car); isForeignKeyViolation(err) {
+		http.Error(w, "branch_id or class_id does not exist", http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to create car: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -129,6 +162,35 @@ func (h *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request) {
 	if newStatus != "" {
 		currentCar.Status = newStatus
 	}
+	if fuelType := r.FormValue("fuel_type"); fuelType != "" {
+		if !pricing.ValidFuelType(fuelType) {
+			http.Error(w, "fuel_type must be petrol, diesel, hybrid or electric", http.StatusBadRequest)
+			return
+		}
+		// The last reading is on the old fuel's scale; the repository clears it
+		if fuelType != currentCar.FuelType {
+			currentCar.EnergyLevel = nil
+		}
+		currentCar.FuelType = fuelType
+	}
+	if rate := r.FormValue("daily_rate_cents"); rate != "" {
+		if !auth.HasPermission(r.Context(), auth.PermManagePricing) {
+			writeErrorCode(w, http.StatusForbidden, "ERR_FORBIDDEN", "Only managers can change daily_rate_cents.")
+			return
+		}
+		cents, err := strconv.Atoi(rate)
+		if err != nil || cents <= 0 {
+			http.Error(w, "daily_rate_cents must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		currentCar.DailyRateCents = cents
+	}
+	if !applyMileageTerms(w, r, currentCar) {
+		return
+	}
+	if !applyBranch(w, r, currentCar) || !applyClass(w, r, currentCar) {
+		return
+	}
 
 	// Handle Image Upload
 	file, header, err := r.FormFile("image")
@@ -143,7 +205,10 @@ func (h *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request) {
 		currentCar.ImageURL = url
 	}
 
-	if err := h.Repo.Update(r.Context(), currentCar); err != nil {
+	if err := h.Repo.Update(r.Context(), currentCar); isForeignKeyViolation(err) {
+		http.Error(w, "branch_id or class_id does not exist", http.StatusBadRequest)
+		return
+	} else if err != nil {
 		http.Error(w, "Failed to update car: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
@@ -154,3 +219,80 @@ func (h *CarHandler) UpdateCar(w http.ResponseWriter, r *http.Request) {
 		"data":   currentCar,
 	})
 }
+
+// applyMileageTerms sets the car's mileage allowance from the form, if given:
+// included_km_per_day ("unlimited" removes the limit) and overage_cents_per_km.
+// Like the daily rate they are prices, so only managers may set them.
+// It writes the error response and returns false when the input is rejected.
+func applyMileageTerms(w http.ResponseWriter, r *http.Request, car *models.Car) bool {
+	included := r.FormValue("included_km_per_day")
+	overage := r.FormValue("overage_cents_per_km")
+	if included == "" && overage == "" {
+		return true
+	}
+	if !auth.HasPermission(r.Context(), auth.PermManagePricing) {
+		writeErrorCode(w, http.StatusForbidden, "ERR_FORBIDDEN", "Only managers can change mileage terms.")
+		return false
+	}
+
+	switch included {
+	case "":
+	case "unlimited":
+		car.IncludedKmPerDay = nil
+	default:
+		km, err := strconv.Atoi(included)
+		if err != nil || km < 0 {
+			http.Error(w, "included_km_per_day must be a non-negative integer or \"unlimited\"", http.StatusBadRequest)
+			return false
+		}
+		car.IncludedKmPerDay = &km
+	}
+	if overage != "" {
+		cents, err := strconv.Atoi(overage)
+		if err != nil || cents < 0 {
+			http.Error(w, "overage_cents_per_km must be a non-negative integer", http.StatusBadRequest)
+			return false
+		}
+		car.OverageCentsPerKm = cents
+	}
+	return true
+}
+
+// applyBranch moves the car to the form's branch_id, if given ("none" removes
+// it from its branch). Bookings already made keep their pickup branch.
+func applyBranch(w http.ResponseWriter, r *http.Request, car *models.Car) bool {
+	switch id := r.FormValue("branch_id"); id {
+	case "":
+	case "none":
+		car.BranchID = nil
+	default:
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, "Invalid branch_id", http.StatusBadRequest)
+			return false
+		}
+		car.BranchID = &id
+	}
+	return true
+}
+
+// applyClass puts the car in the form's class_id, if given ("none" takes it
+// out of its class). Class bookings already assigned the car keep it.
+func applyClass(w http.ResponseWriter, r *http.Request, car *models.Car) bool {
+	switch id := r.FormValue("class_id"); id {
+	case "":
+	case "none":
+		car.ClassID = nil
+	default:
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, "Invalid class_id", http.StatusBadRequest)
+			return false
+		}
+		car.ClassID = &id
+	}
+	return true
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23503"
+}
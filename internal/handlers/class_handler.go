@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"rental-saas/internal/auth"
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/vehicleclass"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errClassInUse    = errors.New("vehicle class still has cars or bookings; deactivate it instead")
+	errRateForbidden = errors.New("only managers can set daily_rate_cents")
+)
+
+type ClassHandler struct{}
+
+func NewClassHandler() *ClassHandler {
+	return &ClassHandler{}
+}
+
+// ClassWithCars is a class and how many cars are in it.
+type ClassWithCars struct {
+	vehicleclass.Class
+	CarCount int `json:"car_count"`
+}
+
+// loadClasses returns the tenant's classes by name, active ones first.
+func loadClasses(r *http.Request, tx pgx.Tx, activeOnly bool) ([]vehicleclass.Class, error) {
+	query := "SELECT " + vehicleclass.Columns + " FROM vehicle_classes"
+	if activeOnly {
+		query += " WHERE active"
+	}
+	rows, err := tx.Query(r.Context(), query+" ORDER BY active DESC, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	classes := []vehicleclass.Class{}
+	for rows.Next() {
+		var c vehicleclass.Class
+		if err := vehicleclass.Scan(rows, &c); err != nil {
+			return nil, err
+		}
+		classes = append(classes, c)
+	}
+	return classes, rows.Err()
+}
+
+// GET /api/classes
+// Every class, inactive ones included.
+func (h *ClassHandler) ListClasses(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	result := []ClassWithCars{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		classes, err := loadClasses(r, tx, false)
+		if err != nil {
+			return err
+		}
+		for _, c := range classes {
+			cc := ClassWithCars{Class: c}
+			if err := tx.QueryRow(r.Context(), "SELECT COUNT(*) FROM cars WHERE class_id = $1", c.ID).Scan(&cc.CarCount); err != nil {
+				return err
+			}
+			result = append(result, cc)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to list classes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// ClassAvailability is a class's capacity over the requested range.
+type ClassAvailability struct {
+	vehicleclass.Class
+	vehicleclass.Availability
+}
+
+// GET /api/classes/availability?start=...&end=... (RFC 3339)[&branch_id=...]
+// For each active class: its cars free for the whole range (at branch_id, if
+// given), the class bookings in the range still waiting for a car, and how
+// many more bookings it can take.
+func (h *ClassHandler) GetClassAvailability(w http.ResponseWriter, r *http.Request) {
+	start, err1 := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	end, err2 := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err1 != nil || err2 != nil {
+		http.Error(w, "start and end must be RFC 3339 timestamps", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var pickupBranchID *string
+	if id := r.URL.Query().Get("branch_id"); id != "" {
+		pickupBranchID = &id
+	}
+
+	result := []ClassAvailability{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if err := availability.ValidateRange(start, end); err != nil {
+			return err
+		}
+		if pickupBranchID != nil {
+			if _, err := branch.Load(r.Context(), tx, *pickupBranchID); err != nil {
+				return err
+			}
+		}
+		classes, err := loadClasses(r, tx, true)
+		if err != nil {
+			return err
+		}
+		for _, c := range classes {
+			a, err := vehicleclass.Check(r.Context(), tx, c.ID, start, end, pickupBranchID)
+			if err != nil {
+				return err
+			}
+			result = append(result, ClassAvailability{Class: c, Availability: a})
+		}
+		return nil
+	})
+	if err != nil {
+		writeAvailabilityError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// POST /api/classes
+func (h *ClassHandler) CreateClass(w http.ResponseWriter, r *http.Request) {
+	h.saveClass(w, r, "")
+}
+
+// PUT /api/classes/{id}
+// An inactive class is no longer offered for new bookings; existing ones keep it.
+func (h *ClassHandler) UpdateClass(w http.ResponseWriter, r *http.Request) {
+	h.saveClass(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *ClassHandler) saveClass(w http.ResponseWriter, r *http.Request, id string) {
+	c := vehicleclass.Class{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	canPrice := auth.HasPermission(r.Context(), auth.PermManagePricing)
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if id == "" {
+			if !canPrice {
+				return errRateForbidden
+			}
+			return tx.QueryRow(r.Context(), `
+				INSERT INTO vehicle_classes (name, description, daily_rate_cents, seats, image_url, active)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id
+			`, c.Name, c.Description, c.DailyRateCents, c.Seats, c.ImageURL, c.Active).Scan(&c.ID)
+		}
+
+		if _, err := uuid.Parse(id); err != nil {
+			return pgx.ErrNoRows
+		}
+		var rate int
+		if err := tx.QueryRow(r.Context(), "SELECT daily_rate_cents FROM vehicle_classes WHERE id = $1 FOR UPDATE", id).Scan(&rate); err != nil {
+			return err
+		}
+		if rate != c.DailyRateCents && !canPrice {
+			return errRateForbidden
+		}
+		return tx.QueryRow(r.Context(), `
+			UPDATE vehicle_classes SET name = $1, description = $2, daily_rate_cents = $3, seats = $4, image_url = $5,
+			       active = $6, updated_at = NOW()
+			WHERE id = $7
+			RETURNING id
+		`, c.Name, c.Description, c.DailyRateCents, c.Seats, c.ImageURL, c.Active, id).Scan(&c.ID)
+	})
+	switch {
+	case err == pgx.ErrNoRows:
+		http.Error(w, "Vehicle class not found", http.StatusNotFound)
+		return
+	case errors.Is(err, errRateForbidden):
+		writeErrorCode(w, http.StatusForbidden, "ERR_FORBIDDEN", "Only managers can set daily_rate_cents.")
+		return
+	case isUniqueViolation(err):
+		http.Error(w, "A vehicle class with that name already exists", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to save vehicle class: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   c,
+	})
+}
+
+// DELETE /api/classes/{id}
+// Only a class no car and no booking refers to can be deleted.
+func (h *ClassHandler) DeleteClass(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, err := uuid.Parse(id); err != nil {
+			return vehicleclass.ErrNotFound
+		}
+		var inUse bool
+		err := tx.QueryRow(r.Context(), `
+			SELECT EXISTS (SELECT 1 FROM cars WHERE class_id = $1)
+			    OR EXISTS (SELECT 1 FROM bookings WHERE class_id = $1)
+		`, id).Scan(&inUse)
+		if err != nil {
+			return err
+		}
+		if inUse {
+			return errClassInUse
+		}
+		tag, err := tx.Exec(r.Context(), "DELETE FROM vehicle_classes WHERE id = $1", id)
+		if err == nil && tag.RowsAffected() == 0 {
+			return vehicleclass.ErrNotFound
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, vehicleclass.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errClassInUse):
+		writeErrorCode(w, http.StatusConflict, "ERR_CLASS_IN_USE", err.Error())
+	case err != nil:
+		http.Error(w, "Failed to delete vehicle class: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,464 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/models"
+	"rental-saas/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	errCustomerNotFound = errors.New("customer not found")
+	errCustomerInUse    = errors.New("customer has bookings and cannot be deleted; merge it instead")
+	errDuplicateEmail   = errors.New("another customer already uses this email")
+)
+
+type CustomerHandler struct{}
+
+func NewCustomerHandler() *CustomerHandler {
+	return &CustomerHandler{}
+}
+
+type CustomerRequest struct {
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Email           string `json:"email"`
+	Phone           string `json:"phone"`
+	DriverLicenseID string `json:"driver_license_id"`
+}
+
+func (req *CustomerRequest) normalize() error {
+	req.FirstName = strings.TrimSpace(req.FirstName)
+	req.LastName = strings.TrimSpace(req.LastName)
+	req.Email = strings.ToLower(strings.TrimSpace(req.Email))
+	req.Phone = strings.TrimSpace(req.Phone)
+	req.DriverLicenseID = strings.TrimSpace(req.DriverLicenseID)
+
+	if req.FirstName == "" || !strings.Contains(req.Email, "@") {
+		return fmt.Errorf("first_name and a valid email are required")
+	}
+	return nil
+}
+
+// splitName turns the single "full name" field collected by the widget into
+// first and last name: everything up to the last space is the first name.
+func splitName(full string) (string, string) {
+	full = strings.Join(strings.Fields(full), " ")
+	if i := strings.LastIndex(full, " "); i > 0 {
+		return full[:i], full[i+1:]
+	}
+	return full, ""
+}
+
+const customerColumns = `id, first_name, last_name, email, COALESCE(phone, ''), COALESCE(driver_license_id, ''), created_at, COALESCE(updated_at, created_at)`
+
+func scanCustomer(row pgx.Row, c *models.Customer) error {
+	return row.Scan(&c.ID, &c.FirstName, &c.LastName, &c.Email, &c.Phone, &c.DriverLicenseID, &c.CreatedAt, &c.UpdatedAt)
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func writeCustomerError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errCustomerNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errCustomerInUse), errors.Is(err, errDuplicateEmail):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GET /api/customers?q=...&limit=...&offset=...
+// q matches name, email, phone and driver licence, case-insensitively.
+func (h *CustomerHandler) ListCustomers(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	// Escape LIKE wildcards so the search term is matched literally
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	pattern := "%" + strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+
+	customers := []models.Customer{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT `+customerColumns+`
+			FROM customers
+			WHERE $1 = ''
+			   OR first_name || ' ' || last_name ILIKE $2
+			   OR email ILIKE $2
+			   OR phone ILIKE $2
+			   OR driver_license_id ILIKE $2
+			ORDER BY last_name, first_name
+			LIMIT $3 OFFSET $4
+		`, q, pattern, limit, offset)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var c models.Customer
+			if err := scanCustomer(rows, &c); err != nil {
+				return err
+			}
+			customers = append(customers, c)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		http.Error(w, "Failed to list customers: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   customers,
+	})
+}
+
+// POST /api/customers
+func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+	var req CustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		err := scanCustomer(tx.QueryRow(r.Context(), `
+			INSERT INTO customers (first_name, last_name, email, phone, driver_license_id)
+			VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''))
+			RETURNING `+customerColumns,
+			req.FirstName, req.LastName, req.Email, req.Phone, req.DriverLicenseID), &customer)
+		if isUniqueViolation(err) {
+			return errDuplicateEmail
+		}
+		if err != nil {
+			return err
+		}
+		return webhooks.EmitCustomerCreated(r.Context(), tx, customer.ID, "dashboard")
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   customer,
+	})
+}
+
+// GET /api/customers/{id}
+func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		err := scanCustomer(tx.QueryRow(r.Context(), `SELECT `+customerColumns+` FROM customers WHERE id = $1`, customerID), &customer)
+		if err == pgx.ErrNoRows {
+			return errCustomerNotFound
+		}
+		return err
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   customer,
+	})
+}
+
+// PUT /api/customers/{id}
+func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+	var req CustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := req.normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		err := scanCustomer(tx.QueryRow(r.Context(), `
+			UPDATE customers
+			SET first_name = $1, last_name = $2, email = $3, phone = NULLIF($4, ''),
+			    driver_license_id = NULLIF($5, ''), updated_at = NOW()
+			WHERE id = $6
+			RETURNING `+customerColumns,
+			req.FirstName, req.LastName, req.Email, req.Phone, req.DriverLicenseID, customerID), &customer)
+		switch {
+		case err == pgx.ErrNoRows:
+			return errCustomerNotFound
+		case isUniqueViolation(err):
+			return errDuplicateEmail
+		}
+		return err
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   customer,
+	})
+}
+
+// DELETE /api/customers/{id}
+// Only customers without bookings can be deleted; duplicates should be merged.
+func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var hasBookings bool
+		err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM bookings WHERE customer_id = $1)", customerID).Scan(&hasBookings)
+		if err != nil {
+			return err
+		}
+		if hasBookings {
+			return errCustomerInUse
+		}
+
+		tag, err := tx.Exec(r.Context(), "DELETE FROM customers WHERE id = $1", customerID)
+		if err != nil {
+			return err
+		}
+		if tag.RowsAffected() == 0 {
+			return errCustomerNotFound
+		}
+		return nil
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type CustomerBooking struct {
+	ID               string    `json:"id"`
+	CarID            *string   `json:"car_id"` // nil until a class booking is assigned a car
+	ClassID          *string   `json:"class_id"`
+	CarMake          string    `json:"car_make"`
+	CarModel         string    `json:"car_model"`
+	LicensePlate     string    `json:"license_plate"`
+	StartTime        time.Time `json:"start_time"`
+	EndTime          time.Time `json:"end_time"`
+	Status           string    `json:"status"`
+	TotalAmountCents int       `json:"total_amount_cents"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// GET /api/customers/{id}/bookings
+func (h *CustomerHandler) GetCustomerBookings(w http.ResponseWriter, r *http.Request) {
+	customerID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	bookings := []CustomerBooking{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var exists bool
+		if err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM customers WHERE id = $1)", customerID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return errCustomerNotFound
+		}
+
+		rows, err := tx.Query(r.Context(), `
+			SELECT b.id, b.car_id, b.class_id, COALESCE(c.make, ''), COALESCE(c.model, ''), COALESCE(c.license_plate, ''),
+			       b.start_time, b.end_time, b.status, b.total_amount_cents, b.created_at
+			FROM bookings b
+			LEFT JOIN cars c ON b.car_id = c.id
+			WHERE b.customer_id = $1
+			ORDER BY b.start_time DESC
+		`, customerID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var b CustomerBooking
+			if err := rows.Scan(&b.ID, &b.CarID, &b.ClassID, &b.CarMake, &b.CarModel, &b.LicensePlate, &b.StartTime, &b.EndTime,
+				&b.Status, &b.TotalAmountCents, &b.CreatedAt); err != nil {
+				return err
+			}
+			bookings = append(bookings, b)
+		}
+		return rows.Err()
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   bookings,
+	})
+}
+
+type MergeCustomerRequest struct {
+	DuplicateID string `json:"duplicate_id"`
+}
+
+// POST /api/customers/{id}/merge
+// Folds duplicate_id into {id}: bookings are re-pointed (payments follow via
+// their booking), missing contact details are copied over and the duplicate
+// record is deleted.
+func (h *CustomerHandler) MergeCustomers(w http.ResponseWriter, r *http.Request) {
+	survivorID := chi.URLParam(r, "id")
+	var req MergeCustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.DuplicateID == "" || req.DuplicateID == survivorID {
+		http.Error(w, "duplicate_id must reference a different customer", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var customer models.Customer
+	var moved int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		// Lock both rows in a stable order so concurrent merges cannot deadlock
+		rows, err := tx.Query(r.Context(), "SELECT id FROM customers WHERE id IN ($1, $2) ORDER BY id FOR UPDATE", survivorID, req.DuplicateID)
+		if err != nil {
+			return err
+		}
+		locked := 0
+		for rows.Next() {
+			locked++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if locked != 2 {
+			return errCustomerNotFound
+		}
+
+		tag, err := tx.Exec(r.Context(), "UPDATE bookings SET customer_id = $1 WHERE customer_id = $2", survivorID, req.DuplicateID)
+		if err != nil {
+			return err
+		}
+		moved = tag.RowsAffected()
+
+		_, err = tx.Exec(r.Context(), `
+			UPDATE customers s
+			SET phone = COALESCE(s.phone, d.phone),
+			    driver_license_id = COALESCE(s.driver_license_id, d.driver_license_id),
+			    updated_at = NOW()
+			FROM customers d
+			WHERE s.id = $1 AND d.id = $2
+		`, survivorID, req.DuplicateID)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(r.Context(), "DELETE FROM customers WHERE id = $1", req.DuplicateID); err != nil {
+			return err
+		}
+
+		return scanCustomer(tx.QueryRow(r.Context(), `SELECT `+customerColumns+` FROM customers WHERE id = $1`, survivorID), &customer)
+	})
+
+	if err != nil {
+		writeCustomerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "success",
+		"data":           customer,
+		"bookings_moved": moved,
+	})
+}
@@ -0,0 +1,20 @@
+package handlers
+
+import "testing"
+
+func TestSplitName(t *testing.T) {
+	cases := []struct {
+		in, first, last string
+	}{
+		{"Jane Doe", "Jane", "Doe"},
+		{"  Mary   Ann  Smith ", "Mary Ann", "Smith"},
+		{"Cher", "Cher", ""},
+		{"", "", ""},
+	}
+	for _, tc := range cases {
+		first, last := splitName(tc.in)
+		if first != tc.first || last != tc.last {
+			t.Errorf("splitName(%q) = %q, %q; want %q, %q", tc.in, first, last, tc.first, tc.last)
+		}
+	}
+}
@@ -44,11 +44,11 @@ func (h *DashboardHandler) GetDashboardStats(w http.ResponseWriter, r *http.Requ
 	}
 
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		// 1. Revenue (This Month)
+		// 1. Revenue (This Month), net of refunds
 		err := tx.QueryRow(r.Context(), `
-			SELECT COALESCE(SUM(amount_cents), 0) 
+			SELECT COALESCE(SUM(amount_cents - refunded_cents), 0) 
 			FROM payments 
-			WHERE status = 'captured' 
+			WHERE status IN ('captured', 'partially_refunded', 'refunded') 
 			AND created_at >= DATE_TRUNC('month', CURRENT_DATE)
 		`).Scan(&stats.RevenueCents)
 		if err != nil {
@@ -73,9 +73,10 @@ func (h *DashboardHandler) GetDashboardStats(w http.ResponseWriter, r *http.Requ
 
 		// 3. Recent Activity
 		rows, err := tx.Query(r.Context(), `
-			SELECT b.id, c.make, c.model, b.status, b.created_at 
+			SELECT b.id, COALESCE(c.make, vc.name, ''), COALESCE(c.model, ''), b.status, b.created_at 
 			FROM bookings b
-			JOIN cars c ON b.car_id = c.id
+			LEFT JOIN cars c ON b.car_id = c.id
+			LEFT JOIN vehicle_classes vc ON b.class_id = vc.id
 			ORDER BY b.created_at DESC 
 			LIMIT 5
 		`)
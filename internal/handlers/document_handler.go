@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"rental-saas/internal/auth"
+	"rental-saas/internal/availability"
+	"rental-saas/internal/compliance"
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var errDocumentNotFound = errors.New("document not found")
+
+// DocumentHandler keeps the compliance documents of each car. Files are stored
+// under unguessable names in the storage directory the bucket's public policy
+// excludes, and only served through DownloadDocument.
+type DocumentHandler struct {
+	Storage *storage.MinioClient
+}
+
+func NewDocumentHandler(storage *storage.MinioClient) *DocumentHandler {
+	return &DocumentHandler{Storage: storage}
+}
+
+type UpdateDocumentRequest struct {
+	Kind      string        `json:"kind"`
+	Title     string        `json:"title"`
+	IssuedOn  *pricing.Date `json:"issued_on"`
+	ExpiresOn *pricing.Date `json:"expires_on"`
+	Mandatory bool          `json:"mandatory"`
+}
+
+// GET /api/cars/{id}/documents
+func (h *DocumentHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	carID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(carID); err != nil {
+		http.Error(w, "Invalid car ID", http.StatusBadRequest)
+		return
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	docs := []compliance.Document{}
+	now := time.Now()
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT `+compliance.Columns+` FROM car_documents
+			WHERE car_id = $1
+			ORDER BY kind, expires_on DESC NULLS FIRST
+		`, carID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var d compliance.Document
+			if err := compliance.Scan(rows, &d); err != nil {
+				return err
+			}
+			d.SetExpiry(now)
+			docs = append(docs, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list documents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   docs,
+	})
+}
+
+// POST /api/cars/{id}/documents (multipart)
+// Fields: file, kind, title, issued_on and expires_on (YYYY-MM-DD), mandatory.
+// Registration, insurance and inspection certificates are mandatory unless
+// mandatory=false is sent.
+func (h *DocumentHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	carID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(carID); err != nil {
+		http.Error(w, "Invalid car ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseMultipartForm(20 << 20); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "file is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	doc := compliance.Document{
+		CarID:       carID,
+		Kind:        r.FormValue("kind"),
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Filename:    filepath.Base(header.Filename),
+		ContentType: header.Header.Get("Content-Type"),
+	}
+	if doc.ContentType == "" {
+		doc.ContentType = "application/octet-stream"
+	}
+	doc.Mandatory = compliance.MandatoryByDefault(doc.Kind)
+	if v := r.FormValue("mandatory"); v != "" {
+		if doc.Mandatory, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "mandatory must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+	for field, dst := range map[string]**pricing.Date{"issued_on": &doc.IssuedOn, "expires_on": &doc.ExpiresOn} {
+		if v := r.FormValue(field); v != "" {
+			d, err := pricing.ParseDate(v)
+			if err != nil {
+				http.Error(w, field+" must be a YYYY-MM-DD date", http.StatusBadRequest)
+				return
+			}
+			*dst = &d
+		}
+	}
+	if err := doc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if userID := auth.UserIDFromContext(r.Context()); userID != "" {
+		doc.UploadedBy = &userID
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var exists bool
+		err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM cars WHERE id = $1)", carID).Scan(&exists)
+		if err == nil && !exists {
+			return availability.ErrCarNotFound
+		}
+		return err
+	})
+	if errors.Is(err, availability.ErrCarNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to find car: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Under the private directory the bucket policy keeps from public reads
+	objectName := fmt.Sprintf("%s/%s/%s/%s%s", tenantID, storage.PrivateDir, carID, uuid.NewString(), strings.ToLower(filepath.Ext(header.Filename)))
+	if _, err := h.Storage.UploadFile(r.Context(), objectName, file, header.Size, doc.ContentType); err != nil {
+		http.Error(w, "Failed to upload document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	doc.ObjectName = objectName
+
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return compliance.Scan(tx.QueryRow(r.Context(), `
+			INSERT INTO car_documents (car_id, kind, title, object_name, filename, content_type, issued_on, expires_on, mandatory, uploaded_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING `+compliance.Columns,
+			doc.CarID, doc.Kind, doc.Title, doc.ObjectName, doc.Filename, doc.ContentType, doc.IssuedOn, doc.ExpiresOn, doc.Mandatory, doc.UploadedBy), &doc)
+	})
+	if err != nil {
+		h.Storage.DeleteFile(r.Context(), objectName)
+		http.Error(w, "Failed to save document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	doc.SetExpiry(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   doc,
+	})
+}
+
+// PUT /api/documents/{id}
+// Replaces the document's details; the file itself cannot be changed. A new
+// expiry date re-arms the expiry warnings.
+func (h *DocumentHandler) UpdateDocument(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req UpdateDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	doc := compliance.Document{Kind: req.Kind, IssuedOn: req.IssuedOn, ExpiresOn: req.ExpiresOn}
+	if err := doc.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, err := uuid.Parse(id); err != nil {
+			return errDocumentNotFound
+		}
+		err := compliance.Scan(tx.QueryRow(r.Context(), `
+			UPDATE car_documents
+			SET kind = $1, title = $2, issued_on = $3, expires_on = $4, mandatory = $5,
+			    warned_stage = CASE WHEN expires_on IS DISTINCT FROM $4 THEN NULL ELSE warned_stage END,
+			    updated_at = NOW()
+			WHERE id = $6
+			RETURNING `+compliance.Columns,
+			req.Kind, strings.TrimSpace(req.Title), req.IssuedOn, req.ExpiresOn, req.Mandatory, id), &doc)
+		if err == pgx.ErrNoRows {
+			return errDocumentNotFound
+		}
+		return err
+	})
+	if errors.Is(err, errDocumentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	doc.SetExpiry(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   doc,
+	})
+}
+
+// GET /api/documents/{id}/file
+func (h *DocumentHandler) DownloadDocument(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	doc, err := h.load(r, tenantID, chi.URLParam(r, "id"), false)
+	if errors.Is(err, errDocumentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	file, size, err := h.Storage.GetFile(r.Context(), doc.ObjectName)
+	if err != nil {
+		http.Error(w, "Failed to read document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", doc.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", doc.Filename))
+	io.Copy(w, file)
+}
+
+// DELETE /api/documents/{id}
+func (h *DocumentHandler) DeleteDocument(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+	doc, err := h.load(r, tenantID, chi.URLParam(r, "id"), true)
+	if errors.Is(err, errDocumentNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to delete document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The row is gone; a file left behind is only wasted space
+	if err := h.Storage.DeleteFile(r.Context(), doc.ObjectName); err != nil {
+		fmt.Printf("Failed to delete document file %s: %v\n", doc.ObjectName, err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// load reads a document, deleting it as well when remove is set.
+func (h *DocumentHandler) load(r *http.Request, tenantID, id string, remove bool) (compliance.Document, error) {
+	var doc compliance.Document
+	if _, err := uuid.Parse(id); err != nil {
+		return doc, errDocumentNotFound
+	}
+	query := "SELECT " + compliance.Columns + " FROM car_documents WHERE id = $1"
+	if remove {
+		query = "DELETE FROM car_documents WHERE id = $1 RETURNING " + compliance.Columns
+	}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return compliance.Scan(tx.QueryRow(r.Context(), query, id), &doc)
+	})
+	if err == pgx.ErrNoRows {
+		return doc, errDocumentNotFound
+	}
+	return doc, err
+}
+
+// GET /api/documents/expiring
+// Documents across the fleet that have expired or expire within the warning
+// window, soonest first.
+func (h *DocumentHandler) ListExpiring(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var docs []compliance.Expiring
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		docs, err = compliance.ListExpiring(r.Context(), tx, time.Now(), false)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to list expiring documents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   docs,
+	})
+}
@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/database"
+	"rental-saas/internal/extras"
+	"rental-saas/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var errExtraInUse = errors.New("extra has been booked; deactivate it instead")
+
+type ExtraHandler struct{}
+
+func NewExtraHandler() *ExtraHandler {
+	return &ExtraHandler{}
+}
+
+// loadExtras returns the tenant's extras by name, active ones first.
+func loadExtras(r *http.Request, tx pgx.Tx, activeOnly bool) ([]extras.Extra, error) {
+	query := "SELECT " + extras.Columns + " FROM extras"
+	if activeOnly {
+		query += " WHERE active"
+	}
+	rows, err := tx.Query(r.Context(), query+" ORDER BY active DESC, name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	list := []extras.Extra{}
+	for rows.Next() {
+		var e extras.Extra
+		if err := extras.Scan(rows, &e); err != nil {
+			return nil, err
+		}
+		list = append(list, e)
+	}
+	return list, rows.Err()
+}
+
+// ExtraAvailability is an extra and, over the requested range, the units
+// bookings hold and the units left (null if unlimited).
+type ExtraAvailability struct {
+	extras.Extra
+	Booked *int `json:"booked,omitempty"`
+	Left   *int `json:"left,omitempty"`
+}
+
+// GET /api/extras[?start=...&end=...] (RFC 3339)
+// Every extra, inactive ones included; with a range, how many units of each
+// are booked and left over it.
+func (h *ExtraHandler) ListExtras(w http.ResponseWriter, r *http.Request) {
+	var start, end time.Time
+	if r.URL.Query().Get("start") != "" || r.URL.Query().Get("end") != "" {
+		var err1, err2 error
+		start, err1 = time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+		end, err2 = time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+		if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+			http.Error(w, "start and end must be RFC 3339 timestamps, end after start", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	result := []ExtraAvailability{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		list, err := loadExtras(r, tx, false)
+		if err != nil {
+			return err
+		}
+		for _, e := range list {
+			ea := ExtraAvailability{Extra: e}
+			if !start.IsZero() {
+				booked, err := extras.Booked(r.Context(), tx, e.ID, start, end)
+				if err != nil {
+					return err
+				}
+				ea.Booked, ea.Left = &booked, e.Left(booked)
+			}
+			result = append(result, ea)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to list extras: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
+
+// POST /api/extras
+func (h *ExtraHandler) CreateExtra(w http.ResponseWriter, r *http.Request) {
+	h.saveExtra(w, r, "")
+}
+
+// PUT /api/extras/{id}
+// Bookings already made keep the charges they were quoted. Lowering the
+// inventory below what is booked only stops new bookings.
+func (h *ExtraHandler) UpdateExtra(w http.ResponseWriter, r *http.Request) {
+	h.saveExtra(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *ExtraHandler) saveExtra(w http.ResponseWriter, r *http.Request, id string) {
+	e := extras.Extra{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := e.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	args := []interface{}{e.Name, e.Description, e.PriceCents, e.PricePer, e.Inventory, e.Active}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if id == "" {
+			return tx.QueryRow(r.Context(), `
+				INSERT INTO extras (name, description, price_cents, price_per, inventory, active)
+				VALUES ($1, $2, $3, $4, $5, $6)
+				RETURNING id
+			`, args...).Scan(&e.ID)
+		}
+		if _, err := uuid.Parse(id); err != nil {
+			return pgx.ErrNoRows
+		}
+		return tx.QueryRow(r.Context(), `
+			UPDATE extras SET name = $1, description = $2, price_cents = $3, price_per = $4, inventory = $5,
+			       active = $6, updated_at = NOW()
+			WHERE id = $7
+			RETURNING id
+		`, append(args, id)...).Scan(&e.ID)
+	})
+	switch {
+	case err == pgx.ErrNoRows:
+		http.Error(w, extras.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	case isUniqueViolation(err):
+		http.Error(w, "An extra with that name already exists", http.StatusConflict)
+		return
+	case err != nil:
+		http.Error(w, "Failed to save extra: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   e,
+	})
+}
+
+// DELETE /api/extras/{id}
+// Only an extra no booking has taken can be deleted.
+func (h *ExtraHandler) DeleteExtra(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, err := uuid.Parse(id); err != nil {
+			return extras.ErrNotFound
+		}
+		var inUse bool
+		if err := tx.QueryRow(r.Context(), "SELECT EXISTS (SELECT 1 FROM booking_extras WHERE extra_id = $1)", id).Scan(&inUse); err != nil {
+			return err
+		}
+		if inUse {
+			return errExtraInUse
+		}
+		tag, err := tx.Exec(r.Context(), "DELETE FROM extras WHERE id = $1", id)
+		if err == nil && tag.RowsAffected() == 0 {
+			return extras.ErrNotFound
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, extras.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, errExtraInUse):
+		writeErrorCode(w, http.StatusConflict, "ERR_EXTRA_IN_USE", err.Error())
+	case err != nil:
+		http.Error(w, "Failed to delete extra: "+err.Error(), http.StatusInternalServerError)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,364 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"rental-saas/internal/auth"
+	"rental-saas/internal/database"
+	"rental-saas/internal/inspection"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/models"
+	"rental-saas/internal/storage"
+	"rental-saas/internal/tax"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jung-kurt/gofpdf"
+)
+
+var errWrongInspectionStage = errors.New("booking is not at the stage for this inspection")
+
+type InspectionHandler struct {
+	Storage *storage.MinioClient
+}
+
+func NewInspectionHandler(storage *storage.MinioClient) *InspectionHandler {
+	return &InspectionHandler{Storage: storage}
+}
+
+type CreateInspectionRequest struct {
+	Kind  string            `json:"kind"` // pickup or return
+	Notes string            `json:"notes"`
+	Items []inspection.Item `json:"items"`
+}
+
+type BookingInspectionsResponse struct {
+	Pickup *inspection.Report `json:"pickup"`
+	Return *inspection.Report `json:"return"`
+	// Estimated cost of the return damage not on the pickup report; what ReturnCar bills
+	NewDamageCostCents int `json:"new_damage_cost_cents"`
+}
+
+// POST /api/bookings/{id}/inspections
+// Records the pickup report before or at handover, and the return report while
+// the car is out. Reports cannot be changed once saved.
+func (h *InspectionHandler) CreateInspection(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	var req CreateInspectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Kind != inspection.KindPickup && req.Kind != inspection.KindReturn {
+		http.Error(w, "kind must be pickup or return", http.StatusBadRequest)
+		return
+	}
+	for i, item := range req.Items {
+		if err := item.Validate(); err != nil {
+			http.Error(w, fmt.Sprintf("items[%d]: %v", i, err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	report := inspection.Report{BookingID: bookingID, Kind: req.Kind, Notes: req.Notes, Items: req.Items}
+	if userID := auth.UserIDFromContext(r.Context()); userID != "" {
+		report.InspectedBy = &userID
+	}
+
+	var resp BookingInspectionsResponse
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var status models.BookingStatus
+		var carID *string
+		err := tx.QueryRow(r.Context(), "SELECT status, car_id FROM bookings WHERE id = $1 FOR UPDATE", bookingID).
+			Scan(&status, &carID)
+		if err == pgx.ErrNoRows {
+			return errBookingNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if carID == nil {
+			return errNoCarAssigned
+		}
+		report.CarID = *carID
+
+		switch {
+		case req.Kind == inspection.KindPickup && (status == models.BookingStatusPending || status == models.BookingStatusConfirmed || status == models.BookingStatusActive):
+		case req.Kind == inspection.KindReturn && status == models.BookingStatusActive:
+		default:
+			return fmt.Errorf("%w (%s inspection, booking %s)", errWrongInspectionStage, req.Kind, status)
+		}
+
+		if err := inspection.Create(r.Context(), tx, &report); err != nil {
+			return err
+		}
+		resp, err = loadBookingInspections(r, tx, bookingID)
+		return err
+	})
+	if err != nil {
+		writeInspectionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   resp,
+	})
+}
+
+// GET /api/bookings/{id}/inspections
+func (h *InspectionHandler) GetInspections(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var resp BookingInspectionsResponse
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		resp, err = loadBookingInspections(r, tx, bookingID)
+		return err
+	})
+	if err != nil {
+		writeInspectionError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   resp,
+	})
+}
+
+// POST /api/inspections/{id}/photos
+// Multipart upload of one "photo", optionally attached to a "damage_item_id"
+// of the same inspection.
+func (h *InspectionHandler) UploadPhoto(w http.ResponseWriter, r *http.Request) {
+	inspectionID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(inspectionID); err != nil {
+		http.Error(w, "Invalid inspection ID", http.StatusBadRequest)
+		return
+	}
+	if err := r.ParseMultipartForm(10 << 20); err != nil {
+		http.Error(w, "Unable to parse form", http.StatusBadRequest)
+		return
+	}
+	file, header, err := r.FormFile("photo")
+	if err != nil {
+		http.Error(w, "photo is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	contentType := header.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") {
+		http.Error(w, "photo must be an image", http.StatusBadRequest)
+		return
+	}
+	var itemID *string
+	if id := r.FormValue("damage_item_id"); id != "" {
+		if _, err := uuid.Parse(id); err != nil {
+			http.Error(w, "Invalid damage_item_id", http.StatusBadRequest)
+			return
+		}
+		itemID = &id
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var id string
+		if itemID != nil {
+			id = *itemID
+		}
+		return inspection.Exists(r.Context(), tx, inspectionID, id)
+	})
+	if err != nil {
+		writeInspectionError(w, err)
+		return
+	}
+
+	objectName := fmt.Sprintf("%s/inspections/%s/%s%s", tenantID, inspectionID, uuid.NewString(), strings.ToLower(filepath.Ext(header.Filename)))
+	url, err := h.Storage.UploadFile(r.Context(), objectName, file, header.Size, contentType)
+	if err != nil {
+		http.Error(w, "Failed to upload photo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var photo inspection.Photo
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		photo, err = inspection.AddPhoto(r.Context(), tx, inspectionID, itemID, url)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to save photo: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   photo,
+	})
+}
+
+// GET /api/bookings/{id}/damage-report
+// The customer-facing PDF: damage at pickup, damage at return with new items
+// marked, photo links, and the amount billed for new damage.
+func (h *InspectionHandler) DamageReport(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var firstName, lastName, carMake, carModel, plate string
+	var startTime, endTime time.Time
+	var resp BookingInspectionsResponse
+	var taxes tax.Config
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(r.Context(), `
+			SELECT c.first_name, c.last_name, car.make, car.model, car.license_plate, b.start_time, b.end_time
+			FROM bookings b
+			JOIN customers c ON b.customer_id = c.id
+			JOIN cars car ON b.car_id = car.id
+			WHERE b.id = $1
+		`, bookingID).Scan(&firstName, &lastName, &carMake, &carModel, &plate, &startTime, &endTime)
+		if err == pgx.ErrNoRows {
+			return errBookingNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if taxes, err = tax.ForBooking(r.Context(), tx, bookingID); err != nil {
+			return err
+		}
+		resp, err = loadBookingInspections(r, tx, bookingID)
+		return err
+	})
+	if err != nil {
+		writeInspectionError(w, err)
+		return
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(40, 10, "Vehicle Damage Report")
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(40, 8, fmt.Sprintf("Customer: %s %s", firstName, lastName))
+	pdf.Ln(8)
+	pdf.Cell(40, 8, fmt.Sprintf("Vehicle: %s %s (%s)", carMake, carModel, plate))
+	pdf.Ln(8)
+	pdf.Cell(40, 8, fmt.Sprintf("Rental Period: %s to %s", startTime.Format("2006-01-02"), endTime.Format("2006-01-02")))
+	pdf.Ln(12)
+
+	writeDamageSection(pdf, "At pickup", resp.Pickup, false, taxes.Currency)
+	writeDamageSection(pdf, "At return", resp.Return, true, taxes.Currency)
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(40, 10, "New damage charged: "+tax.Format(resp.NewDamageCostCents, taxes.Currency)+" before tax")
+
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=damage-report-%s.pdf", bookingID))
+	if err := pdf.Output(w); err != nil {
+		fmt.Printf("Error generating PDF: %v\n", err)
+	}
+}
+
+func writeDamageSection(pdf *gofpdf.Fpdf, title string, report *inspection.Report, markNew bool, currency string) {
+	pdf.SetFont("Arial", "B", 13)
+	pdf.Cell(40, 10, title)
+	pdf.Ln(9)
+	pdf.SetFont("Arial", "", 11)
+
+	if report == nil {
+		pdf.Cell(40, 7, "No inspection recorded.")
+		pdf.Ln(10)
+		return
+	}
+	pdf.Cell(40, 7, "Inspected "+report.InspectedAt.Format("2006-01-02 15:04"))
+	pdf.Ln(7)
+	if len(report.Items) == 0 {
+		pdf.Cell(40, 7, "No damage found.")
+		pdf.Ln(7)
+	}
+	for _, item := range report.Items {
+		label := item.Label()
+		if item.Description != "" {
+			label += ": " + item.Description
+		}
+		amount := ""
+		if markNew && item.New {
+			label = "NEW  " + label
+			amount = tax.Format(item.EstimatedCostCents, currency)
+		}
+		pdf.CellFormat(150, 7, label, "", 0, "L", false, 0, "")
+		pdf.CellFormat(30, 7, amount, "", 1, "R", false, 0, "")
+	}
+	if report.Notes != "" {
+		pdf.MultiCell(0, 7, "Notes: "+report.Notes, "", "L", false)
+	}
+	for i, photo := range report.Photos {
+		pdf.SetTextColor(0, 0, 255)
+		pdf.WriteLinkString(6, fmt.Sprintf("Photo %d", i+1), photo.URL)
+		pdf.SetTextColor(0, 0, 0)
+		pdf.Write(6, "   ")
+	}
+	pdf.Ln(12)
+}
+
+func loadBookingInspections(r *http.Request, tx pgx.Tx, bookingID string) (BookingInspectionsResponse, error) {
+	pickup, ret, err := inspection.ForBooking(r.Context(), tx, bookingID)
+	if err != nil {
+		return BookingInspectionsResponse{}, err
+	}
+	resp := BookingInspectionsResponse{Pickup: pickup, Return: ret}
+	if ret != nil {
+		for _, item := range ret.Items {
+			if item.New {
+				resp.NewDamageCostCents += item.EstimatedCostCents
+			}
+		}
+	}
+	return resp, nil
+}
+
+func writeInspectionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, inspection.ErrAlreadyRecorded):
+		writeErrorCode(w, http.StatusConflict, "ERR_ALREADY_RECORDED", err.Error())
+	case errors.Is(err, errWrongInspectionStage):
+		writeErrorCode(w, http.StatusConflict, "ERR_INVALID_STATE", err.Error())
+	case errors.Is(err, inspection.ErrNotFound), errors.Is(err, inspection.ErrItemNotFound):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	default:
+		writeLifecycleError(w, err)
+	}
+}
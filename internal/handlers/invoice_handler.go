@@ -1,16 +1,21 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"rental-saas/internal/database"
+	"rental-saas/internal/invoice"
+	"rental-saas/internal/mailer"
 	"rental-saas/internal/middleware"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
-	"github.com/jung-kurt/gofpdf"
 )
 
 type InvoiceHandler struct{}
@@ -19,59 +24,296 @@ func NewInvoiceHandler() *InvoiceHandler {
 	return &InvoiceHandler{}
 }
 
+func writeInvoiceError(w http.ResponseWriter, err error, prefix string) {
+	switch {
+	case err == pgx.ErrNoRows:
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", "Booking not found")
+	case errors.Is(err, invoice.ErrNotFound):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	case errors.Is(err, invoice.ErrNotCompleted), errors.Is(err, invoice.ErrNothingCharged):
+		writeErrorCode(w, http.StatusConflict, "ERR_NOT_INVOICEABLE", err.Error())
+	case errors.Is(err, invoice.ErrAlreadyInvoiced):
+		writeErrorCode(w, http.StatusConflict, "ERR_ALREADY_INVOICED", err.Error())
+	case errors.Is(err, invoice.ErrAlreadyCredited):
+		writeErrorCode(w, http.StatusConflict, "ERR_ALREADY_CREDITED", err.Error())
+	case errors.Is(err, invoice.ErrNotCreditable):
+		writeErrorCode(w, http.StatusConflict, "ERR_NOT_CREDITABLE", err.Error())
+	case errors.Is(err, invoice.ErrCreditTooLarge):
+		writeErrorCode(w, http.StatusConflict, "ERR_CREDIT_TOO_LARGE", err.Error())
+	default:
+		http.Error(w, prefix+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeInvoicePDF(w http.ResponseWriter, inv invoice.Invoice) {
+	pdf, err := invoice.Render(inv)
+	if err != nil {
+		http.Error(w, "Failed to render invoice: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", inv.Number))
+	w.Write(pdf)
+}
+
+// emailInvoice sends a newly issued invoice to its customer in the background.
+func emailInvoice(inv invoice.Invoice) {
+	go func() {
+		pdf, err := invoice.Render(inv)
+		if err != nil {
+			fmt.Printf("Failed to generate PDF for email: %v\n", err)
+			return
+		}
+		if err := mailer.SendInvoice(mailer.New(), inv.CustomerEmail, pdf); err != nil {
+			fmt.Printf("Failed to email invoice %s to %s: %v\n", inv.Number, inv.CustomerEmail, err)
+		}
+	}()
+}
+
+// GET /api/bookings/{id}/invoice
+// The booking's latest invoice as a PDF. Bookings completed before invoices
+// were stored have none until one is issued with POST /api/bookings/{id}/invoices.
 func (h *InvoiceHandler) GenerateInvoice(w http.ResponseWriter, r *http.Request) {
 	bookingID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(bookingID); err != nil {
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", "Booking not found")
+		return
+	}
 	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
 	if !ok {
 		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
 		return
 	}
 
-	var firstName, lastName, carMake, carModel string
-	var startTime, endTime time.Time
-	var totalAmountCents int
-
+	var inv invoice.Invoice
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		return tx.QueryRow(r.Context(), `
-			SELECT c.first_name, c.last_name, car.make, car.model, b.start_time, b.end_time, b.total_amount_cents
-			FROM bookings b
-			JOIN customers c ON b.customer_id = c.id
-			JOIN cars car ON b.car_id = car.id
-			WHERE b.id = $1
-		`, bookingID).Scan(&firstName, &lastName, &carMake, &carModel, &startTime, &endTime, &totalAmountCents)
+		var err error
+		inv, err = invoice.Latest(r.Context(), tx, bookingID)
+		return err
 	})
+	if err != nil {
+		writeInvoiceError(w, err, "Failed to load invoice: ")
+		return
+	}
+	writeInvoicePDF(w, inv)
+}
 
+// POST /api/bookings/{id}/invoices
+// Invoices a completed booking that has no invoice yet, or again once its last
+// invoice has been credited in full, and emails the new invoice to the customer.
+func (h *InvoiceHandler) IssueInvoice(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(bookingID); err != nil {
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", "Booking not found")
+		return
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var inv invoice.Invoice
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		inv, err = invoice.Issue(r.Context(), tx, bookingID, time.Now())
+		return err
+	})
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			http.Error(w, "Booking not found", http.StatusNotFound)
+		writeInvoiceError(w, err, "Failed to issue invoice: ")
+		return
+	}
+	emailInvoice(inv)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   inv,
+	})
+}
+
+// GET /api/invoices[?booking_id=...]
+// Invoices and credit notes, newest first, without their lines.
+func (h *InvoiceHandler) ListInvoices(w http.ResponseWriter, r *http.Request) {
+	bookingID := r.URL.Query().Get("booking_id")
+	if bookingID != "" {
+		if _, err := uuid.Parse(bookingID); err != nil {
+			http.Error(w, "Invalid booking_id", http.StatusBadRequest)
 			return
 		}
-		http.Error(w, "Database error: "+err.Error(), http.StatusInternalServerError)
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
 		return
 	}
 
-	pdf := gofpdf.New("P", "mm", "A4", "")
-	pdf.AddPage()
-	pdf.SetFont("Arial", "B", 16)
-	pdf.Cell(40, 10, fmt.Sprintf("Invoice - %s", tenantID))
-	pdf.Ln(12)
+	var invoices []invoice.Invoice
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		invoices, err = invoice.List(r.Context(), tx, bookingID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to list invoices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	pdf.SetFont("Arial", "", 12)
-	pdf.Cell(40, 10, fmt.Sprintf("Customer: %s %s", firstName, lastName))
-	pdf.Ln(8)
-	pdf.Cell(40, 10, fmt.Sprintf("Vehicle: %s %s", carMake, carModel))
-	pdf.Ln(8)
-	pdf.Cell(40, 10, fmt.Sprintf("Rental Period: %s to %s", startTime.Format("2006-01-02"), endTime.Format("2006-01-02")))
-	pdf.Ln(12)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   invoices,
+	})
+}
 
-	pdf.SetFont("Arial", "B", 14)
-	pdf.Cell(40, 10, fmt.Sprintf("Total: $%.2f", float64(totalAmountCents)/100.0))
+func (h *InvoiceHandler) load(w http.ResponseWriter, r *http.Request) (invoice.Invoice, bool) {
+	var inv invoice.Invoice
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return inv, false
+	}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		inv, err = invoice.Load(r.Context(), tx, chi.URLParam(r, "id"))
+		return err
+	})
+	if err != nil {
+		writeInvoiceError(w, err, "Failed to load invoice: ")
+		return inv, false
+	}
+	return inv, true
+}
 
-	w.Header().Set("Content-Type", "application/pdf")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=invoice-%s.pdf", bookingID))
-	
-	if err := pdf.Output(w); err != nil {
-		// Log error, but header already sent
-		fmt.Printf("Error generating PDF: %v\n", err)
+// GET /api/invoices/{id}
+func (h *InvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	inv, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   inv,
+	})
+}
+
+// GET /api/invoices/{id}/pdf
+// The invoice or credit note as issued, the same document that was emailed.
+func (h *InvoiceHandler) DownloadInvoice(w http.ResponseWriter, r *http.Request) {
+	inv, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	writeInvoicePDF(w, inv)
+}
+
+type CreditNoteRequest struct {
+	Reason string `json:"reason"`
+	// Optional: part of the invoice to credit; all that is left by default
+	AmountCents int `json:"amount_cents"`
+}
+
+// POST /api/invoices/{id}/credit-note
+// Credits an invoice, in full or in part. Once it is credited in full the
+// booking can be invoiced again with POST /api/bookings/{id}/invoices.
+func (h *InvoiceHandler) CreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	var req CreditNoteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		http.Error(w, "reason is required", http.StatusBadRequest)
+		return
+	}
+	if req.AmountCents < 0 {
+		http.Error(w, "amount_cents cannot be negative", http.StatusBadRequest)
+		return
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var cn invoice.Invoice
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		cn, err = invoice.Credit(r.Context(), tx, chi.URLParam(r, "id"), req.AmountCents, req.Reason, time.Now())
+		return err
+	})
+	if err != nil {
+		writeInvoiceError(w, err, "Failed to issue credit note: ")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   cn,
+	})
+}
+
+// GET /api/invoices/settings
+func (h *InvoiceHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var settings invoice.Settings
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		settings, err = invoice.LoadSettings(r.Context(), tx)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to load invoice settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   settings,
+	})
+}
+
+// PUT /api/invoices/settings
+// Sets the number prefixes, padding and whether numbering restarts each
+// year. Issued documents keep their numbers.
+func (h *InvoiceHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	settings := invoice.DefaultSettings()
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
+	if err := settings.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return invoice.SaveSettings(r.Context(), tx, settings)
+	})
+	if err != nil {
+		http.Error(w, "Failed to save invoice settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   settings,
+	})
 }
@@ -0,0 +1,571 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/database"
+	"rental-saas/internal/maintenance"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/models"
+	"rental-saas/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	errWorkOrderNotFound = errors.New("work order not found")
+	errWorkOrderState    = errors.New("work order cannot make this move")
+	errCarRented         = errors.New("car is rented; service can start once it is returned")
+)
+
+type MaintenanceHandler struct{}
+
+func NewMaintenanceHandler() *MaintenanceHandler {
+	return &MaintenanceHandler{}
+}
+
+type CreateWorkOrderRequest struct {
+	CarID          string    `json:"car_id"`
+	ServicePlanID  *string   `json:"service_plan_id"`
+	Description    string    `json:"description"`
+	Vendor         string    `json:"vendor"`
+	ScheduledStart time.Time `json:"scheduled_start"`
+	ScheduledEnd   time.Time `json:"scheduled_end"`
+}
+
+type CloseWorkOrderRequest struct {
+	CostCents int     `json:"cost_cents"`
+	Vendor    *string `json:"vendor"`
+	Odometer  *int    `json:"odometer"` // reading at service; defaults to the car's
+	Notes     string  `json:"notes"`
+}
+
+type DuePlan struct {
+	maintenance.Plan
+	LicensePlate string          `json:"license_plate"`
+	Odometer     int             `json:"odometer"`
+	Due          maintenance.Due `json:"due"`
+}
+
+// GET /api/maintenance/plans?car_id=...
+func (h *MaintenanceHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	query := "SELECT " + maintenance.PlanColumns + " FROM service_plans"
+	var args []interface{}
+	if carID := r.URL.Query().Get("car_id"); carID != "" {
+		if _, err := uuid.Parse(carID); err != nil {
+			http.Error(w, "Invalid car_id", http.StatusBadRequest)
+			return
+		}
+		query += " WHERE car_id = $1"
+		args = append(args, carID)
+	}
+	query += " ORDER BY created_at"
+
+	plans := []maintenance.Plan{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var p maintenance.Plan
+			if err := maintenance.ScanPlan(rows, &p); err != nil {
+				return err
+			}
+			plans = append(plans, p)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list service plans: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   plans,
+	})
+}
+
+// POST /api/maintenance/plans
+func (h *MaintenanceHandler) CreatePlan(w http.ResponseWriter, r *http.Request) {
+	h.savePlan(w, r, "")
+}
+
+// PUT /api/maintenance/plans/{id}
+// Editing last_service_* records a service done outside the work orders.
+func (h *MaintenanceHandler) UpdatePlan(w http.ResponseWriter, r *http.Request) {
+	h.savePlan(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *MaintenanceHandler) savePlan(w http.ResponseWriter, r *http.Request, id string) {
+	plan := maintenance.Plan{Active: true}
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(plan.CarID); err != nil {
+		http.Error(w, "Invalid car_id", http.StatusBadRequest)
+		return
+	}
+	if err := plan.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if plan.LastServiceDate.IsZero() {
+		plan.LastServiceDate.Time = time.Now().UTC().Truncate(24 * time.Hour)
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	args := []interface{}{plan.CarID, plan.Name, plan.IntervalKm, plan.IntervalMonths, plan.LastServiceOdometer, plan.LastServiceDate, plan.Active}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if id == "" {
+			return tx.QueryRow(r.Context(), `
+				INSERT INTO service_plans (car_id, name, interval_km, interval_months, last_service_odometer, last_service_date, active)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING id
+			`, args...).Scan(&plan.ID)
+		}
+		// A changed plan may no longer be due, so it can alert again
+		return tx.QueryRow(r.Context(), `
+			UPDATE service_plans SET car_id = $1, name = $2, interval_km = $3, interval_months = $4,
+			       last_service_odometer = $5, last_service_date = $6, active = $7,
+			       due_alerted_at = NULL, updated_at = NOW()
+			WHERE id = $8
+			RETURNING id
+		`, append(args, id)...).Scan(&plan.ID)
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Service plan not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save service plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   plan,
+	})
+}
+
+// DELETE /api/maintenance/plans/{id}
+func (h *MaintenanceHandler) DeletePlan(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var deleted int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), "DELETE FROM service_plans WHERE id = $1", chi.URLParam(r, "id"))
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete service plan: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Service plan not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/maintenance/due
+// Active plans that are due soon or overdue, most urgent first.
+func (h *MaintenanceHandler) ListDue(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	due := []DuePlan{}
+	now := time.Now()
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT sp.id, sp.car_id, sp.name, sp.interval_km, sp.interval_months, sp.last_service_odometer,
+			       sp.last_service_date, sp.active, c.license_plate, c.odometer
+			FROM service_plans sp JOIN cars c ON c.id = sp.car_id
+			WHERE sp.active
+		`)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var d DuePlan
+			p := &d.Plan
+			err := rows.Scan(&p.ID, &p.CarID, &p.Name, &p.IntervalKm, &p.IntervalMonths, &p.LastServiceOdometer,
+				&p.LastServiceDate, &p.Active, &d.LicensePlate, &d.Odometer)
+			if err != nil {
+				return err
+			}
+			d.Due = p.Due(d.Odometer, now)
+			if d.Due.State != maintenance.StateOK {
+				due = append(due, d)
+			}
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list due service: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Overdue before due soon
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Due.State == maintenance.StateOverdue && due[j].Due.State != maintenance.StateOverdue
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   due,
+	})
+}
+
+// GET /api/maintenance/work-orders?car_id=...&status=...
+func (h *MaintenanceHandler) ListWorkOrders(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var where []string
+	var args []interface{}
+	if carID := r.URL.Query().Get("car_id"); carID != "" {
+		if _, err := uuid.Parse(carID); err != nil {
+			http.Error(w, "Invalid car_id", http.StatusBadRequest)
+			return
+		}
+		args = append(args, carID)
+		where = append(where, fmt.Sprintf("car_id = $%d", len(args)))
+	}
+	if status := r.URL.Query().Get("status"); status != "" {
+		args = append(args, status)
+		where = append(where, fmt.Sprintf("status = $%d", len(args)))
+	}
+	query := "SELECT " + maintenance.WorkOrderColumns + " FROM work_orders"
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY scheduled_start DESC"
+
+	orders := []maintenance.WorkOrder{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var wo maintenance.WorkOrder
+			if err := maintenance.ScanWorkOrder(rows, &wo); err != nil {
+				return err
+			}
+			orders = append(orders, wo)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list work orders: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   orders,
+	})
+}
+
+// POST /api/maintenance/work-orders
+// Schedules service. The car cannot be booked during the window, and the
+// window cannot overlap a booking.
+func (h *MaintenanceHandler) CreateWorkOrder(w http.ResponseWriter, r *http.Request) {
+	var req CreateWorkOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if _, err := uuid.Parse(req.CarID); err != nil {
+		http.Error(w, "Invalid car_id", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Description) == "" {
+		http.Error(w, "description is required", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var wo maintenance.WorkOrder
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if err := availability.ReserveForMaintenance(r.Context(), tx, req.CarID, req.ScheduledStart, req.ScheduledEnd); err != nil {
+			return err
+		}
+		return maintenance.ScanWorkOrder(tx.QueryRow(r.Context(), `
+			INSERT INTO work_orders (car_id, service_plan_id, description, vendor, scheduled_start, scheduled_end)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING `+maintenance.WorkOrderColumns,
+			req.CarID, req.ServicePlanID, req.Description, req.Vendor, req.ScheduledStart, req.ScheduledEnd), &wo)
+	})
+	if err != nil {
+		writeMaintenanceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   wo,
+	})
+}
+
+// POST /api/maintenance/work-orders/{id}/start
+// The car goes to maintenance until the order is closed.
+func (h *MaintenanceHandler) StartWorkOrder(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var wo maintenance.WorkOrder
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		carID, err := lockWorkOrder(r.Context(), tx, id, maintenance.WorkOrderScheduled)
+		if err != nil {
+			return err
+		}
+
+		var carStatus models.CarStatus
+		if err := tx.QueryRow(r.Context(), "SELECT status FROM cars WHERE id = $1 FOR UPDATE", carID).Scan(&carStatus); err != nil {
+			return err
+		}
+		if carStatus == models.CarStatusRented {
+			return errCarRented
+		}
+
+		err = maintenance.ScanWorkOrder(tx.QueryRow(r.Context(), `
+			UPDATE work_orders SET status = $1, started_at = NOW(), updated_at = NOW()
+			WHERE id = $2
+			RETURNING `+maintenance.WorkOrderColumns, maintenance.WorkOrderInProgress, id), &wo)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.Context(), "UPDATE cars SET status = $1, updated_at = NOW() WHERE id = $2", models.CarStatusMaintenance, carID); err != nil {
+			return err
+		}
+		return webhooks.EmitCarStatusChanged(r.Context(), tx, carID, string(carStatus), string(models.CarStatusMaintenance), "maintenance")
+	})
+	if err != nil {
+		writeMaintenanceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   wo,
+	})
+}
+
+// POST /api/maintenance/work-orders/{id}/close
+// Records the cost and reading, marks the linked plan serviced and returns the
+// car to available once no other work order has it in the shop.
+func (h *MaintenanceHandler) CloseWorkOrder(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req CloseWorkOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.CostCents < 0 {
+		http.Error(w, "cost_cents cannot be negative", http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var wo maintenance.WorkOrder
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		carID, err := lockWorkOrder(r.Context(), tx, id, maintenance.WorkOrderScheduled, maintenance.WorkOrderInProgress)
+		if err != nil {
+			return err
+		}
+
+		var carStatus models.CarStatus
+		var odometer int
+		err = tx.QueryRow(r.Context(), "SELECT status, odometer FROM cars WHERE id = $1 FOR UPDATE", carID).Scan(&carStatus, &odometer)
+		if err != nil {
+			return err
+		}
+		if req.Odometer != nil {
+			if *req.Odometer < odometer {
+				return fmt.Errorf("%w (%d km)", errOdometerBehind, odometer)
+			}
+			odometer = *req.Odometer
+		}
+
+		err = maintenance.ScanWorkOrder(tx.QueryRow(r.Context(), `
+			UPDATE work_orders
+			SET status = $1, closed_at = NOW(), started_at = COALESCE(started_at, NOW()),
+			    cost_cents = $2, vendor = COALESCE($3, vendor), odometer = $4, notes = $5, updated_at = NOW()
+			WHERE id = $6
+			RETURNING `+maintenance.WorkOrderColumns,
+			maintenance.WorkOrderClosed, req.CostCents, req.Vendor, odometer, req.Notes, id), &wo)
+		if err != nil {
+			return err
+		}
+		if wo.ServicePlanID != nil {
+			_, err := tx.Exec(r.Context(), `
+				UPDATE service_plans
+				SET last_service_odometer = $1, last_service_date = CURRENT_DATE, due_alerted_at = NULL, updated_at = NOW()
+				WHERE id = $2
+			`, odometer, *wo.ServicePlanID)
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := tx.Exec(r.Context(), "UPDATE cars SET odometer = $1 WHERE id = $2", odometer, carID); err != nil {
+			return err
+		}
+
+		var stillInShop bool
+		err = tx.QueryRow(r.Context(), `
+			SELECT EXISTS (SELECT 1 FROM work_orders WHERE car_id = $1 AND status = $2)
+		`, carID, maintenance.WorkOrderInProgress).Scan(&stillInShop)
+		if err != nil {
+			return err
+		}
+		if carStatus != models.CarStatusMaintenance || stillInShop {
+			return nil
+		}
+		if _, err := tx.Exec(r.Context(), "UPDATE cars SET status = $1, updated_at = NOW() WHERE id = $2", models.CarStatusAvailable, carID); err != nil {
+			return err
+		}
+		return webhooks.EmitCarStatusChanged(r.Context(), tx, carID, string(carStatus), string(models.CarStatusAvailable), "maintenance")
+	})
+	if err != nil {
+		writeMaintenanceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   wo,
+	})
+}
+
+// POST /api/maintenance/work-orders/{id}/cancel
+// Drops a work order that has not started and frees its window.
+func (h *MaintenanceHandler) CancelWorkOrder(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var wo maintenance.WorkOrder
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if _, err := lockWorkOrder(r.Context(), tx, id, maintenance.WorkOrderScheduled); err != nil {
+			return err
+		}
+		return maintenance.ScanWorkOrder(tx.QueryRow(r.Context(), `
+			UPDATE work_orders SET status = $1, updated_at = NOW()
+			WHERE id = $2
+			RETURNING `+maintenance.WorkOrderColumns, maintenance.WorkOrderCancelled, id), &wo)
+	})
+	if err != nil {
+		writeMaintenanceError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   wo,
+	})
+}
+
+// lockWorkOrder locks the order and checks it is in one of the from statuses.
+func lockWorkOrder(ctx context.Context, tx pgx.Tx, id string, from ...string) (carID string, err error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return "", errWorkOrderNotFound
+	}
+	var status string
+	err = tx.QueryRow(ctx, "SELECT car_id, status FROM work_orders WHERE id = $1 FOR UPDATE", id).Scan(&carID, &status)
+	if err == pgx.ErrNoRows {
+		return "", errWorkOrderNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, s := range from {
+		if status == s {
+			return carID, nil
+		}
+	}
+	return "", fmt.Errorf("%w (status: %s)", errWorkOrderState, status)
+}
+
+func writeMaintenanceError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errWorkOrderNotFound), errors.Is(err, availability.ErrCarNotFound):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	case errors.Is(err, errWorkOrderState):
+		writeErrorCode(w, http.StatusConflict, "ERR_INVALID_TRANSITION", err.Error())
+	case errors.Is(err, errCarRented), errors.Is(err, availability.ErrUnavailable):
+		writeErrorCode(w, http.StatusConflict, "ERR_UNAVAILABLE", err.Error())
+	case errors.Is(err, availability.ErrInvalidRange):
+		writeErrorCode(w, http.StatusBadRequest, "ERR_INVALID_RANGE", err.Error())
+	default:
+		writeLifecycleError(w, err)
+	}
+}
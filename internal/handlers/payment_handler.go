@@ -6,10 +6,17 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"rental-saas/internal/database"
 	"rental-saas/internal/middleware"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/refund"
+	"rental-saas/internal/tax"
+	"rental-saas/internal/webhooks"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/stripe/stripe-go/v76"
 	"github.com/stripe/stripe-go/v76/paymentintent"
@@ -25,7 +32,7 @@ func NewPaymentHandler() *PaymentHandler {
 
 type CreateIntentRequest struct {
 	BookingID string `json:"booking_id"`
-	Amount    int64  `json:"amount"` // Amount in cents
+	Amount    int64  `json:"amount"` // In the booking's currency's minor unit; defaults to its total with taxes plus its deposit
 }
 
 func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Request) {
@@ -35,10 +42,14 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if req.Amount <= 0 {
+	if req.Amount < 0 {
 		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
 		return
 	}
+	if _, err := uuid.Parse(req.BookingID); err != nil {
+		http.Error(w, "Invalid booking_id", http.StatusBadRequest)
+		return
+	}
 
 	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
 	if !ok {
@@ -46,12 +57,42 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// Check if payment intent already exists for this booking
+	// Check if payment intent already exists for this booking, and read the
+	// currency and taxes the booking was priced with
 	var existingIntentID string
+	var taxes tax.Config
+	var totalCents, depositCents, taxCents int
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		return tx.QueryRow(r.Context(), "SELECT stripe_intent_id FROM payments WHERE booking_id = $1", req.BookingID).Scan(&existingIntentID)
+		var err error
+		if taxes, err = tax.ForBooking(r.Context(), tx, req.BookingID); err != nil {
+			return err
+		}
+		if err := tx.QueryRow(r.Context(), `
+			SELECT COALESCE(total_amount_cents, 0), COALESCE(deposit_amount_cents, 0) FROM bookings WHERE id = $1
+		`, req.BookingID).Scan(&totalCents, &depositCents); err != nil {
+			return err
+		}
+		charges, err := pricing.Charges(r.Context(), tx, req.BookingID)
+		if err != nil {
+			return err
+		}
+		taxCents = tax.Amount(charges)
+
+		err = tx.QueryRow(r.Context(), "SELECT stripe_intent_id FROM payments WHERE booking_id = $1", req.BookingID).Scan(&existingIntentID)
+		if err == pgx.ErrNoRows {
+			return nil
+		}
+		return err
 	})
-	if err == nil && existingIntentID != "" {
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Booking not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load booking: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if existingIntentID != "" {
 		// Return existing intent
 		// We need to fetch the client secret from Stripe if we don't store it (we don't seem to store it in DB based on previous code, only intent ID)
 		// Or we can retrieve the intent from Stripe.
@@ -67,10 +108,20 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// The deposit is held with the total so that mileage, refuelling and
+	// damage charged at return can still be captured
+	if req.Amount == 0 {
+		req.Amount = int64(pricing.Hold(totalCents, depositCents))
+	}
+	if req.Amount <= 0 {
+		http.Error(w, "Amount must be greater than 0", http.StatusBadRequest)
+		return
+	}
+
 	// Create Stripe PaymentIntent (Manual Capture)
 	params := &stripe.PaymentIntentParams{
 		Amount:        stripe.Int64(req.Amount),
-		Currency:      stripe.String(string(stripe.CurrencyUSD)),
+		Currency:      stripe.String(taxes.Currency),
 		CaptureMethod: stripe.String("manual"), // Two-step payment
 		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{
 			Enabled: stripe.Bool(true),
@@ -78,6 +129,7 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 		Metadata: map[string]string{
 			"tenant_id":  tenantID,
 			"booking_id": req.BookingID,
+			"tax_amount": strconv.Itoa(taxCents),
 		},
 	}
 
@@ -90,8 +142,8 @@ func (h *PaymentHandler) CreatePaymentIntent(w http.ResponseWriter, r *http.Requ
 	// Store intent in database
 	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
 		_, err := tx.Exec(r.Context(),
-			"INSERT INTO payments (booking_id, stripe_intent_id, amount_cents, status) VALUES ($1, $2, $3, $4)",
-			req.BookingID, pi.ID, req.Amount, "pending_auth")
+			"INSERT INTO payments (booking_id, stripe_intent_id, amount_cents, status, currency) VALUES ($1, $2, $3, $4, $5)",
+			req.BookingID, pi.ID, req.Amount, "pending_auth", taxes.Currency)
 		return err
 	})
 
@@ -140,12 +192,15 @@ func (h *PaymentHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 			return
 		}
 
-		// Update status in DB
+		// Update status in DB. Stripe retries deliveries, so only the first one emits.
 		err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-			_, err := tx.Exec(r.Context(),
-				"UPDATE payments SET status = 'authorized' WHERE stripe_intent_id = $1",
+			tag, err := tx.Exec(r.Context(),
+				"UPDATE payments SET status = 'authorized' WHERE stripe_intent_id = $1 AND status <> 'authorized'",
 				pi.ID)
-			return err
+			if err != nil || tag.RowsAffected() == 0 {
+				return err
+			}
+			return webhooks.EmitPayment(r.Context(), tx, webhooks.EventPaymentAuthorized, pi.ID)
 		})
 
 		if err != nil {
@@ -156,5 +211,93 @@ func (h *PaymentHandler) HandleStripeWebhook(w http.ResponseWriter, r *http.Requ
 		fmt.Printf("Payment authorized for intent %s (Tenant: %s)\n", pi.ID, tenantID)
 	}
 
+	if event.Type == "charge.refunded" {
+		var ch stripe.Charge
+		if err := json.Unmarshal(event.Data.Raw, &ch); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Stripe copies the PaymentIntent metadata onto its charges
+		tenantID := ch.Metadata["tenant_id"]
+		if tenantID == "" || ch.PaymentIntent == nil {
+			fmt.Println("Missing tenant_id or payment intent on refunded charge")
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		// Refunds recorded here are settled through charge.refund.updated; any
+		// other refund Stripe made, from the dashboard or by a request that
+		// rolled back, is recorded and credited, and sent on like ours
+		remote, err := remoteRefunds(ch.PaymentIntent.ID)
+		if err != nil {
+			fmt.Printf("Failed to list refunds of %s: %v\n", ch.PaymentIntent.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+			recorded, err := refund.Sync(r.Context(), tx, ch.PaymentIntent.ID, remote, time.Now())
+			if err == refund.ErrNoPayment {
+				return nil // not one of ours
+			}
+			if err != nil || len(recorded) == 0 {
+				return err
+			}
+			for _, rf := range recorded {
+				if err := webhooks.EmitRefund(r.Context(), tx, webhooks.EventRefundCreated, rf.ID); err != nil {
+					return err
+				}
+			}
+			if err := webhooks.EmitPayment(r.Context(), tx, webhooks.EventPaymentRefunded, ch.PaymentIntent.ID); err != nil {
+				return err
+			}
+			for _, rf := range recorded {
+				if rf.Status != refund.StatusSucceeded {
+					continue
+				}
+				if err := webhooks.EmitRefund(r.Context(), tx, webhooks.EventRefundSucceeded, rf.ID); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Failed to record refund: %v\n", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if event.Type == "charge.refund.updated" {
+		var re stripe.Refund
+		if err := json.Unmarshal(event.Data.Raw, &re); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing webhook JSON: %v\n", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Refunds made outside CreateRefund carry no tenant; charge.refunded covers them
+		tenantID := re.Metadata["tenant_id"]
+		if tenantID == "" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+			// Not found while CreateRefund has yet to commit: failing makes Stripe retry
+			rf, err := refund.ByStripeID(r.Context(), tx, re.ID)
+			if err != nil {
+				return err
+			}
+			return settleRefund(r.Context(), tx, &rf, string(re.Status))
+		})
+		if err != nil {
+			fmt.Printf("Failed to update refund %s: %v\n", re.ID, err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusOK)
 }
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"rental-saas/internal/auth"
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/refund"
+	"rental-saas/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stripe/stripe-go/v76"
+	stripeRefund "github.com/stripe/stripe-go/v76/refund"
+)
+
+var errRefundRejected = errors.New("Stripe rejected the refund")
+
+type CreateRefundRequest struct {
+	Reason string `json:"reason"`
+	// Optional: the amount to refund; all that is left on the payment by default
+	AmountCents int `json:"amount_cents"`
+}
+
+// settleRefund moves a refund to the status Stripe reports for it. A refund
+// that succeeded credits the booking's invoice; one that failed gives its
+// amount back to be refunded again. Each change is sent on once.
+func settleRefund(ctx context.Context, tx pgx.Tx, rf *refund.Refund, stripeStatus string) error {
+	changed, err := refund.SetStatus(ctx, tx, rf, refund.FromStripe(stripeStatus))
+	if err != nil || !changed {
+		return err
+	}
+	switch rf.Status {
+	case refund.StatusSucceeded:
+		if err := refund.Credit(ctx, tx, rf, time.Now()); err != nil {
+			return err
+		}
+		return webhooks.EmitRefund(ctx, tx, webhooks.EventRefundSucceeded, rf.ID)
+	case refund.StatusFailed:
+		// No money went back, so there is no payment.refunded to send
+		return webhooks.EmitRefund(ctx, tx, webhooks.EventRefundFailed, rf.ID)
+	}
+	return nil
+}
+
+// remoteRefunds lists the refunds Stripe has made on a payment intent.
+func remoteRefunds(intentID string) ([]refund.Remote, error) {
+	var out []refund.Remote
+	it := stripeRefund.List(&stripe.RefundListParams{PaymentIntent: stripe.String(intentID)})
+	for it.Next() {
+		re := it.Refund()
+		out = append(out, refund.Remote{
+			StripeRefundID: re.ID, AmountCents: int(re.Amount), Status: refund.FromStripe(string(re.Status)),
+			Key: re.Metadata["idempotency_key"], Reason: re.Metadata["reason"],
+		})
+	}
+	return out, it.Err()
+}
+
+func writeRefundError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, refund.ErrNoPayment):
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", err.Error())
+	case errors.Is(err, refund.ErrNotRefundable):
+		writeErrorCode(w, http.StatusConflict, "ERR_NOT_REFUNDABLE", err.Error())
+	case errors.Is(err, refund.ErrTooLarge):
+		writeErrorCode(w, http.StatusConflict, "ERR_REFUND_TOO_LARGE", err.Error())
+	case errors.Is(err, errRefundRejected):
+		writeErrorCode(w, http.StatusBadGateway, "ERR_REFUND_REJECTED", err.Error())
+	default:
+		http.Error(w, "Failed to refund: "+err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// POST /api/bookings/{id}/refunds
+// Refunds all or part of a booking's captured payment through Stripe. The
+// refund is usually settled at once; otherwise it stays pending until Stripe
+// reports on it (charge.refund.updated). Once it succeeds the booking's
+// invoice is credited for the amount. The Idempotency-Key header is required:
+// a request repeated with the same key returns the refund it made (200)
+// instead of making another.
+func (h *PaymentHandler) CreateRefund(w http.ResponseWriter, r *http.Request) {
+	var req CreateRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if err := refund.ValidateReason(req.Reason); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.AmountCents < 0 {
+		http.Error(w, "amount_cents cannot be negative", http.StatusBadRequest)
+		return
+	}
+	clientKey := r.Header.Get("Idempotency-Key")
+	if err := refund.ValidateKey(clientKey); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bookingID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(bookingID); err != nil {
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", "Booking not found")
+		return
+	}
+	var createdBy *string
+	if userID := auth.UserIDFromContext(r.Context()); userID != "" {
+		createdBy = &userID
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var rf refund.Refund
+	replayed := false
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		// The payment stays locked until the refund is recorded, so concurrent
+		// refunds cannot give back more than was captured, nor two requests
+		// with one key both refund
+		p, err := refund.LockPayment(r.Context(), tx, bookingID)
+		if err != nil {
+			return err
+		}
+		key := refund.Key(p.ID, clientKey)
+		if rf, err = refund.ByKey(r.Context(), tx, key); err != refund.ErrNotFound {
+			replayed = err == nil
+			return err
+		}
+		amount, err := p.Check(req.AmountCents)
+		if err != nil {
+			return err
+		}
+		if rf, err = refund.Create(r.Context(), tx, &p, amount, req.Reason, key, createdBy); err != nil {
+			return err
+		}
+
+		// A retry after a lost commit reuses the key, and Stripe returns the
+		// refund it already made instead of making another. The key in the
+		// metadata lets charge.refunded record that refund under it.
+		params := &stripe.RefundParams{
+			PaymentIntent: stripe.String(p.StripeIntentID),
+			Amount:        stripe.Int64(int64(amount)),
+		}
+		params.AddMetadata("tenant_id", tenantID)
+		params.AddMetadata("booking_id", bookingID)
+		params.AddMetadata("reason", req.Reason)
+		params.AddMetadata("idempotency_key", key)
+		params.IdempotencyKey = stripe.String(rf.IdempotencyKey())
+		re, err := stripeRefund.New(params)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errRefundRejected, err)
+		}
+
+		if err := refund.Record(r.Context(), tx, &rf, re.ID); err != nil {
+			return err
+		}
+		if err := webhooks.EmitRefund(r.Context(), tx, webhooks.EventRefundCreated, rf.ID); err != nil {
+			return err
+		}
+		if err := webhooks.EmitPayment(r.Context(), tx, webhooks.EventPaymentRefunded, p.StripeIntentID); err != nil {
+			return err
+		}
+		return settleRefund(r.Context(), tx, &rf, string(re.Status))
+	})
+	if err != nil {
+		writeRefundError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !replayed {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   rf,
+	})
+}
+
+// GET /api/bookings/{id}/refunds
+func (h *PaymentHandler) ListRefunds(w http.ResponseWriter, r *http.Request) {
+	bookingID := chi.URLParam(r, "id")
+	if _, err := uuid.Parse(bookingID); err != nil {
+		writeErrorCode(w, http.StatusNotFound, "ERR_NOT_FOUND", "Booking not found")
+		return
+	}
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var refunds []refund.Refund
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		refunds, err = refund.List(r.Context(), tx, bookingID)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to list refunds: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   refunds,
+	})
+}
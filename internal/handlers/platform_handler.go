@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/provisioning"
+)
+
+type PlatformHandler struct{}
+
+func NewPlatformHandler() *PlatformHandler {
+	return &PlatformHandler{}
+}
+
+type CreateTenantRequest struct {
+	Name          string `json:"name"`
+	Subdomain     string `json:"subdomain"`
+	AdminEmail    string `json:"admin_email"`
+	AdminPassword string `json:"admin_password"`
+}
+
+// POST /api/platform/tenants
+func (h *PlatformHandler) CreateTenant(w http.ResponseWriter, r *http.Request) {
+	var req CreateTenantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := provisioning.ProvisionTenant(r.Context(), database.DB, provisioning.TenantRequest{
+		Name:          req.Name,
+		Subdomain:     req.Subdomain,
+		AdminEmail:    req.AdminEmail,
+		AdminPassword: req.AdminPassword,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, provisioning.ErrInvalidRequest):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		case errors.Is(err, provisioning.ErrTenantExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "Failed to provision tenant: "+err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   result,
+	})
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+	"rental-saas/internal/vehicleclass"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+type PricingHandler struct{}
+
+func NewPricingHandler() *PricingHandler {
+	return &PricingHandler{}
+}
+
+// GET /api/pricing/rules
+func (h *PricingHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var rules []pricing.Rule
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		rules, err = pricing.LoadRules(r.Context(), tx)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to list pricing rules: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   rules,
+	})
+}
+
+// POST /api/pricing/rules
+func (h *PricingHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	h.saveRule(w, r, "")
+}
+
+// PUT /api/pricing/rules/{id}
+// Replaces the rule, except whether it is active when the body leaves that out.
+// Bookings already made keep the charges they were quoted.
+func (h *PricingHandler) UpdateRule(w http.ResponseWriter, r *http.Request) {
+	h.saveRule(w, r, chi.URLParam(r, "id"))
+}
+
+func (h *PricingHandler) saveRule(w http.ResponseWriter, r *http.Request, id string) {
+	// A rule is created active, and an update that leaves out active keeps it
+	var body struct {
+		pricing.Rule
+		Active *bool `json:"active"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	rule := body.Rule
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	args := []interface{}{rule.Name, rule.Kind, rule.CarID, rule.Channel, rule.StartDate, rule.EndDate, rule.DaysOfWeek,
+		rule.MinDays, rule.Percent, rule.DailyRateCents, rule.AmountCents, rule.Priority, body.Active}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if id == "" {
+			return tx.QueryRow(r.Context(), `
+				INSERT INTO pricing_rules (name, kind, car_id, channel, start_date, end_date, days_of_week,
+				                           min_days, percent, daily_rate_cents, amount_cents, priority, active)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, COALESCE($13, TRUE))
+				RETURNING id, active
+			`, args...).Scan(&rule.ID, &rule.Active)
+		}
+		return tx.QueryRow(r.Context(), `
+			UPDATE pricing_rules SET name = $1, kind = $2, car_id = $3, channel = $4, start_date = $5, end_date = $6,
+			       days_of_week = $7, min_days = $8, percent = $9, daily_rate_cents = $10, amount_cents = $11,
+			       priority = $12, active = COALESCE($13, active), updated_at = NOW()
+			WHERE id = $14
+			RETURNING id, active
+		`, append(args, id)...).Scan(&rule.ID, &rule.Active)
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Pricing rule not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to save pricing rule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if id == "" {
+		w.WriteHeader(http.StatusCreated)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   rule,
+	})
+}
+
+// DELETE /api/pricing/rules/{id}
+func (h *PricingHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var deleted int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), "DELETE FROM pricing_rules WHERE id = $1", chi.URLParam(r, "id"))
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete pricing rule: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Pricing rule not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET /api/pricing/quote?car_id=...|class_id=...&start=...&end=...[&channel=widget][&branch_id=...&dropoff_branch_id=...]
+// Prices a rental of a car or a vehicle class without booking it, as a booking
+// made now on that channel would be.
+func (h *PricingHandler) GetQuote(w http.ResponseWriter, r *http.Request) {
+	start, err1 := time.Parse(time.RFC3339, r.URL.Query().Get("start"))
+	end, err2 := time.Parse(time.RFC3339, r.URL.Query().Get("end"))
+	if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+		http.Error(w, "start and end must be RFC 3339 timestamps, end after start", http.StatusBadRequest)
+		return
+	}
+	carID, classID := r.URL.Query().Get("car_id"), r.URL.Query().Get("class_id")
+	if classID != "" {
+		if _, err := uuid.Parse(classID); err != nil || carID != "" {
+			http.Error(w, "Invalid class_id", http.StatusBadRequest)
+			return
+		}
+	} else if _, err := uuid.Parse(carID); err != nil {
+		http.Error(w, "Invalid car_id", http.StatusBadRequest)
+		return
+	}
+	channel := r.URL.Query().Get("channel")
+	if channel == "" {
+		channel = pricing.ChannelDashboard
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	route := routeFromQuery(r)
+	var quote pricing.Quote
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		quote, _, err = quoteRental(r.Context(), tx, carID, classID, start, end, channel, route, nil)
+		return err
+	})
+	if err == pricing.ErrCarNotFound || err == vehicleclass.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRouteError(w, err, "Failed to price rental: ")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   quote,
+	})
+}
+
+// GET /api/pricing/settings
+func (h *PricingHandler) GetSettings(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var settings pricing.Settings
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		settings, err = pricing.LoadSettings(r.Context(), tx)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to load pricing settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   settings,
+	})
+}
+
+// PUT /api/pricing/settings
+// Sets the refuel price per eighth of a tank and the recharge price per percent
+// of battery that ReturnCar charges when a car comes back emptier than it left.
+func (h *PricingHandler) UpdateSettings(w http.ResponseWriter, r *http.Request) {
+	var settings pricing.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := settings.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return pricing.SaveSettings(r.Context(), tx, settings)
+	})
+	if err != nil {
+		http.Error(w, "Failed to save pricing settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   settings,
+	})
+}
+
+// GET /api/pricing/tax
+func (h *PricingHandler) GetTax(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var taxes tax.Config
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var err error
+		taxes, err = tax.Load(r.Context(), tx)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to load tax settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   taxes,
+	})
+}
+
+// PUT /api/pricing/tax
+// Replaces the currency, rounding and tax rates. Bookings already made keep
+// the currency and rates they were priced with, settlement included.
+func (h *PricingHandler) UpdateTax(w http.ResponseWriter, r *http.Request) {
+	taxes := tax.Default()
+	if err := json.NewDecoder(r.Body).Decode(&taxes); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	taxes.Currency = strings.ToLower(taxes.Currency)
+	if err := taxes.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		if err := tax.Save(r.Context(), tx, taxes); err != nil {
+			return err
+		}
+		var err error
+		taxes, err = tax.Load(r.Context(), tx)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to save tax settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   taxes,
+	})
+}
@@ -5,10 +5,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"net/http"
+	"time"
 
 	"rental-saas/internal/database"
 	"rental-saas/internal/middleware"
+	"rental-saas/internal/webhooks"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/jackc/pgx/v5"
 )
 
@@ -23,12 +26,33 @@ type CreateWebhookRequest struct {
 	Events []string `json:"events"`
 }
 
+// UpdateWebhookRequest is a partial update; omitted fields are left alone.
+// Setting active to true also re-enables an endpoint the worker disabled.
+type UpdateWebhookRequest struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+type RotateSecretRequest struct {
+	// How long the old secret keeps signing deliveries; default 24, 0 drops it at once
+	OverlapHours *int `json:"overlap_hours"`
+}
+
 type WebhookResponse struct {
-	ID        string   `json:"id"`
-	URL       string   `json:"url"`
-	Events    []string `json:"events"`
-	SecretKey string   `json:"secret_key"`
-	Active    bool     `json:"active"`
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Only returned when the secret is created or rotated
+	SecretKey string `json:"secret_key,omitempty"`
+	Active    bool   `json:"active"`
+
+	PreviousSecretExpiresAt *time.Time `json:"previous_secret_expires_at,omitempty"`
+
+	// Set when the outbox worker gave up on the endpoint (see webhooks.DisableAfter)
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
 }
 
 func (h *SettingsHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +66,15 @@ func (h *SettingsHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request
 		http.Error(w, "URL and Events are required", http.StatusBadRequest)
 		return
 	}
+	if err := webhooks.ValidateURL(r.Context(), req.URL); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	events, err := webhooks.ValidateEvents(req.Events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
 	if !ok {
@@ -49,23 +82,21 @@ func (h *SettingsHandler) RegisterWebhook(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// Generate Secret Key
-	bytes := make([]byte, 32)
-	if _, err := rand.Read(bytes); err != nil {
+	secretKey, err := newWebhookSecret()
+	if err != nil {
 		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
 		return
 	}
-	secretKey := hex.EncodeToString(bytes)
 
 	var webhook WebhookResponse
 	webhook.SecretKey = secretKey
 
-	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
 		return tx.QueryRow(r.Context(), `
 			INSERT INTO webhooks (url, events, secret_key) 
 			VALUES ($1, $2, $3) 
 			RETURNING id, url, events, active
-		`, req.URL, req.Events, secretKey).Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active)
+		`, req.URL, events, secretKey).Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.Active)
 	})
 
 	if err != nil {
@@ -84,23 +115,23 @@ func (h *SettingsHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var webhooks []WebhookResponse
+	list := []WebhookResponse{}
 
 	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
-		rows, err := tx.Query(r.Context(), `SELECT id, url, events, secret_key, active FROM webhooks`)
+		rows, err := tx.Query(r.Context(), `SELECT `+webhookColumns+` FROM webhooks ORDER BY created_at`)
 		if err != nil {
 			return err
 		}
 		defer rows.Close()
 
 		for rows.Next() {
-			var w WebhookResponse
-			if err := rows.Scan(&w.ID, &w.URL, &w.Events, &w.SecretKey, &w.Active); err != nil {
+			var wh WebhookResponse
+			if err := scanWebhook(rows, &wh); err != nil {
 				return err
 			}
-			webhooks = append(webhooks, w)
+			list = append(list, wh)
 		}
-		return nil
+		return rows.Err()
 	})
 
 	if err != nil {
@@ -109,5 +140,202 @@ func (h *SettingsHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(webhooks)
+	json.NewEncoder(w).Encode(list)
+}
+
+// GET /api/settings/webhooks/events
+// The events endpoints can subscribe to.
+func (h *SettingsHandler) ListWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   webhooks.Catalogue,
+	})
+}
+
+// PATCH /api/settings/webhooks/{id}
+func (h *SettingsHandler) UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req UpdateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.URL != nil {
+		if err := webhooks.ValidateURL(r.Context(), *req.URL); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if req.Events != nil {
+		events, err := webhooks.ValidateEvents(req.Events)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Events = events
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var webhook WebhookResponse
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return scanWebhook(tx.QueryRow(r.Context(), `
+			UPDATE webhooks SET
+				url = COALESCE($2, url),
+				events = COALESCE($3, events),
+				active = COALESCE($4, active),
+				disabled_at = CASE WHEN $4 THEN NULL ELSE disabled_at END,
+				disabled_reason = CASE WHEN $4 THEN NULL ELSE disabled_reason END,
+				consecutive_failures = CASE WHEN $4 THEN 0 ELSE consecutive_failures END,
+				failing_since = CASE WHEN $4 THEN NULL ELSE failing_since END,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+webhookColumns,
+			chi.URLParam(r, "id"), req.URL, req.Events, req.Active), &webhook)
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to update webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// DELETE /api/settings/webhooks/{id}
+// Pending deliveries and the delivery log go with it.
+func (h *SettingsHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var deleted int64
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(r.Context(), "DELETE FROM webhooks WHERE id = $1", chi.URLParam(r, "id"))
+		deleted = tag.RowsAffected()
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete webhook: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// POST /api/settings/webhooks/{id}/rotate-secret
+// Returns the new secret; like at creation, this is the only time it is shown.
+func (h *SettingsHandler) RotateWebhookSecret(w http.ResponseWriter, r *http.Request) {
+	var req RotateSecretRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	overlap := webhooks.DefaultSecretOverlap
+	if req.OverlapHours != nil {
+		overlap = time.Duration(*req.OverlapHours) * time.Hour
+		if overlap < 0 || overlap > webhooks.MaxSecretOverlap {
+			http.Error(w, "overlap_hours must be between 0 and 168", http.StatusBadRequest)
+			return
+		}
+	}
+
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	secretKey, err := newWebhookSecret()
+	if err != nil {
+		http.Error(w, "Failed to generate secret", http.StatusInternalServerError)
+		return
+	}
+
+	var webhook WebhookResponse
+	err = database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		return scanWebhook(tx.QueryRow(r.Context(), `
+			UPDATE webhooks SET
+				previous_secret_key = CASE WHEN $3::float8 > 0 THEN secret_key END,
+				previous_secret_expires_at = CASE WHEN $3::float8 > 0 THEN NOW() + make_interval(secs => $3::float8) END,
+				secret_key = $2,
+				updated_at = NOW()
+			WHERE id = $1
+			RETURNING `+webhookColumns,
+			chi.URLParam(r, "id"), secretKey, overlap.Seconds()), &webhook)
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to rotate secret: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	webhook.SecretKey = secretKey
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(webhook)
+}
+
+// POST /api/settings/webhooks/{id}/test
+// Sends a webhook.test event synchronously; like a redelivery, a failing
+// receiver is still a 200 with the outcome in the body.
+func (h *SettingsHandler) TestWebhook(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	res, err := webhooks.SendTest(r.Context(), tenantID, chi.URLParam(r, "id"))
+	if err == webhooks.ErrWebhookNotFound {
+		http.Error(w, "Webhook not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to send test event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"delivered": res.OK(),
+		"data":      res,
+	})
+}
+
+// webhookColumns and scanWebhook never read secret_key: secrets are only
+// returned by the handlers that create them.
+const webhookColumns = `id, url, events, active, CASE WHEN previous_secret_expires_at > NOW() THEN previous_secret_expires_at END,
+	disabled_at, disabled_reason, consecutive_failures`
+
+func scanWebhook(row pgx.Row, wh *WebhookResponse) error {
+	return row.Scan(&wh.ID, &wh.URL, &wh.Events, &wh.Active, &wh.PreviousSecretExpiresAt,
+		&wh.DisabledAt, &wh.DisabledReason, &wh.ConsecutiveFailures)
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
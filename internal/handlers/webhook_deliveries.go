@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"rental-saas/internal/database"
+	"rental-saas/internal/middleware"
+	"rental-saas/internal/webhooks"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5"
+)
+
+type WebhookDelivery struct {
+	ID            string                   `json:"id"`
+	EventID       string                   `json:"event_id"`
+	EventType     string                   `json:"event_type"`
+	Status        string                   `json:"status"`
+	Attempts      int                      `json:"attempts"`
+	NextAttemptAt *time.Time               `json:"next_attempt_at,omitempty"`
+	LastError     *string                  `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time               `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time                `json:"created_at"`
+	Payload       json.RawMessage          `json:"payload,omitempty"`
+	AttemptLog    []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Attempt         int       `json:"attempt"`
+	Manual          bool      `json:"manual"`
+	StatusCode      *int      `json:"status_code,omitempty"`
+	LatencyMS       int       `json:"latency_ms"`
+	ResponseSnippet *string   `json:"response_snippet,omitempty"`
+	Error           *string   `json:"error,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// GET /api/settings/webhooks/{id}/deliveries?status=failed&limit=50
+func (h *SettingsHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != "pending" && status != "succeeded" && status != "failed" {
+		http.Error(w, "status must be pending, succeeded or failed", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	deliveries := []WebhookDelivery{}
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), `
+			SELECT d.id, d.event_id, e.event_type, d.status, d.attempts,
+			       CASE WHEN d.status = 'pending' THEN d.next_attempt_at END,
+			       d.last_error, d.delivered_at, d.created_at
+			FROM webhook_deliveries d
+			JOIN webhook_events e ON e.id = d.event_id
+			WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2)
+			ORDER BY d.created_at DESC
+			LIMIT $3
+		`, chi.URLParam(r, "id"), status, limit)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var d WebhookDelivery
+			if err := rows.Scan(&d.ID, &d.EventID, &d.EventType, &d.Status, &d.Attempts,
+				&d.NextAttemptAt, &d.LastError, &d.DeliveredAt, &d.CreatedAt); err != nil {
+				return err
+			}
+			deliveries = append(deliveries, d)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to list deliveries: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   deliveries,
+	})
+}
+
+// GET /api/settings/webhooks/{id}/deliveries/{deliveryID}
+// Returns the delivery with its payload and every attempt.
+func (h *SettingsHandler) GetDelivery(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	var d WebhookDelivery
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(r.Context(), `
+			SELECT d.id, d.event_id, e.event_type, d.status, d.attempts,
+			       CASE WHEN d.status = 'pending' THEN d.next_attempt_at END,
+			       d.last_error, d.delivered_at, d.created_at, e.payload
+			FROM webhook_deliveries d
+			JOIN webhook_events e ON e.id = d.event_id
+			WHERE d.id = $1 AND d.webhook_id = $2
+		`, chi.URLParam(r, "deliveryID"), chi.URLParam(r, "id")).Scan(&d.ID, &d.EventID, &d.EventType, &d.Status,
+			&d.Attempts, &d.NextAttemptAt, &d.LastError, &d.DeliveredAt, &d.CreatedAt, &d.Payload)
+		if err != nil {
+			return err
+		}
+
+		rows, err := tx.Query(r.Context(), `
+			SELECT attempt, manual, status_code, latency_ms, response_snippet, error, created_at
+			FROM webhook_delivery_attempts
+			WHERE delivery_id = $1
+			ORDER BY created_at
+		`, d.ID)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var a WebhookDeliveryAttempt
+			if err := rows.Scan(&a.Attempt, &a.Manual, &a.StatusCode, &a.LatencyMS, &a.ResponseSnippet, &a.Error, &a.CreatedAt); err != nil {
+				return err
+			}
+			d.AttemptLog = append(d.AttemptLog, a)
+		}
+		return rows.Err()
+	})
+	if err == pgx.ErrNoRows {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to load delivery: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   d,
+	})
+}
+
+// POST /api/settings/webhooks/{id}/deliveries/{deliveryID}/redeliver
+// Sends the delivery again synchronously and returns the outcome. The receiver
+// failing is still a 200 here; the attempt is in the response and the log.
+func (h *SettingsHandler) RedeliverDelivery(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := r.Context().Value(middleware.TenantKey).(string)
+	if !ok {
+		http.Error(w, "Tenant context missing", http.StatusInternalServerError)
+		return
+	}
+
+	// Make sure the delivery belongs to the endpoint in the URL
+	deliveryID := chi.URLParam(r, "deliveryID")
+	err := database.RunInTenantScope(r.Context(), tenantID, func(tx pgx.Tx) error {
+		var exists bool
+		err := tx.QueryRow(r.Context(), `
+			SELECT EXISTS (SELECT 1 FROM webhook_deliveries WHERE id = $1 AND webhook_id = $2)
+		`, deliveryID, chi.URLParam(r, "id")).Scan(&exists)
+		if err == nil && !exists {
+			return webhooks.ErrDeliveryNotFound
+		}
+		return err
+	})
+	var res webhooks.Result
+	if err == nil {
+		res, err = webhooks.Redeliver(r.Context(), tenantID, deliveryID)
+	}
+	if err == webhooks.ErrDeliveryNotFound {
+		http.Error(w, "Delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to redeliver: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "success",
+		"delivered": res.OK(),
+		"data":      res,
+	})
+}
@@ -2,12 +2,20 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
 	"rental-saas/internal/database"
-	"rental-saas/internal/models"
+	"rental-saas/internal/extras"
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+	"rental-saas/internal/vehicleclass"
+	"rental-saas/internal/webhooks"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
@@ -26,10 +34,18 @@ type PublicCar struct {
 	Model      string    `json:"model"`
 	Year       int       `json:"year"`
 	ImageURL   string    `json:"image_url"`
-	PriceCents int       `json:"price_cents"`
+	PriceCents int       `json:"price_cents"` // base daily rate
+
+	// With dates: what the rental will cost, as booking it would charge
+	Quote *pricing.Quote `json:"quote,omitempty"`
 }
 
-// GET /api/public/cars?tenant_id=...
+// GET /api/public/cars?tenant_id=...[&start_date=...&end_date=...][&branch_id=...[&dropoff_branch_id=...]]
+// Without dates every car that is not in maintenance and whose mandatory
+// documents are valid today is listed; with dates only cars free for the whole
+// range, compliant until it ends and bookable for its length are, each with a quote.
+// branch_id keeps the cars at that branch (at pickup, with dates); quotes
+// include the one-way fee to dropoff_branch_id.
 func (h *WidgetHandler) GetPublicCars(w http.ResponseWriter, r *http.Request) {
 	tenantID := r.URL.Query().Get("tenant_id")
 	if tenantID == "" {
@@ -42,6 +58,18 @@ func (h *WidgetHandler) GetPublicCars(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	var start, end time.Time
+	if r.URL.Query().Get("start_date") != "" || r.URL.Query().Get("end_date") != "" {
+		var err1, err2 error
+		start, err1 = time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+		end, err2 = time.Parse(time.RFC3339, r.URL.Query().Get("end_date"))
+		if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+			http.Error(w, "Invalid start_date/end_date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	route := routeFromQuery(r)
 	var cars []PublicCar
 
 	// Resolve Schema Name
@@ -53,28 +81,98 @@ func (h *WidgetHandler) GetPublicCars(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
-		rows, err := tx.Query(r.Context(), `
-			SELECT id, make, model, year, daily_rate_cents, image_url 
-			FROM cars 
-			WHERE status = 'available'
-		`)
+		query := `
+			SELECT id, make, model, COALESCE(year, 0), daily_rate_cents, COALESCE(image_url, '')
+			FROM cars
+			WHERE status <> 'maintenance'
+		`
+		var args []interface{}
+		var oneWayFee *pricing.Line
+		if !start.IsZero() {
+			if err := branch.CheckHours(r.Context(), tx, route, start, end); err != nil {
+				return err
+			}
+			fee, ok, err := branch.OneWayFee(r.Context(), tx, route)
+			if err != nil {
+				return err
+			}
+			if ok {
+				oneWayFee = &fee
+			}
+
+			ids, err := availability.FreeCarIDs(r.Context(), tx, start, end)
+			if err != nil {
+				return err
+			}
+			if route.PickupBranchID != nil {
+				if ids, err = branch.CarsAt(r.Context(), tx, ids, *route.PickupBranchID, start); err != nil {
+					return err
+				}
+			}
+			query += " AND id = ANY($1)"
+			args = append(args, ids)
+		} else {
+			ids, err := availability.NonCompliantCarIDs(r.Context(), tx, time.Now())
+			if err != nil {
+				return err
+			}
+			query += " AND NOT (id = ANY($1))"
+			args = append(args, ids)
+			if route.PickupBranchID != nil {
+				if _, err := branch.Load(r.Context(), tx, *route.PickupBranchID); err != nil {
+					return err
+				}
+				query += " AND branch_id = $2"
+				args = append(args, *route.PickupBranchID)
+			}
+		}
+
+		rows, err := tx.Query(r.Context(), query, args...)
 		if err != nil {
 			return err
 		}
-		defer rows.Close()
-
+		var listed []PublicCar
 		for rows.Next() {
 			var c PublicCar
 			if err := rows.Scan(&c.ID, &c.Make, &c.Model, &c.Year, &c.PriceCents, &c.ImageURL); err != nil {
+				rows.Close()
 				return err
 			}
+			listed = append(listed, c)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil || start.IsZero() {
+			cars = listed
+			return err
+		}
+
+		rules, err := pricing.LoadRules(r.Context(), tx)
+		if err != nil {
+			return err
+		}
+		taxes, err := tax.Load(r.Context(), tx)
+		if err != nil {
+			return err
+		}
+		for _, c := range listed {
+			quote := pricing.Calculate(rules, pricing.Request{
+				CarID: c.ID.String(), DailyRateCents: c.PriceCents, Start: start, End: end, Channel: pricing.ChannelWidget,
+			})
+			if quote.Bookable() != nil {
+				continue
+			}
+			if oneWayFee != nil {
+				quote.Add(*oneWayFee)
+			}
+			taxes.Apply(&quote)
+			c.Quote = &quote
 			cars = append(cars, c)
 		}
 		return nil
 	})
 
 	if err != nil {
-		http.Error(w, "Failed to fetch cars: "+err.Error(), http.StatusInternalServerError)
+		writeRouteError(w, err, "Failed to fetch cars: ")
 		return
 	}
 
@@ -85,12 +183,76 @@ func (h *WidgetHandler) GetPublicCars(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// GET /api/public/quote?tenant_id=...&car_id=...|class_id=...&start_date=...&end_date=...[&branch_id=...&dropoff_branch_id=...]
+// The price the widget shows before booking a car or a vehicle class, with any
+// one-way fee. A quote whose days are below its minimum_days cannot be booked.
+func (h *WidgetHandler) GetPublicQuote(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant_id")
+	carID, classID := r.URL.Query().Get("car_id"), r.URL.Query().Get("class_id")
+	if _, err := uuid.Parse(tenantID); err != nil {
+		http.Error(w, "Invalid tenant_id", http.StatusBadRequest)
+		return
+	}
+	if classID != "" {
+		if _, err := uuid.Parse(classID); err != nil || carID != "" {
+			http.Error(w, "Invalid class_id", http.StatusBadRequest)
+			return
+		}
+	} else if _, err := uuid.Parse(carID); err != nil {
+		http.Error(w, "Invalid car_id", http.StatusBadRequest)
+		return
+	}
+	start, err1 := time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+	end, err2 := time.Parse(time.RFC3339, r.URL.Query().Get("end_date"))
+	if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+		http.Error(w, "Invalid start_date/end_date", http.StatusBadRequest)
+		return
+	}
+
+	var schemaName string
+	err := database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	route := routeFromQuery(r)
+	var quote pricing.Quote
+	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
+		if err := branch.CheckHours(r.Context(), tx, route, start, end); err != nil {
+			return err
+		}
+		var err error
+		quote, _, err = quoteRental(r.Context(), tx, carID, classID, start, end, pricing.ChannelWidget, route, nil)
+		return err
+	})
+	if err == pricing.ErrCarNotFound || err == vehicleclass.ErrNotFound {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		writeRouteError(w, err, "Failed to price rental: ")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   quote,
+	})
+}
+
 type PublicBookRequest struct {
+	// One of: a car, or a vehicle class from /api/public/classes
 	CarID         string    `json:"car_id"`
+	ClassID       string    `json:"class_id"`
 	StartDate     time.Time `json:"start_date"`
 	EndDate       time.Time `json:"end_date"`
 	CustomerEmail string    `json:"customer_email"`
 	CustomerName  string    `json:"customer_name"`
+	branch.Route
+	// Optional: extras from /api/public/extras
+	Extras []extras.Selection `json:"extras"`
 }
 
 // POST /api/public/book?tenant_id=...
@@ -110,10 +272,27 @@ func (h *WidgetHandler) PublicBook(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if err := availability.ValidateRange(req.StartDate, req.EndDate); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !strings.Contains(req.CustomerEmail, "@") || strings.TrimSpace(req.CustomerName) == "" {
+		http.Error(w, "customer_name and customer_email are required", http.StatusBadRequest)
+		return
+	}
+	if (req.CarID == "") == (req.ClassID == "") {
+		http.Error(w, "Exactly one of car_id and class_id is required", http.StatusBadRequest)
+		return
+	}
+	sels, err := extras.Normalize(req.Extras)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
 	// Resolve Schema Name
 	var schemaName string
-	err := database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
+	err = database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
 	if err != nil {
 		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
@@ -122,59 +301,306 @@ func (h *WidgetHandler) PublicBook(w http.ResponseWriter, r *http.Request) {
 	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
 		// 1. Find or Create Customer
 		var customerID string
-		err := tx.QueryRow(r.Context(), "SELECT id FROM customers WHERE email = $1", req.CustomerEmail).Scan(&customerID)
+		email := strings.ToLower(strings.TrimSpace(req.CustomerEmail))
+		err := tx.QueryRow(r.Context(), "SELECT id FROM customers WHERE lower(email) = $1", email).Scan(&customerID)
 		if err == pgx.ErrNoRows {
-			// Create new customer
-			// Split name for simplicity
-			firstName := req.CustomerName
-			lastName := "" 
-			
+			// Create new customer from the widget's single name field
+			firstName, lastName := splitName(req.CustomerName)
+
 			err = tx.QueryRow(r.Context(), `
 				INSERT INTO customers (email, first_name, last_name) 
 				VALUES ($1, $2, $3) 
 				RETURNING id
-			`, req.CustomerEmail, firstName, lastName).Scan(&customerID)
+			`, email, firstName, lastName).Scan(&customerID)
 			if err != nil {
 				return fmt.Errorf("failed to create customer: %w", err)
 			}
+			if err := webhooks.EmitCustomerCreated(r.Context(), tx, customerID, "widget"); err != nil {
+				return err
+			}
 		} else if err != nil {
 			return fmt.Errorf("failed to lookup customer: %w", err)
 		}
 
-		// 2. Pessimistic Locking for Car Availability
-		var status models.CarStatus
-		err = tx.QueryRow(r.Context(), "SELECT status FROM cars WHERE id = $1 FOR UPDATE", req.CarID).Scan(&status)
+		// 2. Lock the car (or class) and the extras and check the requested range
+		// is free, then price them exactly as the listing did
+		rt, err := reserveRental(r.Context(), tx, req.CarID, req.ClassID, req.StartDate, req.EndDate, req.Route, sels, pricing.ChannelWidget)
 		if err != nil {
-			return fmt.Errorf("car not found: %w", err)
+			return err
 		}
-
-		if status != models.CarStatusAvailable {
-			return fmt.Errorf("car is not available")
+		if err := rt.Quote.Bookable(); err != nil {
+			return err
 		}
 
-		// 3. Create Booking (Pending)
-		_, err = tx.Exec(r.Context(), `
-			INSERT INTO bookings (car_id, customer_id, start_time, end_time, status)
-			VALUES ($1, $2, $3, $4, 'pending')
-		`, req.CarID, customerID, req.StartDate, req.EndDate)
+		// 3. Create Booking (Pending). The car status only changes at pickup.
+		var bookingID string
+		err = tx.QueryRow(r.Context(), `
+			INSERT INTO bookings (car_id, class_id, customer_id, start_time, end_time, status, total_amount_cents, pickup_branch_id, dropoff_branch_id)
+			VALUES (NULLIF($1, '')::uuid, NULLIF($2, '')::uuid, $3, $4, $5, 'pending', $6, $7, $8)
+			RETURNING id
+		`, req.CarID, req.ClassID, customerID, req.StartDate, req.EndDate, rt.Quote.TotalCents, rt.Route.PickupBranchID, rt.Route.DropoffBranchID).Scan(&bookingID)
+		if availability.IsConflict(err) {
+			return availability.ErrUnavailable
+		}
 		if err != nil {
 			return fmt.Errorf("failed to create booking: %w", err)
 		}
+		if err := rt.save(r.Context(), tx, bookingID); err != nil {
+			return err
+		}
+
+		return webhooks.EmitBooking(r.Context(), tx, webhooks.EventBookingCreated, bookingID, "widget")
+	})
+
+	if err != nil {
+		writeAvailabilityError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Booking request received"})
+}
+
+// PublicClass is a vehicle class as the widget offers it.
+type PublicClass struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Seats       *int   `json:"seats"`
+	ImageURL    string `json:"image_url"`
+	PriceCents  int    `json:"price_cents"` // base daily rate
+
+	// With dates: what the rental will cost, as booking it would charge
+	Quote *pricing.Quote `json:"quote,omitempty"`
+}
+
+// GET /api/public/classes?tenant_id=...[&start_date=...&end_date=...][&branch_id=...[&dropoff_branch_id=...]]
+// Without dates every active class is listed; with dates only those with a car
+// left for the whole range (at branch_id, if given) and bookable for its
+// length are, each with a quote including any one-way fee to dropoff_branch_id.
+func (h *WidgetHandler) GetPublicClasses(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant_id")
+	if _, err := uuid.Parse(tenantID); err != nil {
+		http.Error(w, "Invalid tenant_id", http.StatusBadRequest)
+		return
+	}
+
+	var start, end time.Time
+	if r.URL.Query().Get("start_date") != "" || r.URL.Query().Get("end_date") != "" {
+		var err1, err2 error
+		start, err1 = time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+		end, err2 = time.Parse(time.RFC3339, r.URL.Query().Get("end_date"))
+		if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+			http.Error(w, "Invalid start_date/end_date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var schemaName string
+	err := database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
 
-		// 4. Update Car Status
-		_, err = tx.Exec(r.Context(), "UPDATE cars SET status = $1 WHERE id = $2", models.CarStatusRented, req.CarID)
+	route := routeFromQuery(r)
+	classes := []PublicClass{}
+	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
+		listed, err := loadClasses(r, tx, true)
 		if err != nil {
-			return fmt.Errorf("failed to update car status: %w", err)
+			return err
+		}
+		if start.IsZero() {
+			for _, c := range listed {
+				classes = append(classes, publicClass(c))
+			}
+			return nil
 		}
 
+		if err := branch.CheckHours(r.Context(), tx, route, start, end); err != nil {
+			return err
+		}
+		for _, c := range listed {
+			a, err := vehicleclass.Check(r.Context(), tx, c.ID, start, end, route.PickupBranchID)
+			if err != nil {
+				return err
+			}
+			if a.Available < 1 {
+				continue
+			}
+			quote, _, err := quoteRental(r.Context(), tx, "", c.ID, start, end, pricing.ChannelWidget, route, nil)
+			if err != nil {
+				return err
+			}
+			if quote.Bookable() != nil {
+				continue
+			}
+			pc := publicClass(c)
+			pc.Quote = &quote
+			classes = append(classes, pc)
+		}
 		return nil
 	})
+	if err != nil {
+		writeRouteError(w, err, "Failed to fetch classes: ")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   classes,
+	})
+}
+
+func publicClass(c vehicleclass.Class) PublicClass {
+	return PublicClass{ID: c.ID, Name: c.Name, Description: c.Description, Seats: c.Seats, ImageURL: c.ImageURL, PriceCents: c.DailyRateCents}
+}
+
+// PublicExtra is an extra as the widget offers it.
+type PublicExtra struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	PriceCents  int    `json:"price_cents"`
+	PricePer    string `json:"price_per"`
+
+	// With dates: units left (null if unlimited) and what one costs for the rental
+	Left *int          `json:"left,omitempty"`
+	Line *pricing.Line `json:"line,omitempty"`
+}
 
+// GET /api/public/extras?tenant_id=...[&start_date=...&end_date=...]
+// Every active extra; with dates only those with a unit left for the whole
+// range, each with how many are left and the charge for one.
+func (h *WidgetHandler) GetPublicExtras(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant_id")
+	if _, err := uuid.Parse(tenantID); err != nil {
+		http.Error(w, "Invalid tenant_id", http.StatusBadRequest)
+		return
+	}
+
+	var start, end time.Time
+	if r.URL.Query().Get("start_date") != "" || r.URL.Query().Get("end_date") != "" {
+		var err1, err2 error
+		start, err1 = time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+		end, err2 = time.Parse(time.RFC3339, r.URL.Query().Get("end_date"))
+		if err1 != nil || err2 != nil || availability.ValidateRange(start, end) != nil {
+			http.Error(w, "Invalid start_date/end_date", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var schemaName string
+	err := database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Tenant not found", http.StatusNotFound)
 		return
 	}
 
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "Booking request received"})
+	list := []PublicExtra{}
+	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
+		active, err := loadExtras(r, tx, true)
+		if err != nil {
+			return err
+		}
+		for _, e := range active {
+			pe := PublicExtra{ID: e.ID, Name: e.Name, Description: e.Description, PriceCents: e.PriceCents, PricePer: e.PricePer}
+			if !start.IsZero() {
+				booked, err := extras.Booked(r.Context(), tx, e.ID, start, end)
+				if err != nil {
+					return err
+				}
+				if pe.Left = e.Left(booked); pe.Left != nil && *pe.Left < 1 {
+					continue
+				}
+				line := e.Line(1, pricing.Days(start, end))
+				pe.Line = &line
+			}
+			list = append(list, pe)
+		}
+		return nil
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch extras: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   list,
+	})
+}
+
+// GET /api/public/branches?tenant_id=...
+// The active branches the widget offers for pickup and drop-off.
+func (h *WidgetHandler) GetPublicBranches(w http.ResponseWriter, r *http.Request) {
+	tenantID := r.URL.Query().Get("tenant_id")
+	if _, err := uuid.Parse(tenantID); err != nil {
+		http.Error(w, "Invalid tenant_id", http.StatusBadRequest)
+		return
+	}
+
+	var schemaName string
+	err := database.DB.QueryRow(r.Context(), "SELECT schema_name FROM public.tenants WHERE id = $1", tenantID).Scan(&schemaName)
+	if err != nil {
+		http.Error(w, "Tenant not found", http.StatusNotFound)
+		return
+	}
+
+	branches := []branch.Branch{}
+	err = database.RunInTenantScope(r.Context(), schemaName, func(tx pgx.Tx) error {
+		rows, err := tx.Query(r.Context(), "SELECT "+branch.Columns+" FROM branches WHERE active ORDER BY name")
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var b branch.Branch
+			if err := branch.Scan(rows, &b); err != nil {
+				return err
+			}
+			branches = append(branches, b)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		http.Error(w, "Failed to fetch branches: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   branches,
+	})
+}
+
+// routeFromQuery reads branch_id (pickup) and dropoff_branch_id, which
+// defaults to the pickup branch.
+func routeFromQuery(r *http.Request) branch.Route {
+	var route branch.Route
+	if id := r.URL.Query().Get("branch_id"); id != "" {
+		route.PickupBranchID = &id
+	}
+	if id := r.URL.Query().Get("dropoff_branch_id"); id != "" {
+		route.DropoffBranchID = &id
+	} else {
+		route.DropoffBranchID = route.PickupBranchID
+	}
+	return route
+}
+
+// writeRouteError reports a bad branch choice as such and anything else as a
+// server error prefixed with msg.
+func writeRouteError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, branch.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, branch.ErrClosed), errors.Is(err, branch.ErrOneWayNotOffered):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, msg+err.Error(), http.StatusInternalServerError)
+	}
 }
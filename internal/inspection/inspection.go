@@ -0,0 +1,144 @@
+// Package inspection records the damage found on a car when it is handed over
+// and when it comes back. Reports are immutable once saved; Diff compares a
+// return report with the pickup report so a customer is only billed for
+// damage that happened during their rental.
+package inspection
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Report kinds.
+const (
+	KindPickup = "pickup"
+	KindReturn = "return"
+)
+
+type Severity string
+
+const (
+	SeverityMinor    Severity = "minor"    // cosmetic: scratches, scuffs
+	SeverityModerate Severity = "moderate" // dents, cracked lights; needs a repair
+	SeveritySevere   Severity = "severe"   // not roadworthy until repaired
+)
+
+func (s Severity) rank() int {
+	switch s {
+	case SeverityMinor:
+		return 1
+	case SeverityModerate:
+		return 2
+	case SeveritySevere:
+		return 3
+	}
+	return 0
+}
+
+// NeedsRepair reports whether damage of this severity takes the car out of service.
+func (s Severity) NeedsRepair() bool {
+	return s.rank() >= SeverityModerate.rank()
+}
+
+// Locations are the panels and areas damage is recorded against. Items are
+// matched between reports by location, so free text is not accepted.
+var Locations = []string{
+	"front_bumper", "rear_bumper", "hood", "roof", "trunk",
+	"windshield", "rear_window",
+	"front_left_door", "front_right_door", "rear_left_door", "rear_right_door",
+	"left_fender", "right_fender", "left_quarter_panel", "right_quarter_panel",
+	"left_mirror", "right_mirror", "headlights", "taillights",
+	"wheels", "tyres", "interior", "underbody", "other",
+}
+
+var ErrAlreadyRecorded = errors.New("inspection already recorded for this booking")
+
+type Photo struct {
+	ID           string    `json:"id"`
+	DamageItemID *string   `json:"damage_item_id,omitempty"`
+	URL          string    `json:"url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Item struct {
+	ID                 string   `json:"id"`
+	Location           string   `json:"location"`
+	Severity           Severity `json:"severity"`
+	Description        string   `json:"description"`
+	EstimatedCostCents int      `json:"estimated_cost_cents"`
+	// Set on return items by Diff: not on the pickup report, or worse than it was
+	New bool `json:"new"`
+}
+
+func (i Item) Validate() error {
+	if !validLocation(i.Location) {
+		return fmt.Errorf("unknown location %q", i.Location)
+	}
+	if i.Severity.rank() == 0 {
+		return errors.New("severity must be minor, moderate or severe")
+	}
+	if i.EstimatedCostCents < 0 {
+		return errors.New("estimated_cost_cents cannot be negative")
+	}
+	return nil
+}
+
+// Label is the item as shown to customers, e.g. "Front left door (moderate)".
+func (i Item) Label() string {
+	loc := strings.ReplaceAll(i.Location, "_", " ")
+	return fmt.Sprintf("%s%s (%s)", strings.ToUpper(loc[:1]), loc[1:], i.Severity)
+}
+
+type Report struct {
+	ID          string    `json:"id"`
+	BookingID   string    `json:"booking_id"`
+	CarID       string    `json:"car_id"`
+	Kind        string    `json:"kind"`
+	Notes       string    `json:"notes"`
+	InspectedBy *string   `json:"inspected_by,omitempty"`
+	InspectedAt time.Time `json:"inspected_at"`
+	Items       []Item    `json:"items"`
+	Photos      []Photo   `json:"photos"`
+}
+
+// Diff marks each return item that is new since pickup and returns those.
+// An item is pre-existing when the pickup report has damage at the same
+// location that was at least as severe. Without a pickup report every return
+// item is new.
+func Diff(pickup, ret []Item) []Item {
+	worst := map[string]int{}
+	for _, p := range pickup {
+		if r := p.Severity.rank(); r > worst[p.Location] {
+			worst[p.Location] = r
+		}
+	}
+
+	var added []Item
+	for i := range ret {
+		ret[i].New = ret[i].Severity.rank() > worst[ret[i].Location]
+		if ret[i].New {
+			added = append(added, ret[i])
+		}
+	}
+	return added
+}
+
+// Cost sums the estimated cost of items.
+func Cost(items []Item) int {
+	total := 0
+	for _, i := range items {
+		total += i.EstimatedCostCents
+	}
+	return total
+}
+
+func validLocation(loc string) bool {
+	for _, l := range Locations {
+		if l == loc {
+			return true
+		}
+	}
+	return false
+}
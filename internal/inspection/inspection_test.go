@@ -0,0 +1,53 @@
+package inspection
+
+import "testing"
+
+func TestDiffBillsOnlyNewOrWorseDamage(t *testing.T) {
+	pickup := []Item{
+		{Location: "front_bumper", Severity: SeverityModerate, EstimatedCostCents: 30000},
+		{Location: "left_mirror", Severity: SeverityMinor},
+	}
+	ret := []Item{
+		{Location: "front_bumper", Severity: SeverityMinor, EstimatedCostCents: 5000},     // already worse at pickup
+		{Location: "left_mirror", Severity: SeveritySevere, EstimatedCostCents: 20000},    // got worse
+		{Location: "rear_left_door", Severity: SeverityMinor, EstimatedCostCents: 12000},  // new
+		{Location: "front_bumper", Severity: SeverityModerate, EstimatedCostCents: 30000}, // unchanged
+	}
+
+	added := Diff(pickup, ret)
+	if len(added) != 2 || Cost(added) != 32000 {
+		t.Fatalf("added = %+v", added)
+	}
+	want := []bool{false, true, true, false}
+	for i, item := range ret {
+		if item.New != want[i] {
+			t.Errorf("item %d (%s) new = %v", i, item.Location, item.New)
+		}
+	}
+}
+
+func TestDiffWithoutPickupReport(t *testing.T) {
+	ret := []Item{{Location: "roof", Severity: SeverityMinor, EstimatedCostCents: 100}}
+	if added := Diff(nil, ret); len(added) != 1 || !ret[0].New {
+		t.Errorf("added = %+v", added)
+	}
+}
+
+func TestItemValidate(t *testing.T) {
+	ok := Item{Location: "hood", Severity: SeverityMinor, EstimatedCostCents: 100}
+	if err := ok.Validate(); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []Item{
+		{Location: "bonnet", Severity: SeverityMinor},
+		{Location: "hood", Severity: "cosmetic"},
+		{Location: "hood", Severity: SeverityMinor, EstimatedCostCents: -1},
+	} {
+		if bad.Validate() == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	if got := ok.Label(); got != "Hood (minor)" {
+		t.Errorf("Label() = %q", got)
+	}
+}
@@ -0,0 +1,167 @@
+package inspection
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNotFound     = errors.New("inspection not found")
+	ErrItemNotFound = errors.New("damage item not found on this inspection")
+)
+
+// Create saves a report and its items, filling in their IDs.
+func Create(ctx context.Context, tx pgx.Tx, r *Report) error {
+	err := tx.QueryRow(ctx, `
+		INSERT INTO inspections (booking_id, car_id, kind, notes, inspected_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (booking_id, kind) DO NOTHING
+		RETURNING id, inspected_at
+	`, r.BookingID, r.CarID, r.Kind, r.Notes, r.InspectedBy).Scan(&r.ID, &r.InspectedAt)
+	if err == pgx.ErrNoRows {
+		return ErrAlreadyRecorded
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := range r.Items {
+		item := &r.Items[i]
+		err := tx.QueryRow(ctx, `
+			INSERT INTO damage_items (inspection_id, position, location, severity, description, estimated_cost_cents)
+			VALUES ($1, $2, $3, $4, $5, $6)
+			RETURNING id
+		`, r.ID, i, item.Location, item.Severity, item.Description, item.EstimatedCostCents).Scan(&item.ID)
+		if err != nil {
+			return err
+		}
+	}
+	if r.Items == nil {
+		r.Items = []Item{}
+	}
+	r.Photos = []Photo{}
+	return nil
+}
+
+// ForBooking loads a booking's pickup and return reports; either may be nil.
+// When both exist the return items are marked by Diff.
+func ForBooking(ctx context.Context, tx pgx.Tx, bookingID string) (pickup, ret *Report, err error) {
+	rows, err := tx.Query(ctx, `
+		SELECT id, booking_id, car_id, kind, notes, inspected_by, inspected_at
+		FROM inspections WHERE booking_id = $1
+	`, bookingID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var reports []*Report
+	for rows.Next() {
+		r := &Report{Items: []Item{}, Photos: []Photo{}}
+		if err := rows.Scan(&r.ID, &r.BookingID, &r.CarID, &r.Kind, &r.Notes, &r.InspectedBy, &r.InspectedAt); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		reports = append(reports, r)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range reports {
+		if err := loadDetails(ctx, tx, r); err != nil {
+			return nil, nil, err
+		}
+		if r.Kind == KindPickup {
+			pickup = r
+		} else {
+			ret = r
+		}
+	}
+	if ret != nil {
+		var before []Item
+		if pickup != nil {
+			before = pickup.Items
+		}
+		Diff(before, ret.Items)
+	}
+	return pickup, ret, nil
+}
+
+func loadDetails(ctx context.Context, tx pgx.Tx, r *Report) error {
+	rows, err := tx.Query(ctx, `
+		SELECT id, location, severity, description, estimated_cost_cents
+		FROM damage_items WHERE inspection_id = $1 ORDER BY position
+	`, r.ID)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var i Item
+		if err := rows.Scan(&i.ID, &i.Location, &i.Severity, &i.Description, &i.EstimatedCostCents); err != nil {
+			rows.Close()
+			return err
+		}
+		r.Items = append(r.Items, i)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = tx.Query(ctx, `
+		SELECT id, damage_item_id, url, created_at
+		FROM inspection_photos WHERE inspection_id = $1 ORDER BY created_at
+	`, r.ID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p Photo
+		if err := rows.Scan(&p.ID, &p.DamageItemID, &p.URL, &p.CreatedAt); err != nil {
+			return err
+		}
+		r.Photos = append(r.Photos, p)
+	}
+	return rows.Err()
+}
+
+// Exists returns ErrNotFound or ErrItemNotFound unless the inspection, and the
+// item if one is given, exist. Photos go to storage before they are recorded,
+// so the upload checks its target first.
+func Exists(ctx context.Context, tx pgx.Tx, inspectionID, itemID string) error {
+	var found bool
+	err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM inspections WHERE id = $1)", inspectionID).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if itemID == "" {
+		return nil
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM damage_items WHERE id = $1 AND inspection_id = $2)
+	`, itemID, inspectionID).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrItemNotFound
+	}
+	return nil
+}
+
+// AddPhoto records an uploaded photo against an inspection and optionally one of its items.
+func AddPhoto(ctx context.Context, tx pgx.Tx, inspectionID string, itemID *string, url string) (Photo, error) {
+	p := Photo{DamageItemID: itemID, URL: url}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO inspection_photos (inspection_id, damage_item_id, url)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at
+	`, inspectionID, itemID, url).Scan(&p.ID, &p.CreatedAt)
+	return p, err
+}
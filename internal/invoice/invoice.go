@@ -0,0 +1,231 @@
+// Package invoice issues a booking's invoices and credit notes as immutable
+// records. An invoice copies the booking's charges, customer and currency
+// when it is issued and takes the next number of a gap-free per-tenant
+// series, so later changes to the booking, the customer or the tax rates do
+// not alter it. A wrong invoice is never edited: a credit note referencing it
+// reverses it line by line, and a new invoice can be issued after that. A
+// refund is credited by a credit note for its amount, with the invoice's taxes
+// in proportion; an invoice is credited once its credit notes add up to it.
+package invoice
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+)
+
+const (
+	KindInvoice    = "invoice"
+	KindCreditNote = "credit_note"
+)
+
+// LineCredit is the line of a partial credit note that reverses part of an
+// invoice's charges; its taxes are reversed on tax lines.
+const LineCredit = "credit"
+
+var (
+	ErrNotFound        = errors.New("invoice not found")
+	ErrNotCompleted    = errors.New("only a completed booking can be invoiced")
+	ErrAlreadyInvoiced = errors.New("booking already has an invoice that has not been credited")
+	ErrAlreadyCredited = errors.New("invoice has already been credited")
+	ErrNotCreditable   = errors.New("only an invoice can be credited")
+	ErrCreditTooLarge  = errors.New("credit exceeds what is left to credit on the invoice")
+	ErrNothingCharged  = errors.New("booking has no charges or settled total to invoice")
+)
+
+// Settings is how a tenant's invoice and credit note numbers look.
+type Settings struct {
+	Prefix           string `json:"prefix"`
+	CreditNotePrefix string `json:"credit_note_prefix"`
+	// YearlyReset starts each series again at 1 every calendar year and puts
+	// the year in the number.
+	YearlyReset bool `json:"yearly_reset"`
+	// Digits is the minimum width of the sequence number, zero padded.
+	Digits int `json:"digits"`
+}
+
+// DefaultSettings are the settings of a tenant that has not saved any.
+func DefaultSettings() Settings {
+	return Settings{Prefix: "INV-", CreditNotePrefix: "CN-", YearlyReset: true, Digits: 4}
+}
+
+func (s Settings) Validate() error {
+	for _, p := range []string{s.Prefix, s.CreditNotePrefix} {
+		if len(p) > 20 || strings.ContainsAny(p, " \t\r\n/\\") {
+			return errors.New("prefixes must be at most 20 characters without spaces or slashes")
+		}
+	}
+	if s.Prefix == s.CreditNotePrefix {
+		return errors.New("invoices and credit notes need different prefixes")
+	}
+	if s.Digits < 1 || s.Digits > 10 {
+		return errors.New("digits must be between 1 and 10")
+	}
+	return nil
+}
+
+// Year is the series year of a document issued at t: its calendar year when
+// numbers reset yearly, 0 otherwise.
+func (s Settings) Year(t time.Time) int {
+	if s.YearlyReset {
+		return t.Year()
+	}
+	return 0
+}
+
+// Number formats the seq'th document of a kind in the given series year,
+// e.g. "INV-2026-0042", or "INV-0042" without a yearly reset.
+func (s Settings) Number(kind string, year, seq int) string {
+	prefix := s.Prefix
+	if kind == KindCreditNote {
+		prefix = s.CreditNotePrefix
+	}
+	if year != 0 {
+		prefix += fmt.Sprintf("%d-", year)
+	}
+	return fmt.Sprintf("%s%0*d", prefix, s.Digits, seq)
+}
+
+// Invoice is an invoice or a credit note as it was issued.
+type Invoice struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Number string `json:"number"`
+
+	BookingID string `json:"booking_id"`
+	// CreditsInvoiceID and CreditsNumber are the invoice a credit note reverses.
+	CreditsInvoiceID *string `json:"credits_invoice_id,omitempty"`
+	CreditsNumber    string  `json:"credits_number,omitempty"`
+	// CreditNoteIDs are the credit notes against an invoice, oldest first, and
+	// CreditedCents the total they gave back.
+	CreditNoteIDs []string `json:"credit_note_ids,omitempty"`
+	CreditedCents int      `json:"credited_cents,omitempty"`
+
+	CustomerName  string    `json:"customer_name"`
+	CustomerEmail string    `json:"customer_email"`
+	Vehicle       string    `json:"vehicle"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+
+	Currency      string         `json:"currency"`
+	SubtotalCents int            `json:"subtotal_cents"`
+	TaxCents      int            `json:"tax_cents"`
+	TotalCents    int            `json:"total_cents"`
+	Lines         []pricing.Line `json:"lines,omitempty"`
+
+	Reason   string    `json:"reason,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+// SetLines sets an invoice's lines and works out its totals from them: the
+// subtotal of the charges, the tax added on top and what was charged.
+func (inv *Invoice) SetLines(lines []pricing.Line) {
+	inv.Lines = lines
+	inv.SubtotalCents = 0
+	for _, l := range lines {
+		if l.Kind != pricing.LineTax {
+			inv.SubtotalCents += l.AmountCents
+		}
+	}
+	inv.TaxCents = tax.Amount(lines)
+	inv.TotalCents = pricing.Total(lines)
+}
+
+// Unitemised is the single line a booking completed before its charges were
+// itemised is invoiced with: the total it was settled at.
+func Unitemised(totalCents int) ([]pricing.Line, error) {
+	if totalCents <= 0 {
+		return nil, ErrNothingCharged
+	}
+	return []pricing.Line{{
+		Kind: pricing.LineRental, Description: "Rental", Quantity: 1,
+		UnitAmountCents: totalCents, AmountCents: totalCents,
+	}}, nil
+}
+
+// Credited reports whether an invoice's credit notes have given all of it back.
+func (inv Invoice) Credited() bool {
+	return len(inv.CreditNoteIDs) > 0 && inv.CreditedCents >= inv.TotalCents
+}
+
+// CreditNote returns a credit note for amountCents of inv, or for all that is
+// left to credit when amountCents is 0. Crediting a whole invoice reverses it
+// line by line; a part of one is a single credit line with each tax line of
+// the invoice in proportion. Number, ID and IssuedAt are left for the issuer.
+func CreditNote(inv Invoice, amountCents int, reason string) (Invoice, error) {
+	if inv.Kind != KindInvoice {
+		return Invoice{}, ErrNotCreditable
+	}
+	if inv.Credited() {
+		return Invoice{}, ErrAlreadyCredited
+	}
+	left := inv.TotalCents - inv.CreditedCents
+	if amountCents == 0 {
+		amountCents = left
+	}
+	if amountCents < 0 || amountCents > left {
+		return Invoice{}, ErrCreditTooLarge
+	}
+
+	id := inv.ID
+	cn := inv
+	cn.ID, cn.Number, cn.Kind = "", "", KindCreditNote
+	cn.CreditsInvoiceID, cn.CreditsNumber = &id, inv.Number
+	cn.CreditNoteIDs, cn.CreditedCents = nil, 0
+	cn.Reason = reason
+
+	if amountCents == inv.TotalCents {
+		cn.Lines = make([]pricing.Line, len(inv.Lines))
+		for i, l := range inv.Lines {
+			l.UnitAmountCents, l.AmountCents = -l.UnitAmountCents, -l.AmountCents
+			cn.Lines[i] = l
+		}
+		cn.SubtotalCents, cn.TaxCents, cn.TotalCents = -inv.SubtotalCents, -inv.TaxCents, -inv.TotalCents
+		return cn, nil
+	}
+
+	var taxLines []pricing.Line
+	taxCents := 0
+	for _, l := range inv.Lines {
+		if l.Kind != pricing.LineTax {
+			continue
+		}
+		l.AmountCents = -share(l.AmountCents, amountCents, inv.TotalCents)
+		l.UnitAmountCents = l.AmountCents
+		if !l.Included {
+			taxCents += l.AmountCents
+		}
+		taxLines = append(taxLines, l)
+	}
+	net := -amountCents - taxCents
+	cn.Lines = append([]pricing.Line{{
+		Kind: LineCredit, Description: "Credit: " + reason, Quantity: 1, UnitAmountCents: net, AmountCents: net,
+	}}, taxLines...)
+	cn.SubtotalCents, cn.TaxCents, cn.TotalCents = net, taxCents, -amountCents
+	return cn, nil
+}
+
+// share is part/whole of amount, rounded half away from zero.
+func share(amount, part, whole int) int {
+	if whole == 0 {
+		return 0
+	}
+	n := int64(amount) * int64(part)
+	half := int64(whole) / 2
+	if n < 0 {
+		return int((n - half) / int64(whole))
+	}
+	return int((n + half) / int64(whole))
+}
+
+// Title is the document's heading, e.g. "Invoice INV-2026-0042".
+func (inv Invoice) Title() string {
+	if inv.Kind == KindCreditNote {
+		return "Credit note " + inv.Number
+	}
+	return "Invoice " + inv.Number
+}
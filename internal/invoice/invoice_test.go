@@ -0,0 +1,164 @@
+package invoice
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"rental-saas/internal/pricing"
+)
+
+func TestNumber(t *testing.T) {
+	s := DefaultSettings()
+	cases := map[string]string{
+		s.Number(KindInvoice, 2026, 42):                           "INV-2026-0042",
+		s.Number(KindCreditNote, 2026, 7):                         "CN-2026-0007",
+		s.Number(KindInvoice, 0, 12345):                           "INV-12345",
+		Settings{Prefix: "", Digits: 1}.Number(KindInvoice, 0, 3): "3",
+	}
+	for got, want := range cases {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	if s.Year(at) != 2026 {
+		t.Errorf("yearly series year %d", s.Year(at))
+	}
+	s.YearlyReset = false
+	if s.Year(at) != 0 {
+		t.Errorf("continuous series year %d", s.Year(at))
+	}
+}
+
+func TestSettingsValidate(t *testing.T) {
+	cases := map[string]Settings{
+		"same prefix": {Prefix: "A-", CreditNotePrefix: "A-", Digits: 4},
+		"space":       {Prefix: "INV ", CreditNotePrefix: "CN-", Digits: 4},
+		"digits":      {Prefix: "INV-", CreditNotePrefix: "CN-", Digits: 0},
+	}
+	for name, s := range cases {
+		if s.Validate() == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if err := DefaultSettings().Validate(); err != nil {
+		t.Errorf("default rejected: %v", err)
+	}
+}
+
+func issued() Invoice {
+	inv := Invoice{ID: "inv-1", Kind: KindInvoice, Number: "INV-2026-0001", Currency: "eur",
+		CustomerName: "Ada Lovelace", CustomerEmail: "ada@example.com", Vehicle: "Fiat 500 (AB-123)",
+		IssuedAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)}
+	inv.SetLines([]pricing.Line{
+		{Kind: pricing.LineRental, Description: "Rental", Quantity: 3, UnitAmountCents: 5000, AmountCents: 15000},
+		{Kind: pricing.LineDamage, Description: "Damage", Quantity: 1, UnitAmountCents: 2000, AmountCents: 2000},
+		{Kind: pricing.LineTax, Description: "VAT 20% (included)", Quantity: 1, UnitAmountCents: 2833, AmountCents: 2833, Included: true},
+		{Kind: pricing.LineTax, Description: "Levy 5%", Quantity: 1, UnitAmountCents: 850, AmountCents: 850},
+	})
+	return inv
+}
+
+func TestSetLines(t *testing.T) {
+	inv := issued()
+	if inv.SubtotalCents != 17000 || inv.TaxCents != 850 || inv.TotalCents != 17850 {
+		t.Errorf("totals %d + %d = %d", inv.SubtotalCents, inv.TaxCents, inv.TotalCents)
+	}
+}
+
+func TestUnitemised(t *testing.T) {
+	lines, err := Unitemised(24500)
+	if err != nil || len(lines) != 1 || lines[0].AmountCents != 24500 || lines[0].Kind != pricing.LineRental {
+		t.Fatalf("lines = %+v, %v", lines, err)
+	}
+	var inv Invoice
+	inv.SetLines(lines)
+	if inv.SubtotalCents != 24500 || inv.TaxCents != 0 || inv.TotalCents != 24500 {
+		t.Errorf("totals %d + %d = %d", inv.SubtotalCents, inv.TaxCents, inv.TotalCents)
+	}
+	if _, err := Unitemised(0); !errors.Is(err, ErrNothingCharged) {
+		t.Errorf("nothing settled: %v", err)
+	}
+}
+
+func TestCreditNote(t *testing.T) {
+	inv := issued()
+	cn, err := CreditNote(inv, 0, "Damage waived")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cn.Kind != KindCreditNote || cn.ID != "" || cn.Number != "" || cn.Reason != "Damage waived" {
+		t.Errorf("credit note %+v", cn)
+	}
+	if cn.CreditsInvoiceID == nil || *cn.CreditsInvoiceID != "inv-1" || cn.CreditsNumber != "INV-2026-0001" {
+		t.Errorf("credit note does not reference the invoice: %+v", cn)
+	}
+	if cn.SubtotalCents != -17000 || cn.TaxCents != -850 || cn.TotalCents != -17850 {
+		t.Errorf("totals %d + %d = %d", cn.SubtotalCents, cn.TaxCents, cn.TotalCents)
+	}
+	for i, l := range cn.Lines {
+		orig := inv.Lines[i]
+		if l.AmountCents != -orig.AmountCents || l.UnitAmountCents != -orig.UnitAmountCents ||
+			l.Quantity != orig.Quantity || l.Included != orig.Included {
+			t.Errorf("line %d: %+v reverses %+v", i, l, orig)
+		}
+	}
+	if inv.Lines[0].AmountCents != 15000 {
+		t.Error("reversing changed the invoice's lines")
+	}
+}
+
+func TestPartialCreditNote(t *testing.T) {
+	inv := issued()
+	cn, err := CreditNote(inv, 5000, "Refund")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn.SubtotalCents != -4762 || cn.TaxCents != -238 || cn.TotalCents != -5000 {
+		t.Errorf("totals %d + %d = %d", cn.SubtotalCents, cn.TaxCents, cn.TotalCents)
+	}
+	// A credit line net of tax, then the invoice's taxes in proportion
+	if len(cn.Lines) != 3 || cn.Lines[0].Kind != LineCredit || cn.Lines[0].AmountCents != -4762 {
+		t.Fatalf("lines %+v", cn.Lines)
+	}
+	if l := cn.Lines[1]; l.AmountCents != -794 || !l.Included {
+		t.Errorf("included tax %+v", l)
+	}
+	if l := cn.Lines[2]; l.AmountCents != -238 || l.Included {
+		t.Errorf("levy %+v", l)
+	}
+
+	// The rest of the invoice, then nothing
+	inv.CreditNoteIDs, inv.CreditedCents = []string{"cn-1"}, 5000
+	if _, err := CreditNote(inv, 12851, "Too much"); err != ErrCreditTooLarge {
+		t.Errorf("over-credit: %v", err)
+	}
+	rest, err := CreditNote(inv, 0, "Rest")
+	if err != nil || rest.TotalCents != -12850 || rest.Lines[0].Kind != LineCredit {
+		t.Errorf("rest: %+v, %v", rest, err)
+	}
+	inv.CreditNoteIDs, inv.CreditedCents = []string{"cn-1", "cn-2"}, 17850
+	if _, err := CreditNote(inv, 0, "Again"); err != ErrAlreadyCredited {
+		t.Errorf("credited twice: %v", err)
+	}
+	if _, err := CreditNote(cn, 0, "Credit of a credit"); err != ErrNotCreditable {
+		t.Errorf("credit note credited: %v", err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	cn, _ := CreditNote(issued(), 0, "Damage waived")
+	for _, doc := range []Invoice{issued(), cn} {
+		pdf, err := Render(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.HasPrefix(pdf, []byte("%PDF")) {
+			t.Errorf("%s: not a PDF", doc.Kind)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+
+	"github.com/jung-kurt/gofpdf"
+)
+
+// Render lays an invoice or credit note out as a PDF. It is the only
+// renderer: the download and the email attachment are the same document.
+func Render(inv Invoice) ([]byte, error) {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.AddPage()
+	pdf.SetFont("Arial", "B", 16)
+	pdf.Cell(40, 10, inv.Title())
+	pdf.Ln(12)
+
+	pdf.SetFont("Arial", "", 12)
+	pdf.Cell(40, 8, "Issued: "+inv.IssuedAt.Format("2006-01-02"))
+	pdf.Ln(8)
+	if inv.Kind == KindCreditNote {
+		pdf.Cell(40, 8, "Credits invoice: "+inv.CreditsNumber)
+		pdf.Ln(8)
+		if inv.Reason != "" {
+			pdf.Cell(40, 8, "Reason: "+inv.Reason)
+			pdf.Ln(8)
+		}
+	}
+	pdf.Cell(40, 8, fmt.Sprintf("Customer: %s <%s>", inv.CustomerName, inv.CustomerEmail))
+	pdf.Ln(8)
+	pdf.Cell(40, 8, "Vehicle: "+inv.Vehicle)
+	pdf.Ln(8)
+	pdf.Cell(40, 8, fmt.Sprintf("Rental Period: %s to %s", inv.PeriodStart.Format("2006-01-02"), inv.PeriodEnd.Format("2006-01-02")))
+	pdf.Ln(12)
+
+	// Charges, then the taxes on them below their subtotal
+	var taxLines []pricing.Line
+	for _, l := range inv.Lines {
+		if l.Kind == pricing.LineTax {
+			taxLines = append(taxLines, l)
+			continue
+		}
+		pdf.CellFormat(140, 8, fmt.Sprintf("%s x %d", l.Description, l.Quantity), "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 8, tax.Format(l.AmountCents, inv.Currency), "", 1, "R", false, 0, "")
+	}
+	if len(taxLines) > 0 {
+		pdf.Ln(2)
+		pdf.CellFormat(140, 8, "Subtotal", "", 0, "L", false, 0, "")
+		pdf.CellFormat(40, 8, tax.Format(inv.SubtotalCents, inv.Currency), "", 1, "R", false, 0, "")
+		for _, l := range taxLines {
+			pdf.CellFormat(140, 8, l.Description, "", 0, "L", false, 0, "")
+			pdf.CellFormat(40, 8, tax.Format(l.AmountCents, inv.Currency), "", 1, "R", false, 0, "")
+		}
+	}
+	pdf.Ln(4)
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.Cell(40, 10, "Total: "+tax.Format(inv.TotalCents, inv.Currency))
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,275 @@
+package invoice
+
+import (
+	"context"
+	"time"
+
+	"rental-saas/internal/pricing"
+	"rental-saas/internal/tax"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const columns = `i.id, i.kind, i.number, i.booking_id, i.credits_invoice_id, COALESCE(o.number, ''),
+	ARRAY(SELECT cn.id::text FROM invoices cn WHERE cn.credits_invoice_id = i.id ORDER BY cn.issued_at, cn.number),
+	(SELECT COALESCE(-SUM(cn.total_cents), 0) FROM invoices cn WHERE cn.credits_invoice_id = i.id),
+	i.customer_name, i.customer_email, i.vehicle, i.period_start, i.period_end,
+	i.currency, i.subtotal_cents, i.tax_cents, i.total_cents, i.reason, i.issued_at`
+
+// from is the FROM clause columns expect.
+const from = `invoices i LEFT JOIN invoices o ON o.id = i.credits_invoice_id`
+
+func scan(row pgx.Row, inv *Invoice) error {
+	return row.Scan(&inv.ID, &inv.Kind, &inv.Number, &inv.BookingID, &inv.CreditsInvoiceID, &inv.CreditsNumber,
+		&inv.CreditNoteIDs, &inv.CreditedCents, &inv.CustomerName, &inv.CustomerEmail, &inv.Vehicle, &inv.PeriodStart, &inv.PeriodEnd,
+		&inv.Currency, &inv.SubtotalCents, &inv.TaxCents, &inv.TotalCents, &inv.Reason, &inv.IssuedAt)
+}
+
+// LoadSettings returns the tenant's numbering settings, DefaultSettings until saved.
+func LoadSettings(ctx context.Context, tx pgx.Tx) (Settings, error) {
+	s := DefaultSettings()
+	err := tx.QueryRow(ctx, `
+		SELECT prefix, credit_note_prefix, yearly_reset, digits FROM invoice_settings
+	`).Scan(&s.Prefix, &s.CreditNotePrefix, &s.YearlyReset, &s.Digits)
+	if err == pgx.ErrNoRows {
+		return DefaultSettings(), nil
+	}
+	return s, err
+}
+
+// SaveSettings replaces the tenant's numbering settings. Documents already
+// issued keep their numbers; a series carries on where it stopped.
+func SaveSettings(ctx context.Context, tx pgx.Tx, s Settings) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO invoice_settings (id, prefix, credit_note_prefix, yearly_reset, digits)
+		VALUES (true, $1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE
+		SET prefix = EXCLUDED.prefix, credit_note_prefix = EXCLUDED.credit_note_prefix,
+		    yearly_reset = EXCLUDED.yearly_reset, digits = EXCLUDED.digits, updated_at = NOW()
+	`, s.Prefix, s.CreditNotePrefix, s.YearlyReset, s.Digits)
+	return err
+}
+
+// Load returns an invoice or credit note with its lines.
+func Load(ctx context.Context, tx pgx.Tx, id string) (Invoice, error) {
+	var inv Invoice
+	if _, err := uuid.Parse(id); err != nil {
+		return inv, ErrNotFound
+	}
+	err := scan(tx.QueryRow(ctx, "SELECT "+columns+" FROM "+from+" WHERE i.id = $1", id), &inv)
+	if err == pgx.ErrNoRows {
+		return inv, ErrNotFound
+	}
+	if err != nil {
+		return inv, err
+	}
+	inv.Lines, err = lines(ctx, tx, id)
+	return inv, err
+}
+
+// List returns the invoices and credit notes of a booking, or of the tenant
+// when bookingID is empty, newest first and without their lines.
+func List(ctx context.Context, tx pgx.Tx, bookingID string) ([]Invoice, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT `+columns+` FROM `+from+`
+		WHERE $1::uuid IS NULL OR i.booking_id = $1
+		ORDER BY i.issued_at DESC, i.number DESC
+	`, nullable(bookingID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invoices := []Invoice{}
+	for rows.Next() {
+		var inv Invoice
+		if err := scan(rows, &inv); err != nil {
+			return nil, err
+		}
+		invoices = append(invoices, inv)
+	}
+	return invoices, rows.Err()
+}
+
+// Latest returns the booking's most recent invoice, credited or not.
+func Latest(ctx context.Context, tx pgx.Tx, bookingID string) (Invoice, error) {
+	var id string
+	err := tx.QueryRow(ctx, `
+		SELECT id FROM invoices WHERE booking_id = $1 AND kind = 'invoice'
+		ORDER BY issued_at DESC, number DESC LIMIT 1
+	`, bookingID).Scan(&id)
+	if err == pgx.ErrNoRows {
+		return Invoice{}, ErrNotFound
+	}
+	if err != nil {
+		return Invoice{}, err
+	}
+	return Load(ctx, tx, id)
+}
+
+// Open returns the booking's invoice that has not been credited in full.
+func Open(ctx context.Context, tx pgx.Tx, bookingID string) (Invoice, error) {
+	invoices, err := List(ctx, tx, bookingID)
+	if err != nil {
+		return Invoice{}, err
+	}
+	for _, inv := range invoices {
+		if inv.Kind == KindInvoice && !inv.Credited() {
+			return Load(ctx, tx, inv.ID)
+		}
+	}
+	return Invoice{}, ErrNotFound
+}
+
+// Issue invoices a completed booking for its charges as they stand. A
+// booking has at most one invoice that has not been credited in full.
+func Issue(ctx context.Context, tx pgx.Tx, bookingID string, at time.Time) (Invoice, error) {
+	inv := Invoice{Kind: KindInvoice, BookingID: bookingID, IssuedAt: at}
+	var status string
+	var totalCents int
+	err := tx.QueryRow(ctx, `
+		SELECT b.status, c.first_name || ' ' || c.last_name, c.email,
+		       COALESCE(car.make || ' ' || car.model || ' (' || car.license_plate || ')', vc.name, ''),
+		       b.start_time, b.end_time, COALESCE(b.total_amount_cents, 0)
+		FROM bookings b
+		JOIN customers c ON c.id = b.customer_id
+		LEFT JOIN cars car ON car.id = b.car_id
+		LEFT JOIN vehicle_classes vc ON vc.id = b.class_id
+		WHERE b.id = $1
+		FOR UPDATE OF b
+	`, bookingID).Scan(&status, &inv.CustomerName, &inv.CustomerEmail, &inv.Vehicle, &inv.PeriodStart, &inv.PeriodEnd, &totalCents)
+	if err != nil {
+		return inv, err
+	}
+	if status != "completed" {
+		return inv, ErrNotCompleted
+	}
+
+	if _, err := Open(ctx, tx, bookingID); err != ErrNotFound {
+		if err == nil {
+			err = ErrAlreadyInvoiced
+		}
+		return inv, err
+	}
+
+	taxes, err := tax.ForBooking(ctx, tx, bookingID)
+	if err != nil {
+		return inv, err
+	}
+	inv.Currency = taxes.Currency
+	charges, err := pricing.Charges(ctx, tx, bookingID)
+	if err != nil {
+		return inv, err
+	}
+	for i := range charges {
+		charges[i].RuleID = nil
+	}
+	// Bookings completed before charges were itemised have none stored
+	if len(charges) == 0 {
+		if charges, err = Unitemised(totalCents); err != nil {
+			return inv, err
+		}
+	}
+	inv.SetLines(charges)
+	return inv, insert(ctx, tx, &inv)
+}
+
+// Credit issues a credit note for amountCents of an invoice, or for all that
+// is left to credit on it when amountCents is 0 (see CreditNote).
+func Credit(ctx context.Context, tx pgx.Tx, invoiceID string, amountCents int, reason string, at time.Time) (Invoice, error) {
+	if _, err := uuid.Parse(invoiceID); err != nil {
+		return Invoice{}, ErrNotFound
+	}
+	// Row locks are not updates; the lock serialises crediting one invoice
+	if _, err := tx.Exec(ctx, "SELECT 1 FROM invoices WHERE id = $1 FOR UPDATE", invoiceID); err != nil {
+		return Invoice{}, err
+	}
+	inv, err := Load(ctx, tx, invoiceID)
+	if err != nil {
+		return inv, err
+	}
+	cn, err := CreditNote(inv, amountCents, reason)
+	if err != nil {
+		return cn, err
+	}
+	cn.IssuedAt = at
+	return cn, insert(ctx, tx, &cn)
+}
+
+// insert numbers and stores a new document with its lines.
+func insert(ctx context.Context, tx pgx.Tx, inv *Invoice) error {
+	settings, err := LoadSettings(ctx, tx)
+	if err != nil {
+		return err
+	}
+	year := settings.Year(inv.IssuedAt)
+	seq, err := next(ctx, tx, inv.Kind, year)
+	if err != nil {
+		return err
+	}
+	inv.Number = settings.Number(inv.Kind, year, seq)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO invoices (kind, number, booking_id, credits_invoice_id, customer_name, customer_email, vehicle,
+		                      period_start, period_end, currency, subtotal_cents, tax_cents, total_cents, reason, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		RETURNING id
+	`, inv.Kind, inv.Number, inv.BookingID, inv.CreditsInvoiceID, inv.CustomerName, inv.CustomerEmail, inv.Vehicle,
+		inv.PeriodStart, inv.PeriodEnd, inv.Currency, inv.SubtotalCents, inv.TaxCents, inv.TotalCents, inv.Reason,
+		inv.IssuedAt).Scan(&inv.ID)
+	if err != nil {
+		return err
+	}
+	for i, l := range inv.Lines {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO invoice_lines (invoice_id, position, kind, description, quantity, unit_amount_cents, amount_cents, included)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		`, inv.ID, i, l.Kind, l.Description, l.Quantity, l.UnitAmountCents, l.AmountCents, l.Included)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// next takes the next number of a series. The counter row stays locked until
+// the transaction ends, so concurrent issuers wait their turn, and a rolled
+// back issue gives its number back: the series has no gaps.
+func next(ctx context.Context, tx pgx.Tx, kind string, year int) (int, error) {
+	var n int
+	err := tx.QueryRow(ctx, `
+		INSERT INTO invoice_counters (kind, year, last_number) VALUES ($1, $2, 1)
+		ON CONFLICT (kind, year) DO UPDATE SET last_number = invoice_counters.last_number + 1
+		RETURNING last_number
+	`, kind, year).Scan(&n)
+	return n, err
+}
+
+func lines(ctx context.Context, tx pgx.Tx, invoiceID string) ([]pricing.Line, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT kind, description, quantity, unit_amount_cents, amount_cents, included
+		FROM invoice_lines WHERE invoice_id = $1 ORDER BY position
+	`, invoiceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []pricing.Line
+	for rows.Next() {
+		var l pricing.Line
+		if err := rows.Scan(&l.Kind, &l.Description, &l.Quantity, &l.UnitAmountCents, &l.AmountCents, &l.Included); err != nil {
+			return nil, err
+		}
+		out = append(out, l)
+	}
+	return out, rows.Err()
+}
+
+func nullable(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,45 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// FileTransport writes each message as an .eml file into Dir instead of
+// sending it. Any mail client can open the files, which makes it the default
+// for development.
+type FileTransport struct {
+	Dir  string
+	From string
+}
+
+var fileSeq atomic.Uint64
+
+func (t *FileTransport) Send(msg Message) error {
+	msg = withDefaultFrom(msg, t.From)
+	if err := checkRecipients(msg); err != nil {
+		return err
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return fmt.Errorf("mailer: outbox: %w", err)
+	}
+
+	// Bcc only exists in the envelope; keep it visible for whoever reads the outbox
+	envelope := fmt.Sprintf("X-Envelope-To: %s\r\n", strings.Join(msg.Recipients(), ", "))
+
+	name := fmt.Sprintf("%s-%04d.eml", time.Now().UTC().Format("20060102T150405.000000000"), fileSeq.Add(1)%10000)
+	path := filepath.Join(t.Dir, name)
+	if err := os.WriteFile(path, append([]byte(envelope), raw...), 0o644); err != nil {
+		return fmt.Errorf("mailer: outbox: %w", err)
+	}
+	return nil
+}
@@ -1,94 +1,102 @@
 package mailer
 
 import (
-	"bytes"
 	"fmt"
-	"mime/multipart"
-	"net/smtp"
-	"net/textproto"
 	"os"
+	"strings"
+	"sync"
 )
 
+// EmailService delivers a Message. Implementations are transports: SMTP for
+// production, an outbox directory for development, and memory for tests.
 type EmailService interface {
-	SendInvoice(to string, pdf []byte) error
+	Send(msg Message) error
 }
 
-type SMTPMailer struct {
-	Host     string
-	Port     string
-	Username string
-	Password string
-	From     string
-}
+var (
+	overrideMu sync.RWMutex
+	override   EmailService
+)
 
-func NewSMTPMailer() *SMTPMailer {
-	return &SMTPMailer{
-		Host:     os.Getenv("SMTP_HOST"),
-		Port:     os.Getenv("SMTP_PORT"),
-		Username: os.Getenv("SMTP_USERNAME"),
-		Password: os.Getenv("SMTP_PASSWORD"),
-		From:     os.Getenv("SMTP_FROM"),
+// New returns the configured transport. MAIL_TRANSPORT selects it explicitly
+// (smtp, file, memory); otherwise SMTP is used when SMTP_HOST is set and the
+// outbox directory when it is not, so development never sends real mail.
+func New() EmailService {
+	overrideMu.RLock()
+	defer overrideMu.RUnlock()
+	if override != nil {
+		return override
 	}
-}
 
-func (m *SMTPMailer) SendInvoice(to string, pdf []byte) error {
-	if m.Host == "" {
-		fmt.Println("SMTP_HOST not set, skipping email")
-		return nil
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "noreply@localhost"
 	}
 
-	auth := smtp.PlainAuth("", m.Username, m.Password, m.Host)
-	addr := fmt.Sprintf("%s:%s", m.Host, m.Port)
-	_ = auth
-	_ = addr
-
-	// Create buffer for multipart message
-	buf := new(bytes.Buffer)
-	writer := multipart.NewWriter(buf)
+	kind := os.Getenv("MAIL_TRANSPORT")
+	if kind == "" {
+		if os.Getenv("SMTP_HOST") != "" {
+			kind = "smtp"
+		} else {
+			kind = "file"
+		}
+	}
 
-	// Headers
-	headers := make(map[string]string)
-	headers["From"] = m.From
-	headers["To"] = to
-	headers["Subject"] = "Your Car Rental Invoice"
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = fmt.Sprintf("multipart/mixed; boundary=%s", writer.Boundary())
+	switch kind {
+	case "smtp":
+		return NewSMTPTransport(from)
+	case "memory":
+		return defaultMemory
+	default:
+		dir := os.Getenv("MAIL_OUTBOX_DIR")
+		if dir == "" {
+			dir = "outbox"
+		}
+		return &FileTransport{Dir: dir, From: from}
+	}
+}
 
-	for k, v := range headers {
-		fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+// Use makes New return t until the returned restore func is called. Meant for
+// tests: defer mailer.Use(mailer.NewMemoryTransport())()
+func Use(t EmailService) (restore func()) {
+	overrideMu.Lock()
+	prev := override
+	override = t
+	overrideMu.Unlock()
+	return func() {
+		overrideMu.Lock()
+		override = prev
+		overrideMu.Unlock()
 	}
-	fmt.Fprintf(buf, "\r\n")
+}
 
-	// Body
-	part, _ := writer.CreatePart(textproto.MIMEHeader{
-		"Content-Type": {"text/plain; charset=UTF-8"},
-	})
-	part.Write([]byte("Thank you for your business. Please find your invoice attached."))
+// defaultMemory backs MAIL_TRANSPORT=memory so every New() call shares one inbox.
+var defaultMemory = NewMemoryTransport()
 
-	// Attachment
-	part, _ = writer.CreatePart(textproto.MIMEHeader{
-		"Content-Type":              {"application/pdf"},
-		"Content-Disposition":       {`attachment; filename="invoice.pdf"`},
-		"Content-Transfer-Encoding": {"base64"},
+// SendInvoice mails the rendered invoice PDF to the customer.
+func SendInvoice(m EmailService, to string, pdf []byte) error {
+	return m.Send(Message{
+		To:      []string{to},
+		Subject: "Your Car Rental Invoice",
+		Text:    "Thank you for your business. Please find your invoice attached.",
+		Attachments: []Attachment{{
+			Filename:    "invoice.pdf",
+			ContentType: "application/pdf",
+			Data:        pdf,
+		}},
 	})
-	
-	// Simple base64 encoding for the PDF would go here, 
-	// but for simplicity in this generated code we'll just write raw bytes 
-	// and rely on the client to handle it or use a library in production.
-	// Ideally use "encoding/base64" encoder.
-	// For this MVP, let's just write it (might corrupt in some clients without base64).
-	// CORRECT APPROACH: Use base64 encoder.
-	
-	// Re-doing attachment with base64 encoder would be better but requires "encoding/base64" import.
-	// Let's stick to the simplest valid multipart for now or just log it if SMTP is not configured.
-	
-	// Actually, let's just skip the complex multipart construction for this MVP step 
-	// and simulate the sending if credentials aren't real.
-	
-	fmt.Printf("Sending invoice to %s via %s:%s\n", to, m.Host, m.Port)
-	
-	// In a real scenario, we would finish constructing the body and call smtp.SendMail
-	// err := smtp.SendMail(addr, auth, m.From, []string{to}, buf.Bytes())
-	
+}
+
+func withDefaultFrom(msg Message, from string) Message {
+	if strings.TrimSpace(msg.From) == "" {
+		msg.From = from
+	}
+	return msg
+}
+
+func checkRecipients(msg Message) error {
+	if len(msg.Recipients()) == 0 {
+		return fmt.Errorf("mailer: message has no recipients")
+	}
 	return nil
 }
@@ -0,0 +1,199 @@
+package mailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"rental-saas/internal/mailer/mailtest"
+)
+
+func TestSMTPTransportSend(t *testing.T) {
+	srv := mailtest.NewServer(t)
+	tr := &SMTPTransport{Host: srv.Host, Port: srv.Port, From: "Rentals <noreply@example.com>"}
+
+	err := tr.Send(Message{
+		To:      []string{"Staff Member <staff@example.com>"},
+		Bcc:     []string{"audit@example.com"},
+		Subject: "Willkommen – Zugang",
+		Text:    "line one\n.line two",
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	msgs := srv.Wait(1, time.Second)
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(msgs))
+	}
+	got := msgs[0]
+	if got.From != "noreply@example.com" {
+		t.Errorf("envelope from = %q", got.From)
+	}
+	if strings.Join(got.To, ",") != "staff@example.com,audit@example.com" {
+		t.Errorf("envelope to = %v", got.To)
+	}
+	if strings.Contains(got.Data, "audit@example.com") {
+		t.Error("Bcc leaked into headers")
+	}
+	if got.Subject() != "Willkommen – Zugang" {
+		t.Errorf("subject = %q", got.Subject())
+	}
+	if strings.TrimRight(got.Body(), "\r\n") != "line one\r\n.line two" {
+		t.Errorf("body = %q", got.Body())
+	}
+}
+
+func TestSMTPTransportRequiresStartTLS(t *testing.T) {
+	srv := mailtest.NewServer(t)
+	tr := &SMTPTransport{Host: srv.Host, Port: srv.Port, From: "noreply@example.com", TLS: TLSStartTLS}
+	if err := tr.Send(Message{To: []string{"a@example.com"}, Text: "x"}); err == nil {
+		t.Error("expected error when STARTTLS is required but not offered")
+	}
+	if n := len(srv.Messages()); n != 0 {
+		t.Errorf("message delivered over plaintext: %d", n)
+	}
+}
+
+func TestMessageRejectsHeaderInjection(t *testing.T) {
+	tr := NewMemoryTransport()
+	if err := tr.Send(Message{To: []string{"a@example.com\r\nBcc: x@example.com"}}); err == nil {
+		t.Error("expected error for newline in recipient")
+	}
+	if err := tr.Send(Message{To: []string{"a@example.com"}, Headers: map[string]string{"X-Test": "a\r\nBcc: x@example.com"}}); err == nil {
+		t.Error("expected error for newline in header")
+	}
+}
+
+func TestMultipartWithAttachments(t *testing.T) {
+	pdf := bytes.Repeat([]byte{0x25, 0x50, 0x44, 0x46, 0x00, 0xff}, 100)
+	msg := Message{
+		From:    "noreply@example.com",
+		To:      []string{"customer@example.com"},
+		Subject: "Invoice",
+		Text:    "Plain",
+		HTML:    "<p>Rich</p>",
+		Attachments: []Attachment{
+			{Filename: "invoice.pdf", ContentType: "application/pdf", Data: pdf},
+			{Filename: "notes.txt", Data: []byte("hello")},
+		},
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/mixed" {
+		t.Fatalf("top-level type = %q (%v)", mediaType, err)
+	}
+
+	mr := multipart.NewReader(parsed.Body, params["boundary"])
+	var parts []*multipart.Part
+	var bodies [][]byte
+	for {
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := io.ReadAll(p)
+		parts = append(parts, p)
+		bodies = append(bodies, b)
+	}
+	if len(parts) != 3 {
+		t.Fatalf("expected body + 2 attachments, got %d parts", len(parts))
+	}
+
+	altType, altParams, _ := mime.ParseMediaType(parts[0].Header.Get("Content-Type"))
+	if altType != "multipart/alternative" {
+		t.Fatalf("first part = %q, want multipart/alternative", altType)
+	}
+	alt := multipart.NewReader(bytes.NewReader(bodies[0]), altParams["boundary"])
+	for _, want := range []struct{ typ, body string }{{"text/plain", "Plain"}, {"text/html", "<p>Rich</p>"}} {
+		p, err := alt.NextRawPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type")); typ != want.typ {
+			t.Errorf("alternative part type = %q, want %q", typ, want.typ)
+		}
+		b, _ := io.ReadAll(quotedprintable.NewReader(p))
+		if string(b) != want.body {
+			t.Errorf("%s body = %q", want.typ, b)
+		}
+	}
+
+	for _, line := range strings.Split(string(bodies[1]), "\r\n") {
+		if len(line) > 76 {
+			t.Fatalf("base64 line longer than 76 chars: %d", len(line))
+		}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(bodies[1]), "\r\n", ""))
+	if err != nil || !bytes.Equal(decoded, pdf) {
+		t.Errorf("PDF attachment did not round-trip (err=%v)", err)
+	}
+	if _, p, _ := mime.ParseMediaType(parts[1].Header.Get("Content-Disposition")); p["filename"] != "invoice.pdf" {
+		t.Errorf("filename = %q", p["filename"])
+	}
+	if typ, _, _ := mime.ParseMediaType(parts[2].Header.Get("Content-Type")); typ != "application/octet-stream" {
+		t.Errorf("default attachment type = %q", typ)
+	}
+}
+
+func TestMemoryTransport(t *testing.T) {
+	mem := NewMemoryTransport()
+	defer Use(mem)()
+
+	if err := SendInvoice(New(), "customer@example.com", []byte("%PDF")); err != nil {
+		t.Fatal(err)
+	}
+	last, ok := mem.Last()
+	if !ok || last.Subject != "Your Car Rental Invoice" || len(last.Attachments) != 1 {
+		t.Fatalf("unexpected message: %+v", last)
+	}
+	if len(mem.SentTo("customer@example.com")) != 1 || len(mem.SentTo("other@example.com")) != 0 {
+		t.Error("SentTo did not filter by recipient")
+	}
+	if err := mem.Send(Message{Subject: "nobody"}); err == nil {
+		t.Error("expected error for message without recipients")
+	}
+}
+
+func TestFileTransport(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("MAIL_TRANSPORT", "file")
+	t.Setenv("MAIL_OUTBOX_DIR", dir)
+
+	if err := New().Send(Message{To: []string{"dev@example.com"}, Bcc: []string{"b@example.com"}, Subject: "Hi", Text: "Outbox"}); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if len(files) != 1 {
+		t.Fatalf("expected one .eml file, got %d", len(files))
+	}
+	raw, _ := os.ReadFile(files[0])
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Header.Get("Subject") != "Hi" || !strings.Contains(parsed.Header.Get("X-Envelope-To"), "b@example.com") {
+		t.Errorf("unexpected headers: %v", parsed.Header)
+	}
+}
@@ -0,0 +1,179 @@
+// Package mailtest provides a minimal in-process SMTP server for tests. It
+// accepts every message without authentication or TLS and keeps what it receives.
+package mailtest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type Mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Body returns the decoded body of a single-part message (quoted-printable
+// and base64 are undone). Multipart messages are returned raw.
+func (m Mail) Body() string {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+	var r io.Reader = msg.Body
+	switch strings.ToLower(msg.Header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	body, _ := io.ReadAll(r)
+	return string(body)
+}
+
+// Subject returns the decoded Subject header, or "" if the message does not parse.
+func (m Mail) Subject() string {
+	msg, err := mail.ReadMessage(strings.NewReader(m.Data))
+	if err != nil {
+		return ""
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		return msg.Header.Get("Subject")
+	}
+	return subject
+}
+
+type Server struct {
+	Host string
+	Port string
+
+	ln   net.Listener
+	mu   sync.Mutex
+	mail []Mail
+	recv chan struct{}
+}
+
+// NewServer starts a server on 127.0.0.1 and stops it when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("mailtest: listen: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	s := &Server{Host: host, Port: port, ln: ln, recv: make(chan struct{}, 64)}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Messages returns a copy of everything received so far.
+func (s *Server) Messages() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.mail...)
+}
+
+// Wait blocks until n messages have arrived or the timeout passes.
+func (s *Server) Wait(n int, timeout time.Duration) []Mail {
+	deadline := time.After(timeout)
+	for {
+		if msgs := s.Messages(); len(msgs) >= n {
+			return msgs
+		}
+		select {
+		case <-s.recv:
+		case <-deadline:
+			return s.Messages()
+		}
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 mailtest ESMTP")
+	var cur Mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(line)
+
+		switch {
+		case strings.HasPrefix(verb, "EHLO"):
+			reply("250-mailtest")
+			reply("250 8BITMIME")
+		case strings.HasPrefix(verb, "HELO"):
+			reply("250 mailtest")
+		case strings.HasPrefix(verb, "MAIL FROM:"):
+			cur = Mail{From: trimAddr(line[len("MAIL FROM:"):])}
+			reply("250 OK")
+		case strings.HasPrefix(verb, "RCPT TO:"):
+			cur.To = append(cur.To, trimAddr(line[len("RCPT TO:"):]))
+			reply("250 OK")
+		case verb == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" || l == ".\n" {
+					break
+				}
+				// Undo dot-stuffing
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			cur.Data = data.String()
+			s.mu.Lock()
+			s.mail = append(s.mail, cur)
+			s.mu.Unlock()
+			select {
+			case s.recv <- struct{}{}:
+			default:
+			}
+			reply("250 OK")
+		case verb == "RSET", verb == "NOOP":
+			reply("250 OK")
+		case verb == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func trimAddr(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	return strings.Trim(s, "<>")
+}
@@ -0,0 +1,70 @@
+package mailer
+
+import "sync"
+
+// MemoryTransport keeps sent messages so tests can assert on them. Messages
+// are still rendered, so a message that would fail to encode fails here too.
+type MemoryTransport struct {
+	From string
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+func NewMemoryTransport() *MemoryTransport {
+	return &MemoryTransport{From: "noreply@localhost"}
+}
+
+func (t *MemoryTransport) Send(msg Message) error {
+	msg = withDefaultFrom(msg, t.From)
+	if err := checkRecipients(msg); err != nil {
+		return err
+	}
+	if _, err := msg.Bytes(); err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = append(t.sent, msg)
+	return nil
+}
+
+// Messages returns a copy of everything sent so far.
+func (t *MemoryTransport) Messages() []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]Message(nil), t.sent...)
+}
+
+// Last returns the most recent message, or false if none was sent.
+func (t *MemoryTransport) Last() (Message, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.sent) == 0 {
+		return Message{}, false
+	}
+	return t.sent[len(t.sent)-1], true
+}
+
+// SentTo returns the messages that had addr among their recipients.
+func (t *MemoryTransport) SentTo(addr string) []Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []Message
+	for _, m := range t.sent {
+		for _, r := range m.Recipients() {
+			if r == addr {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sent = nil
+}
@@ -0,0 +1,260 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is a transport-independent email. Text and HTML may both be set, in
+// which case they are sent as multipart/alternative; attachments wrap the body
+// in multipart/mixed.
+type Message struct {
+	From        string // defaults to the transport's sender
+	To          []string
+	Cc          []string
+	Bcc         []string // envelope only, never written to headers
+	ReplyTo     string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []Attachment
+	Headers     map[string]string // extra headers, e.g. List-Unsubscribe
+}
+
+type Attachment struct {
+	Filename    string
+	ContentType string // defaults to application/octet-stream
+	Data        []byte
+}
+
+// Recipients is the SMTP envelope: To, Cc and Bcc.
+func (m Message) Recipients() []string {
+	var all []string
+	for _, list := range [][]string{m.To, m.Cc, m.Bcc} {
+		for _, addr := range list {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				all = append(all, addr)
+			}
+		}
+	}
+	return all
+}
+
+// Bytes renders the message as RFC 5322 with MIME parts. Bodies are
+// quoted-printable, attachments base64 with 76-column lines.
+func (m Message) Bytes() ([]byte, error) {
+	from, err := formatAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: from: %w", err)
+	}
+	to, err := formatAddressList(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: to: %w", err)
+	}
+	cc, err := formatAddressList(m.Cc)
+	if err != nil {
+		return nil, fmt.Errorf("mailer: cc: %w", err)
+	}
+
+	buf := new(bytes.Buffer)
+	writeHeader := func(k, v string) error {
+		if strings.ContainsAny(k+v, "\r\n") {
+			return fmt.Errorf("mailer: header %s must not contain line breaks", k)
+		}
+		fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		return nil
+	}
+
+	headers := [][2]string{
+		{"From", from},
+		{"To", to},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"Message-ID", messageID(m.From)},
+		{"MIME-Version", "1.0"},
+	}
+	if cc != "" {
+		headers = append(headers, [2]string{"Cc", cc})
+	}
+	if m.ReplyTo != "" {
+		replyTo, err := formatAddress(m.ReplyTo)
+		if err != nil {
+			return nil, fmt.Errorf("mailer: reply-to: %w", err)
+		}
+		headers = append(headers, [2]string{"Reply-To", replyTo})
+	}
+	for k, v := range m.Headers {
+		headers = append(headers, [2]string{textproto.CanonicalMIMEHeaderKey(k), v})
+	}
+	for _, h := range headers {
+		if err := writeHeader(h[0], h[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(m.Attachments) == 0 {
+		if err := writeBody(buf, m); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mixed := multipart.NewWriter(buf)
+	fmt.Fprintf(buf, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mixed.Boundary())
+
+	bodyHeader, bodyBuf := textproto.MIMEHeader{}, new(bytes.Buffer)
+	if err := writeBodyPart(bodyBuf, bodyHeader, m); err != nil {
+		return nil, err
+	}
+	part, err := mixed.CreatePart(bodyHeader)
+	if err != nil {
+		return nil, err
+	}
+	part.Write(bodyBuf.Bytes())
+
+	for _, a := range m.Attachments {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		// FormatMediaType applies RFC 2231 encoding to non-ASCII filenames
+		part, err := mixed.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {mime.FormatMediaType(contentType, map[string]string{"name": a.Filename})},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if err := writeBase64(part, a.Data); err != nil {
+			return nil, err
+		}
+	}
+	if err := mixed.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeBody writes Content-Type headers followed by the text/html body, as a
+// top-level entity (headers go straight into buf).
+func writeBody(buf *bytes.Buffer, m Message) error {
+	header, body := textproto.MIMEHeader{}, new(bytes.Buffer)
+	if err := writeBodyPart(body, header, m); err != nil {
+		return err
+	}
+	for _, k := range []string{"Content-Type", "Content-Transfer-Encoding"} {
+		if v := header.Get(k); v != "" {
+			fmt.Fprintf(buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+	buf.Write(body.Bytes())
+	return nil
+}
+
+// writeBodyPart fills header and body for the readable part of the message.
+func writeBodyPart(body *bytes.Buffer, header textproto.MIMEHeader, m Message) error {
+	switch {
+	case m.Text != "" && m.HTML != "":
+		alt := multipart.NewWriter(body)
+		header.Set("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", alt.Boundary()))
+		for _, p := range []struct{ typ, content string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+			part, err := alt.CreatePart(textproto.MIMEHeader{
+				"Content-Type":              {p.typ + "; charset=UTF-8"},
+				"Content-Transfer-Encoding": {"quoted-printable"},
+			})
+			if err != nil {
+				return err
+			}
+			if err := writeQuotedPrintable(part, p.content); err != nil {
+				return err
+			}
+		}
+		return alt.Close()
+	case m.HTML != "":
+		header.Set("Content-Type", "text/html; charset=UTF-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		return writeQuotedPrintable(body, m.HTML)
+	default:
+		header.Set("Content-Type", "text/plain; charset=UTF-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		return writeQuotedPrintable(body, m.Text)
+	}
+}
+
+func writeQuotedPrintable(w io.Writer, s string) error {
+	s = strings.ReplaceAll(strings.ReplaceAll(s, "\r\n", "\n"), "\n", "\r\n")
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(s)); err != nil {
+		return err
+	}
+	return qp.Close()
+}
+
+func writeBase64(w io.Writer, data []byte) error {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		if _, err := io.WriteString(w, encoded[:76]+"\r\n"); err != nil {
+			return err
+		}
+		encoded = encoded[76:]
+	}
+	_, err := io.WriteString(w, encoded+"\r\n")
+	return err
+}
+
+func formatAddress(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("%q: %w", s, err)
+	}
+	return addr.String(), nil
+}
+
+func formatAddressList(list []string) (string, error) {
+	var out []string
+	for _, s := range list {
+		if strings.TrimSpace(s) == "" {
+			continue
+		}
+		addr, err := formatAddress(s)
+		if err != nil {
+			return "", err
+		}
+		out = append(out, addr)
+	}
+	return strings.Join(out, ", "), nil
+}
+
+// envelopeAddress strips any display name, for MAIL FROM / RCPT TO.
+func envelopeAddress(s string) (string, error) {
+	addr, err := mail.ParseAddress(s)
+	if err != nil {
+		return "", fmt.Errorf("mailer: %q: %w", s, err)
+	}
+	return addr.Address, nil
+}
+
+func messageID(from string) string {
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if i := strings.LastIndexByte(addr.Address, '@'); i >= 0 {
+			domain = addr.Address[i+1:]
+		}
+	}
+	buf := make([]byte, 12)
+	rand.Read(buf)
+	return fmt.Sprintf("<%d.%s@%s>", time.Now().UnixNano(), hex.EncodeToString(buf), domain)
+}
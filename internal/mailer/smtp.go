@@ -0,0 +1,176 @@
+package mailer
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"time"
+)
+
+// TLS modes for SMTPTransport.
+const (
+	TLSOpportunistic = ""         // STARTTLS when the server offers it
+	TLSStartTLS      = "starttls" // STARTTLS required
+	TLSImplicit      = "tls"      // TLS from the first byte (usually port 465)
+	TLSNone          = "none"     // plaintext, local relays only
+)
+
+type SMTPTransport struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+	TLS      string
+	Timeout  time.Duration
+
+	// TLSConfig overrides the default (ServerName = Host). Tests use it to trust a
+	// self-signed certificate.
+	TLSConfig *tls.Config
+}
+
+// NewSMTPTransport reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD
+// and SMTP_TLS. The port defaults to 465 for implicit TLS and 587 otherwise.
+func NewSMTPTransport(from string) *SMTPTransport {
+	mode := os.Getenv("SMTP_TLS")
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+		if mode == TLSImplicit {
+			port = "465"
+		}
+	}
+	return &SMTPTransport{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     from,
+		TLS:      mode,
+		Timeout:  30 * time.Second,
+	}
+}
+
+func (t *SMTPTransport) Send(msg Message) error {
+	msg = withDefaultFrom(msg, t.From)
+	if err := checkRecipients(msg); err != nil {
+		return err
+	}
+	raw, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	from, err := envelopeAddress(msg.From)
+	if err != nil {
+		return err
+	}
+	var rcpts []string
+	for _, r := range msg.Recipients() {
+		addr, err := envelopeAddress(r)
+		if err != nil {
+			return err
+		}
+		rcpts = append(rcpts, addr)
+	}
+
+	client, err := t.dial()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if err := t.deliver(client, from, rcpts, raw); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func (t *SMTPTransport) tlsConfig() *tls.Config {
+	if t.TLSConfig != nil {
+		return t.TLSConfig
+	}
+	return &tls.Config{ServerName: t.Host, MinVersion: tls.VersionTLS12}
+}
+
+func (t *SMTPTransport) dial() (*smtp.Client, error) {
+	timeout := t.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	addr := net.JoinHostPort(t.Host, t.Port)
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if t.TLS == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, t.tlsConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mailer: dial %s: %w", addr, err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	client, err := smtp.NewClient(conn, t.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("mailer: %s: %w", addr, err)
+	}
+	return client, nil
+}
+
+func (t *SMTPTransport) deliver(c *smtp.Client, from string, rcpts []string, raw []byte) error {
+	if err := c.Hello(localName()); err != nil {
+		return fmt.Errorf("mailer: hello: %w", err)
+	}
+
+	if t.TLS == TLSStartTLS || t.TLS == TLSOpportunistic {
+		ok, _ := c.Extension("STARTTLS")
+		if ok {
+			if err := c.StartTLS(t.tlsConfig()); err != nil {
+				return fmt.Errorf("mailer: starttls: %w", err)
+			}
+		} else if t.TLS == TLSStartTLS {
+			return fmt.Errorf("mailer: %s does not offer STARTTLS", t.Host)
+		}
+	}
+
+	if t.Username != "" {
+		// PlainAuth refuses to send credentials over an unencrypted connection
+		// to anything but localhost.
+		if err := c.Auth(smtp.PlainAuth("", t.Username, t.Password, t.Host)); err != nil {
+			return fmt.Errorf("mailer: auth: %w", err)
+		}
+	}
+
+	if err := c.Mail(from); err != nil {
+		return fmt.Errorf("mailer: MAIL FROM: %w", err)
+	}
+	for _, r := range rcpts {
+		if err := c.Rcpt(r); err != nil {
+			return fmt.Errorf("mailer: RCPT TO %s: %w", r, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("mailer: DATA: %w", err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		return fmt.Errorf("mailer: DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mailer: DATA: %w", err)
+	}
+	return nil
+}
+
+func localName() string {
+	if name, err := os.Hostname(); err == nil && name != "" {
+		return name
+	}
+	return "localhost"
+}
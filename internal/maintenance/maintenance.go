@@ -0,0 +1,132 @@
+// Package maintenance works out when cars are due for service and records the
+// work done on them. A service plan is due every IntervalKm kilometres or
+// IntervalMonths months after its last service, whichever comes first.
+package maintenance
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"rental-saas/internal/pricing"
+)
+
+// A plan is due soon this many kilometres or days before it is due.
+const (
+	DueSoonKm   = 1000
+	DueSoonDays = 14
+)
+
+// Due states.
+const (
+	StateOK      = "ok"
+	StateDueSoon = "due_soon"
+	StateOverdue = "overdue"
+)
+
+// Work order statuses. Scheduled and in-progress orders hold the car for
+// their scheduled window.
+const (
+	WorkOrderScheduled  = "scheduled"
+	WorkOrderInProgress = "in_progress"
+	WorkOrderClosed     = "closed"
+	WorkOrderCancelled  = "cancelled"
+)
+
+type Plan struct {
+	ID                  string       `json:"id"`
+	CarID               string       `json:"car_id"`
+	Name                string       `json:"name"`
+	IntervalKm          *int         `json:"interval_km"`
+	IntervalMonths      *int         `json:"interval_months"`
+	LastServiceOdometer int          `json:"last_service_odometer"`
+	LastServiceDate     pricing.Date `json:"last_service_date"`
+	Active              bool         `json:"active"`
+}
+
+func (p Plan) Validate() error {
+	if strings.TrimSpace(p.Name) == "" {
+		return errors.New("name is required")
+	}
+	if p.IntervalKm == nil && p.IntervalMonths == nil {
+		return errors.New("interval_km or interval_months is required")
+	}
+	if (p.IntervalKm != nil && *p.IntervalKm <= 0) || (p.IntervalMonths != nil && *p.IntervalMonths <= 0) {
+		return errors.New("intervals must be positive")
+	}
+	if p.LastServiceOdometer < 0 {
+		return errors.New("last_service_odometer cannot be negative")
+	}
+	return nil
+}
+
+type Due struct {
+	State         string        `json:"state"`
+	DueOdometer   *int          `json:"due_odometer,omitempty"`
+	KmRemaining   *int          `json:"km_remaining,omitempty"`
+	DueDate       *pricing.Date `json:"due_date,omitempty"`
+	DaysRemaining *int          `json:"days_remaining,omitempty"`
+}
+
+// Due works out the plan's state for a car at odometer on the date of now.
+func (p Plan) Due(odometer int, now time.Time) Due {
+	d := Due{State: StateOK}
+	worse := func(state string) {
+		if state == StateOverdue || d.State == StateOK {
+			d.State = state
+		}
+	}
+
+	if p.IntervalKm != nil {
+		due := p.LastServiceOdometer + *p.IntervalKm
+		left := due - odometer
+		d.DueOdometer, d.KmRemaining = &due, &left
+		switch {
+		case left <= 0:
+			worse(StateOverdue)
+		case left <= DueSoonKm:
+			worse(StateDueSoon)
+		}
+	}
+	if p.IntervalMonths != nil && !p.LastServiceDate.IsZero() {
+		due := pricing.Date{Time: p.LastServiceDate.AddDate(0, *p.IntervalMonths, 0)}
+		y, m, day := now.Date()
+		today := time.Date(y, m, day, 0, 0, 0, 0, time.UTC)
+		left := int(due.Sub(today).Hours() / 24)
+		d.DueDate, d.DaysRemaining = &due, &left
+		switch {
+		case left <= 0:
+			worse(StateOverdue)
+		case left <= DueSoonDays:
+			worse(StateDueSoon)
+		}
+	}
+	return d
+}
+
+type WorkOrder struct {
+	ID             string     `json:"id"`
+	CarID          string     `json:"car_id"`
+	ServicePlanID  *string    `json:"service_plan_id"`
+	Status         string     `json:"status"`
+	Description    string     `json:"description"`
+	Vendor         string     `json:"vendor"`
+	CostCents      int        `json:"cost_cents"`
+	ScheduledStart time.Time  `json:"scheduled_start"`
+	ScheduledEnd   time.Time  `json:"scheduled_end"`
+	StartedAt      *time.Time `json:"started_at"`
+	ClosedAt       *time.Time `json:"closed_at"`
+	Odometer       *int       `json:"odometer"`
+	Notes          string     `json:"notes"`
+	// Hours the car was out of service, once closed
+	DowntimeHours *float64 `json:"downtime_hours,omitempty"`
+}
+
+// SetDowntime fills DowntimeHours from StartedAt and ClosedAt.
+func (wo *WorkOrder) SetDowntime() {
+	if wo.StartedAt == nil || wo.ClosedAt == nil {
+		return
+	}
+	h := wo.ClosedAt.Sub(*wo.StartedAt).Hours()
+	wo.DowntimeHours = &h
+}
@@ -0,0 +1,90 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+
+	"rental-saas/internal/pricing"
+)
+
+func intp(v int) *int { return &v }
+
+func plan(km, months *int) Plan {
+	last, _ := pricing.ParseDate("2025-01-15")
+	return Plan{Name: "Service", IntervalKm: km, IntervalMonths: months, LastServiceOdometer: 30000, LastServiceDate: last}
+}
+
+func TestDueByDistance(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := plan(intp(15000), nil)
+
+	cases := map[int]string{
+		40000: StateOK,
+		44000: StateDueSoon,
+		45000: StateOverdue,
+		47000: StateOverdue,
+	}
+	for odometer, want := range cases {
+		d := p.Due(odometer, now)
+		if d.State != want {
+			t.Errorf("at %d km: %s, want %s", odometer, d.State, want)
+		}
+		if *d.DueOdometer != 45000 || *d.KmRemaining != 45000-odometer || d.DueDate != nil {
+			t.Errorf("at %d km: %+v", odometer, d)
+		}
+	}
+}
+
+func TestDueByCalendar(t *testing.T) {
+	p := plan(nil, intp(12))
+	cases := map[string]string{
+		"2025-12-01": StateOK,
+		"2026-01-05": StateDueSoon,
+		"2026-01-15": StateOverdue,
+	}
+	for day, want := range cases {
+		now, _ := time.Parse(time.DateOnly, day)
+		d := p.Due(0, now.Add(15*time.Hour))
+		if d.State != want {
+			t.Errorf("on %s: %s, want %s (%+v)", day, d.State, want, d)
+		}
+		if d.DueDate.String() != "2026-01-15" || d.DueOdometer != nil {
+			t.Errorf("on %s: %+v", day, d)
+		}
+	}
+}
+
+func TestDueWhicheverComesFirst(t *testing.T) {
+	p := plan(intp(15000), intp(12))
+	now := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	if d := p.Due(31000, now); d.State != StateOverdue {
+		t.Errorf("overdue by date, few km: %s", d.State)
+	}
+	now = time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	if d := p.Due(44500, now); d.State != StateDueSoon {
+		t.Errorf("due soon by km: %s", d.State)
+	}
+}
+
+func TestPlanValidate(t *testing.T) {
+	if err := plan(intp(15000), intp(12)).Validate(); err != nil {
+		t.Error(err)
+	}
+	backwards := plan(intp(15000), nil)
+	backwards.LastServiceOdometer = -1
+	cases := []struct {
+		plan Plan
+		want string
+	}{
+		{plan(nil, nil), "interval_km or interval_months is required"},
+		{plan(intp(0), nil), "intervals must be positive"},
+		{plan(intp(15000), intp(-1)), "intervals must be positive"},
+		{backwards, "last_service_odometer cannot be negative"},
+		{Plan{IntervalKm: intp(1)}, "name is required"},
+	}
+	for _, c := range cases {
+		if err := c.plan.Validate(); err == nil || err.Error() != c.want {
+			t.Errorf("%+v: %v, want %q", c.plan, err, c.want)
+		}
+	}
+}
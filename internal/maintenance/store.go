@@ -0,0 +1,84 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"rental-saas/internal/webhooks"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const PlanColumns = `id, car_id, name, interval_km, interval_months, last_service_odometer, last_service_date, active`
+
+func ScanPlan(row pgx.Row, p *Plan) error {
+	return row.Scan(&p.ID, &p.CarID, &p.Name, &p.IntervalKm, &p.IntervalMonths, &p.LastServiceOdometer, &p.LastServiceDate, &p.Active)
+}
+
+const WorkOrderColumns = `id, car_id, service_plan_id, status, description, vendor, cost_cents,
+	scheduled_start, scheduled_end, started_at, closed_at, odometer, notes`
+
+func ScanWorkOrder(row pgx.Row, wo *WorkOrder) error {
+	err := row.Scan(&wo.ID, &wo.CarID, &wo.ServicePlanID, &wo.Status, &wo.Description, &wo.Vendor, &wo.CostCents,
+		&wo.ScheduledStart, &wo.ScheduledEnd, &wo.StartedAt, &wo.ClosedAt, &wo.Odometer, &wo.Notes)
+	if err != nil {
+		return err
+	}
+	wo.SetDowntime()
+	return nil
+}
+
+// CheckDue sends car.service_due for each of the car's active plans that has
+// come due since it was last serviced. Call it after the odometer changes.
+func CheckDue(ctx context.Context, tx pgx.Tx, carID string, now time.Time) error {
+	var odometer int
+	var plate string
+	err := tx.QueryRow(ctx, "SELECT odometer, license_plate FROM cars WHERE id = $1", carID).Scan(&odometer, &plate)
+	if err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT `+PlanColumns+` FROM service_plans
+		WHERE car_id = $1 AND active AND due_alerted_at IS NULL
+		FOR UPDATE
+	`, carID)
+	if err != nil {
+		return err
+	}
+	var plans []Plan
+	for rows.Next() {
+		var p Plan
+		if err := ScanPlan(rows, &p); err != nil {
+			rows.Close()
+			return err
+		}
+		plans = append(plans, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, p := range plans {
+		due := p.Due(odometer, now)
+		if due.State == StateOK {
+			continue
+		}
+		if _, err := tx.Exec(ctx, "UPDATE service_plans SET due_alerted_at = NOW() WHERE id = $1", p.ID); err != nil {
+			return err
+		}
+		payload := webhooks.ServiceDuePayload{
+			CarID: carID, LicensePlate: plate, ServicePlanID: p.ID, PlanName: p.Name,
+			State: due.State, Odometer: odometer, DueOdometer: due.DueOdometer,
+		}
+		if due.DueDate != nil {
+			s := due.DueDate.String()
+			payload.DueDate = &s
+		}
+		if err := webhooks.EmitServiceDue(ctx, tx, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,12 +3,9 @@ package middleware_test
 import (
 	"context"
 	"fmt"
-	"net/http"
-	"net/http/httptest"
 	"testing"
 
 	"rental-saas/internal/database"
-	"rental-saas/internal/middleware"
 
 	"github.com/jackc/pgx/v5"
 )
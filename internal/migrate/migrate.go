@@ -0,0 +1,462 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"rental-saas/migrations"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Scope string
+
+const (
+	ScopePublic Scope = "public"
+	ScopeTenant Scope = "tenant"
+)
+
+var ErrSchemaBehind = errors.New("database schema is behind the binary")
+
+// Migration is one versioned SQL file. Versions are unique across both scopes,
+// so a single schema_migrations table per schema can record either kind.
+type Migration struct {
+	Version int
+	Name    string
+	Scope   Scope
+	Up      string
+	Down    string
+}
+
+// Load parses the embedded migrations. Files are named NNN_name.sql with an
+// optional NNN_name.down.sql next to them.
+func Load() ([]Migration, error) {
+	return load(migrations.FS)
+}
+
+func load(fsys fs.FS) ([]Migration, error) {
+	byVersion := map[int]*Migration{}
+
+	for _, scope := range []Scope{ScopePublic, ScopeTenant} {
+		files, err := fs.Glob(fsys, string(scope)+"/*.sql")
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			base := strings.TrimSuffix(path.Base(file), ".sql")
+			down := strings.HasSuffix(base, ".down")
+			base = strings.TrimSuffix(base, ".down")
+
+			prefix, name, ok := strings.Cut(base, "_")
+			if !ok {
+				return nil, fmt.Errorf("migration %s: expected NNN_name.sql", file)
+			}
+			version, err := strconv.Atoi(prefix)
+			if err != nil {
+				return nil, fmt.Errorf("migration %s: invalid version: %w", file, err)
+			}
+
+			body, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+
+			m, exists := byVersion[version]
+			if !exists {
+				m = &Migration{Version: version, Name: name, Scope: scope}
+				byVersion[version] = m
+			}
+			if m.Scope != scope || m.Name != name {
+				return nil, fmt.Errorf("migration %s: version %d already used by %s/%s", file, version, m.Scope, m.Name)
+			}
+			if down {
+				m.Down = string(body)
+			} else {
+				m.Up = string(body)
+			}
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %03d_%s has a down file but no up file", m.Version, m.Name)
+		}
+		list = append(list, *m)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+type Migrator struct {
+	DB          *pgxpool.Pool
+	Migrations  []Migration
+	Concurrency int
+	// DryRun applies pending migrations inside a transaction that is always
+	// rolled back, so the SQL is validated without changing anything.
+	DryRun bool
+	Logf   func(format string, args ...interface{})
+}
+
+func New(db *pgxpool.Pool) (*Migrator, error) {
+	list, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		DB:          db,
+		Migrations:  list,
+		Concurrency: 4,
+		Logf:        func(string, ...interface{}) {},
+	}, nil
+}
+
+// Latest returns the highest version the binary knows for the given scope.
+func (m *Migrator) Latest(scope Scope) int {
+	latest := 0
+	for _, mig := range m.Migrations {
+		if mig.Scope == scope && mig.Version > latest {
+			latest = mig.Version
+		}
+	}
+	return latest
+}
+
+func (m *Migrator) scoped(scope Scope) []Migration {
+	var list []Migration
+	for _, mig := range m.Migrations {
+		if mig.Scope == scope {
+			list = append(list, mig)
+		}
+	}
+	return list
+}
+
+// SchemaStatus describes how far a single schema is from the binary.
+type SchemaStatus struct {
+	Schema  string `json:"schema"`
+	Scope   Scope  `json:"scope"`
+	Current int    `json:"current_version"`
+	Latest  int    `json:"latest_version"`
+	Pending []int  `json:"pending,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (s SchemaStatus) Behind() bool {
+	return len(s.Pending) > 0 || s.Error != ""
+}
+
+const trackingTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+	)`
+
+// scope sets the search_path and takes a per-schema advisory lock so two
+// migrators (e.g. two pods starting at once) never race on the same schema.
+func scope(ctx context.Context, tx pgx.Tx, schema string) error {
+	ident := pgx.Identifier{schema}.Sanitize()
+	if _, err := tx.Exec(ctx, "SET LOCAL search_path TO "+ident); err != nil {
+		return fmt.Errorf("failed to set search_path: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('schema_migrations:' || $1))", schema); err != nil {
+		return fmt.Errorf("failed to lock schema: %w", err)
+	}
+	_, err := tx.Exec(ctx, trackingTable)
+	return err
+}
+
+func appliedVersions(ctx context.Context, tx pgx.Tx) (map[int]bool, error) {
+	rows, err := tx.Query(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]bool{}
+	for rows.Next() {
+		var v int
+		if err := rows.Scan(&v); err != nil {
+			return nil, err
+		}
+		applied[v] = true
+	}
+	return applied, rows.Err()
+}
+
+func applyOne(ctx context.Context, tx pgx.Tx, mig Migration) error {
+	if _, err := tx.Exec(ctx, mig.Up); err != nil {
+		return fmt.Errorf("migration %03d_%s failed: %w", mig.Version, mig.Name, err)
+	}
+	_, err := tx.Exec(ctx, "INSERT INTO schema_migrations (version, name) VALUES ($1, $2)", mig.Version, mig.Name)
+	return err
+}
+
+// ApplyTenant brings the schema already selected on tx up to date. It is used
+// by provisioning so a new tenant is migrated in the same transaction that
+// creates it.
+func (m *Migrator) ApplyTenant(ctx context.Context, tx pgx.Tx, schema string) error {
+	if err := scope(ctx, tx, schema); err != nil {
+		return err
+	}
+	applied, err := appliedVersions(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range m.scoped(ScopeTenant) {
+		if applied[mig.Version] {
+			continue
+		}
+		if err := applyOne(ctx, tx, mig); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateSchema applies each pending migration in its own transaction so a
+// failure keeps the progress made so far. Dry runs use one transaction for
+// everything and roll it back.
+func (m *Migrator) migrateSchema(ctx context.Context, schema string, sc Scope) ([]Migration, error) {
+	var done []Migration
+
+	if m.DryRun {
+		tx, err := m.DB.Begin(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer tx.Rollback(ctx)
+
+		if err := scope(ctx, tx, schema); err != nil {
+			return nil, err
+		}
+		applied, err := appliedVersions(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		for _, mig := range m.scoped(sc) {
+			if applied[mig.Version] {
+				continue
+			}
+			if err := applyOne(ctx, tx, mig); err != nil {
+				return done, err
+			}
+			m.Logf("[dry-run] %s: would apply %03d_%s", schema, mig.Version, mig.Name)
+			done = append(done, mig)
+		}
+		return done, nil
+	}
+
+	for _, mig := range m.scoped(sc) {
+		applied := false
+		err := m.inTx(ctx, schema, func(tx pgx.Tx) error {
+			versions, err := appliedVersions(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if versions[mig.Version] {
+				return nil
+			}
+			applied = true
+			return applyOne(ctx, tx, mig)
+		})
+		if err != nil {
+			return done, err
+		}
+		if applied {
+			m.Logf("%s: applied %03d_%s", schema, mig.Version, mig.Name)
+			done = append(done, mig)
+		}
+	}
+	return done, nil
+}
+
+func (m *Migrator) inTx(ctx context.Context, schema string, fn func(tx pgx.Tx) error) error {
+	tx, err := m.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := scope(ctx, tx, schema); err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (m *Migrator) MigratePublic(ctx context.Context) ([]Migration, error) {
+	return m.migrateSchema(ctx, "public", ScopePublic)
+}
+
+func (m *Migrator) MigrateTenant(ctx context.Context, schema string) ([]Migration, error) {
+	return m.migrateSchema(ctx, schema, ScopeTenant)
+}
+
+// TenantSchemas lists every schema registered in public.tenants.
+func (m *Migrator) TenantSchemas(ctx context.Context) ([]string, error) {
+	rows, err := m.DB.Query(ctx, "SELECT DISTINCT schema_name FROM public.tenants ORDER BY schema_name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schemas []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, s)
+	}
+	return schemas, rows.Err()
+}
+
+type TenantResult struct {
+	Schema  string
+	Applied []Migration
+	Err     error
+}
+
+// MigrateAllTenants migrates every tenant schema, at most Concurrency at a
+// time. A failing tenant does not stop the others; check each result's Err.
+func (m *Migrator) MigrateAllTenants(ctx context.Context) ([]TenantResult, error) {
+	schemas, err := m.TenantSchemas(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := m.Concurrency
+	if limit < 1 {
+		limit = 1
+	}
+	sem := make(chan struct{}, limit)
+	results := make([]TenantResult, len(schemas))
+
+	var wg sync.WaitGroup
+	for i, schema := range schemas {
+		wg.Add(1)
+		go func(i int, schema string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			applied, err := m.MigrateTenant(ctx, schema)
+			results[i] = TenantResult{Schema: schema, Applied: applied, Err: err}
+		}(i, schema)
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (m *Migrator) schemaStatus(ctx context.Context, schema string, sc Scope) SchemaStatus {
+	status := SchemaStatus{Schema: schema, Scope: sc, Latest: m.Latest(sc)}
+
+	var applied map[int]bool
+	err := m.inTx(ctx, schema, func(tx pgx.Tx) error {
+		var err error
+		applied, err = appliedVersions(ctx, tx)
+		return err
+	})
+	if err != nil {
+		status.Error = err.Error()
+		return status
+	}
+
+	for _, mig := range m.scoped(sc) {
+		if applied[mig.Version] {
+			if mig.Version > status.Current {
+				status.Current = mig.Version
+			}
+		} else {
+			status.Pending = append(status.Pending, mig.Version)
+		}
+	}
+	return status
+}
+
+// Status reports the public schema followed by every tenant schema.
+func (m *Migrator) Status(ctx context.Context) ([]SchemaStatus, error) {
+	statuses := []SchemaStatus{m.schemaStatus(ctx, "public", ScopePublic)}
+
+	schemas, err := m.TenantSchemas(ctx)
+	if err != nil {
+		return statuses, err
+	}
+	for _, schema := range schemas {
+		statuses = append(statuses, m.schemaStatus(ctx, schema, ScopeTenant))
+	}
+	return statuses, nil
+}
+
+// CheckCurrent returns ErrSchemaBehind if any schema has pending migrations.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	var behind []string
+	for _, s := range statuses {
+		if s.Behind() {
+			behind = append(behind, fmt.Sprintf("%s (at %d, binary has %d)", s.Schema, s.Current, s.Latest))
+		}
+	}
+	if len(behind) > 0 {
+		return fmt.Errorf("%w: %s", ErrSchemaBehind, strings.Join(behind, ", "))
+	}
+	return nil
+}
+
+// Rollback runs down migrations in reverse order until the schema is at the
+// target version. Every migration being reverted must ship a .down.sql file.
+func (m *Migrator) Rollback(ctx context.Context, schema string, sc Scope, target int) ([]Migration, error) {
+	list := m.scoped(sc)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version > list[j].Version })
+
+	var reverted []Migration
+	err := m.inTx(ctx, schema, func(tx pgx.Tx) error {
+		applied, err := appliedVersions(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, mig := range list {
+			if mig.Version <= target || !applied[mig.Version] {
+				continue
+			}
+			if mig.Down == "" {
+				return fmt.Errorf("migration %03d_%s has no down migration", mig.Version, mig.Name)
+			}
+			if _, err := tx.Exec(ctx, mig.Down); err != nil {
+				return fmt.Errorf("down migration %03d_%s failed: %w", mig.Version, mig.Name, err)
+			}
+			if _, err := tx.Exec(ctx, "DELETE FROM schema_migrations WHERE version = $1", mig.Version); err != nil {
+				return err
+			}
+			m.Logf("%s: reverted %03d_%s", schema, mig.Version, mig.Name)
+			reverted = append(reverted, mig)
+		}
+		if m.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return reverted, nil
+	}
+	return reverted, err
+}
+
+var errDryRun = errors.New("dry run")
@@ -0,0 +1,56 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	list, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load embedded migrations: %v", err)
+	}
+	if len(list) == 0 {
+		t.Fatal("Expected embedded migrations")
+	}
+
+	for i := 1; i < len(list); i++ {
+		if list[i].Version <= list[i-1].Version {
+			t.Errorf("Migrations out of order: %d after %d", list[i].Version, list[i-1].Version)
+		}
+	}
+	if list[0].Scope != ScopePublic {
+		t.Errorf("Expected first migration to target the public schema, got %s", list[0].Scope)
+	}
+}
+
+func TestLoadRejectsDuplicateVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"public/001_init.sql":  {Data: []byte("SELECT 1;")},
+		"tenant/001_other.sql": {Data: []byte("SELECT 1;")},
+	}
+	if _, err := load(fsys); err == nil {
+		t.Error("Expected error for version used in both scopes")
+	}
+}
+
+func TestLoadPairsDownFiles(t *testing.T) {
+	fsys := fstest.MapFS{
+		"tenant/002_cars.sql":      {Data: []byte("CREATE TABLE cars ();")},
+		"tenant/002_cars.down.sql": {Data: []byte("DROP TABLE cars;")},
+		"tenant/003_users.sql":     {Data: []byte("CREATE TABLE users ();")},
+	}
+	list, err := load(fsys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 migrations, got %d", len(list))
+	}
+	if list[0].Down != "DROP TABLE cars;" {
+		t.Errorf("Expected down migration to be paired, got %q", list[0].Down)
+	}
+	if list[1].Down != "" {
+		t.Errorf("Expected no down migration for 003, got %q", list[1].Down)
+	}
+}
@@ -0,0 +1,49 @@
+package models
+
+import "fmt"
+
+type BookingStatus string
+
+const (
+	BookingStatusPending   BookingStatus = "pending"
+	BookingStatusConfirmed BookingStatus = "confirmed"
+	BookingStatusActive    BookingStatus = "active"
+	BookingStatusCompleted BookingStatus = "completed"
+	BookingStatusCancelled BookingStatus = "cancelled"
+	BookingStatusNoShow    BookingStatus = "no_show"
+)
+
+// bookingTransitions is the booking state machine. A booking is created
+// pending, confirmed once the payment is authorized, active from pickup and
+// completed on return. Completed, cancelled and no-show are terminal.
+var bookingTransitions = map[BookingStatus][]BookingStatus{
+	BookingStatusPending:   {BookingStatusConfirmed, BookingStatusCancelled},
+	BookingStatusConfirmed: {BookingStatusActive, BookingStatusCancelled, BookingStatusNoShow},
+	BookingStatusActive:    {BookingStatusCompleted},
+}
+
+func (s BookingStatus) CanTransitionTo(next BookingStatus) bool {
+	for _, allowed := range bookingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+type TransitionError struct {
+	From BookingStatus
+	To   BookingStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("booking cannot go from %s to %s", e.From, e.To)
+}
+
+// ValidateBookingTransition returns a *TransitionError for illegal moves.
+func ValidateBookingTransition(from, to BookingStatus) error {
+	if !from.CanTransitionTo(to) {
+		return &TransitionError{From: from, To: to}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBookingTransitions(t *testing.T) {
+	cases := []struct {
+		from, to BookingStatus
+		allowed  bool
+	}{
+		{BookingStatusPending, BookingStatusConfirmed, true},
+		{BookingStatusPending, BookingStatusCancelled, true},
+		{BookingStatusPending, BookingStatusActive, false},
+		{BookingStatusPending, BookingStatusCompleted, false},
+		{BookingStatusConfirmed, BookingStatusActive, true},
+		{BookingStatusConfirmed, BookingStatusNoShow, true},
+		{BookingStatusConfirmed, BookingStatusCancelled, true},
+		{BookingStatusActive, BookingStatusCompleted, true},
+		{BookingStatusActive, BookingStatusCancelled, false},
+		{BookingStatusCompleted, BookingStatusActive, false},
+		{BookingStatusCancelled, BookingStatusConfirmed, false},
+		{BookingStatusNoShow, BookingStatusActive, false},
+	}
+
+	for _, tc := range cases {
+		err := ValidateBookingTransition(tc.from, tc.to)
+		if tc.allowed && err != nil {
+			t.Errorf("%s -> %s: expected allowed, got %v", tc.from, tc.to, err)
+		}
+		if !tc.allowed {
+			var te *TransitionError
+			if !errors.As(err, &te) {
+				t.Errorf("%s -> %s: expected TransitionError, got %v", tc.from, tc.to, err)
+			}
+		}
+	}
+}
@@ -23,6 +23,17 @@ type Car struct {
 	Status         CarStatus `json:"status"`
 	DailyRateCents int       `json:"daily_rate_cents"`
 	ImageURL       string    `json:"image_url,omitempty"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
+	Odometer       int       `json:"odometer"`
+	// IncludedKmPerDay is the distance included in each rental day; nil means unlimited.
+	IncludedKmPerDay  *int   `json:"included_km_per_day"`
+	OverageCentsPerKm int    `json:"overage_cents_per_km"`
+	FuelType          string `json:"fuel_type"`
+	// EnergyLevel is the last fuel (eighths) or charge (percent) reading; nil if never recorded.
+	EnergyLevel *int `json:"energy_level"`
+	// BranchID is the branch the car is at; nil for tenants without branches.
+	BranchID *string `json:"branch_id"`
+	// ClassID is the vehicle class the car is rented out as; nil if it is only booked by itself.
+	ClassID   *string   `json:"class_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+type Customer struct {
+	ID              string    `json:"id"`
+	FirstName       string    `json:"first_name"`
+	LastName        string    `json:"last_name"`
+	Email           string    `json:"email"`
+	Phone           string    `json:"phone,omitempty"`
+	DriverLicenseID string    `json:"driver_license_id,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
@@ -0,0 +1,46 @@
+package pricing
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Date is a calendar date, "2006-01-02" in JSON and DATE in Postgres.
+type Date struct {
+	time.Time
+}
+
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(time.DateOnly, s)
+	return Date{t}, err
+}
+
+func (d Date) String() string { return d.Format(time.DateOnly) }
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Date) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+func (d *Date) ScanDate(v pgtype.Date) error {
+	d.Time = v.Time
+	return nil
+}
+
+func (d Date) DateValue() (pgtype.Date, error) {
+	return pgtype.Date{Time: d.Time, Valid: true}, nil
+}
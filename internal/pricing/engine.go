@@ -0,0 +1,344 @@
+// Package pricing turns a car, a rental period and a booking channel into an
+// itemised quote, using the tenant's pricing rules. The widget listing,
+// booking creation and settlement all price through Calculate so the amount a
+// customer is shown is the amount they are charged.
+package pricing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Rule kinds.
+const (
+	// KindSeason sets or adjusts the daily rate on dates between StartDate and EndDate.
+	KindSeason = "season"
+	// KindDayOfWeek sets or adjusts the daily rate on DaysOfWeek (0 = Sunday), e.g. weekends.
+	KindDayOfWeek = "day_of_week"
+	// KindLengthTier discounts (or surcharges) the rental by Percent from MinDays on,
+	// e.g. weekly and monthly rates. Only the longest matching tier applies.
+	KindLengthTier = "length_tier"
+	// KindMinimumLength rejects rentals shorter than MinDays.
+	KindMinimumLength = "minimum_length"
+	// KindBlackout adds AmountCents once to any rental that has a day between
+	// StartDate and EndDate.
+	KindBlackout = "blackout_surcharge"
+)
+
+// Booking channels; a rule with a Channel only applies to that channel.
+const (
+	ChannelDashboard = "dashboard"
+	ChannelWidget    = "widget"
+)
+
+// Line kinds.
+const (
+	LineRental    = "rental"
+	LineDiscount  = "discount"
+	LineSurcharge = "surcharge"
+	LineDamage    = "damage"
+	LineOneWay    = "one_way_fee"
+	LineExtra     = "extra"
+	LineTax       = "tax"
+)
+
+var ErrMinimumLength = errors.New("rental is shorter than the minimum length")
+
+type Rule struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Kind           string  `json:"kind"`
+	CarID          *string `json:"car_id"`
+	Channel        *string `json:"channel"`
+	StartDate      *Date   `json:"start_date"`
+	EndDate        *Date   `json:"end_date"`
+	DaysOfWeek     []int   `json:"days_of_week"`
+	MinDays        *int    `json:"min_days"`
+	Percent        *int    `json:"percent"`
+	DailyRateCents *int    `json:"daily_rate_cents"`
+	AmountCents    *int    `json:"amount_cents"`
+	Priority       int     `json:"priority"`
+	Active         bool    `json:"active"`
+}
+
+// Validate checks that the fields the rule's kind needs are present and sane.
+func (r Rule) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name is required")
+	}
+	if r.Channel != nil && *r.Channel != ChannelDashboard && *r.Channel != ChannelWidget {
+		return errors.New("channel must be dashboard or widget")
+	}
+
+	rateOrPercent := func() error {
+		if (r.Percent == nil) == (r.DailyRateCents == nil) {
+			return errors.New("exactly one of percent and daily_rate_cents is required")
+		}
+		if r.DailyRateCents != nil && *r.DailyRateCents <= 0 {
+			return errors.New("daily_rate_cents must be positive")
+		}
+		if r.Percent != nil && (*r.Percent <= -100 || *r.Percent == 0) {
+			return errors.New("percent must be non-zero and greater than -100")
+		}
+		return nil
+	}
+	dates := func() error {
+		if r.StartDate == nil || r.EndDate == nil || r.EndDate.Before(r.StartDate.Time) {
+			return errors.New("start_date and end_date are required and end_date cannot be before start_date")
+		}
+		return nil
+	}
+
+	switch r.Kind {
+	case KindSeason:
+		if err := dates(); err != nil {
+			return err
+		}
+		return rateOrPercent()
+	case KindDayOfWeek:
+		if len(r.DaysOfWeek) == 0 {
+			return errors.New("days_of_week is required")
+		}
+		for _, d := range r.DaysOfWeek {
+			if d < 0 || d > 6 {
+				return errors.New("days_of_week must be between 0 (Sunday) and 6 (Saturday)")
+			}
+		}
+		return rateOrPercent()
+	case KindLengthTier:
+		if r.MinDays == nil || *r.MinDays < 2 {
+			return errors.New("min_days must be at least 2")
+		}
+		if r.Percent == nil || *r.Percent < -100 || *r.Percent == 0 {
+			return errors.New("percent must be non-zero and at least -100")
+		}
+		return nil
+	case KindMinimumLength:
+		if r.MinDays == nil || *r.MinDays < 1 {
+			return errors.New("min_days must be at least 1")
+		}
+		return nil
+	case KindBlackout:
+		if err := dates(); err != nil {
+			return err
+		}
+		if r.AmountCents == nil || *r.AmountCents <= 0 {
+			return errors.New("amount_cents must be positive")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown kind %q", r.Kind)
+}
+
+func (r Rule) appliesTo(carID, channel string) bool {
+	if !r.Active {
+		return false
+	}
+	if r.CarID != nil && *r.CarID != carID {
+		return false
+	}
+	return r.Channel == nil || *r.Channel == channel
+}
+
+func (r Rule) coversDate(d time.Time) bool {
+	return r.StartDate != nil && r.EndDate != nil && !d.Before(dateOnly(r.StartDate.Time)) && !d.After(dateOnly(r.EndDate.Time))
+}
+
+type Request struct {
+	CarID          string
+	DailyRateCents int
+	Start, End     time.Time
+	Channel        string
+}
+
+type Line struct {
+	Kind            string  `json:"kind"`
+	Description     string  `json:"description"`
+	Quantity        int     `json:"quantity"`
+	UnitAmountCents int     `json:"unit_amount_cents"`
+	AmountCents     int     `json:"amount_cents"`
+	RuleID          *string `json:"rule_id,omitempty"`
+	// Included marks a line whose amount is already part of the others, such
+	// as a tax contained in the prices. It is shown but not added up.
+	Included bool `json:"included,omitempty"`
+}
+
+type Quote struct {
+	Days        int    `json:"days"`
+	MinimumDays int    `json:"minimum_days,omitempty"`
+	Lines       []Line `json:"lines"`
+	TotalCents  int    `json:"total_cents"`
+	Currency    string `json:"currency,omitempty"`
+}
+
+// Bookable reports whether the rental meets the minimum length. Settlement
+// ignores it: a booking that already exists is charged whatever its length.
+func (q Quote) Bookable() error {
+	if q.Days < q.MinimumDays {
+		return fmt.Errorf("%w of %d days", ErrMinimumLength, q.MinimumDays)
+	}
+	return nil
+}
+
+// Add appends a line the rules do not produce, such as a one-way fee.
+func (q *Quote) Add(l Line) {
+	q.Lines = append(q.Lines, l)
+	q.TotalCents = Total(q.Lines)
+}
+
+// Days is the number of rental days charged: every started 24 hours counts.
+func Days(start, end time.Time) int {
+	d := end.Sub(start)
+	days := int(d / (24 * time.Hour))
+	if d%(24*time.Hour) != 0 || days == 0 {
+		days++
+	}
+	return days
+}
+
+// Calculate prices a rental. Each rental day starts a multiple of 24 hours
+// after pickup and takes the calendar date it starts on, in pickup's time zone.
+func Calculate(rules []Rule, req Request) Quote {
+	var applicable []Rule
+	for _, r := range rules {
+		if r.appliesTo(req.CarID, req.Channel) {
+			applicable = append(applicable, r)
+		}
+	}
+	// Highest priority first, so the first match of a kind wins
+	sort.SliceStable(applicable, func(i, j int) bool { return applicable[i].Priority > applicable[j].Priority })
+
+	q := Quote{Days: Days(req.Start, req.End)}
+
+	var dates []time.Time
+	var rental []Line
+	rentalSubtotal := 0
+	for i := 0; i < q.Days; i++ {
+		date := dateOnly(req.Start.Add(time.Duration(i) * 24 * time.Hour))
+		dates = append(dates, date)
+
+		rate := req.DailyRateCents
+		var names []string
+		if r, ok := first(applicable, KindSeason, func(r Rule) bool { return r.coversDate(date) }); ok {
+			rate = adjust(rate, r)
+			names = append(names, r.Name)
+		}
+		if r, ok := first(applicable, KindDayOfWeek, func(r Rule) bool { return containsInt(r.DaysOfWeek, int(date.Weekday())) }); ok {
+			rate = adjust(rate, r)
+			names = append(names, r.Name)
+		}
+		rentalSubtotal += rate
+
+		// Consecutive days at the same rate for the same reasons share a line
+		desc := "Daily rate"
+		if len(names) > 0 {
+			desc += " (" + strings.Join(names, ", ") + ")"
+		}
+		if n := len(rental); n > 0 && rental[n-1].UnitAmountCents == rate && rental[n-1].Description == desc {
+			rental[n-1].Quantity++
+			rental[n-1].AmountCents += rate
+			continue
+		}
+		rental = append(rental, Line{Kind: LineRental, Description: desc, Quantity: 1, UnitAmountCents: rate, AmountCents: rate})
+	}
+	q.Lines = append(q.Lines, rental...)
+
+	// Longest tier the rental reaches
+	var tier *Rule
+	for i, r := range applicable {
+		if r.Kind == KindLengthTier && q.Days >= *r.MinDays && (tier == nil || *r.MinDays > *tier.MinDays) {
+			tier = &applicable[i]
+		}
+	}
+	if tier != nil {
+		amount := percentOf(rentalSubtotal, *tier.Percent)
+		kind := LineDiscount
+		if amount > 0 {
+			kind = LineSurcharge
+		}
+		q.Lines = append(q.Lines, Line{
+			Kind: kind, Description: tier.Name, Quantity: 1,
+			UnitAmountCents: amount, AmountCents: amount, RuleID: &tier.ID,
+		})
+	}
+
+	for i, r := range applicable {
+		switch r.Kind {
+		case KindBlackout:
+			for _, d := range dates {
+				if r.coversDate(d) {
+					q.Lines = append(q.Lines, Line{
+						Kind: LineSurcharge, Description: r.Name, Quantity: 1,
+						UnitAmountCents: *r.AmountCents, AmountCents: *r.AmountCents, RuleID: &applicable[i].ID,
+					})
+					break
+				}
+			}
+		case KindMinimumLength:
+			if *r.MinDays > q.MinimumDays {
+				q.MinimumDays = *r.MinDays
+			}
+		}
+	}
+
+	q.TotalCents = Total(q.Lines)
+	return q
+}
+
+// Total sums lines other than included ones; it is never negative.
+func Total(lines []Line) int {
+	total := 0
+	for _, l := range lines {
+		if !l.Included {
+			total += l.AmountCents
+		}
+	}
+	if total < 0 {
+		return 0
+	}
+	return total
+}
+
+func first(rules []Rule, kind string, match func(Rule) bool) (Rule, bool) {
+	for _, r := range rules {
+		if r.Kind == kind && match(r) {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// adjust applies a rate rule: a fixed rate replaces the current one, a
+// percentage changes it.
+func adjust(rate int, r Rule) int {
+	if r.DailyRateCents != nil {
+		return *r.DailyRateCents
+	}
+	return rate + percentOf(rate, *r.Percent)
+}
+
+// percentOf returns pct% of amount rounded half away from zero.
+func percentOf(amount, pct int) int {
+	v := amount * pct
+	if v < 0 {
+		return -((-v + 50) / 100)
+	}
+	return (v + 50) / 100
+}
+
+func dateOnly(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func containsInt(list []int, v int) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
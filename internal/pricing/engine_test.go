@@ -0,0 +1,253 @@
+package pricing
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func intp(v int) *int       { return &v }
+func strp(v string) *string { return &v }
+func date(s string) *Date   { d, _ := ParseDate(s); return &d }
+
+// 2025-07-04 is a Friday.
+func request(start string, days int) Request {
+	s, _ := time.Parse(time.RFC3339, start)
+	return Request{CarID: "car-1", DailyRateCents: 10000, Start: s, End: s.Add(time.Duration(days) * 24 * time.Hour), Channel: ChannelWidget}
+}
+
+func TestDays(t *testing.T) {
+	s := time.Date(2025, 7, 4, 10, 0, 0, 0, time.UTC)
+	cases := map[time.Duration]int{
+		time.Hour:           1,
+		24 * time.Hour:      1,
+		25 * time.Hour:      2,
+		72 * time.Hour:      3,
+		72*time.Hour + 1e9:  4,
+		7 * 24 * time.Hour:  7,
+		30 * 24 * time.Hour: 30,
+	}
+	for d, want := range cases {
+		if got := Days(s, s.Add(d)); got != want {
+			t.Errorf("Days(%v) = %d, want %d", d, got, want)
+		}
+	}
+}
+
+func TestCalculateBaseRate(t *testing.T) {
+	q := Calculate(nil, request("2025-07-07T10:00:00Z", 3))
+	if q.Days != 3 || q.TotalCents != 30000 || len(q.Lines) != 1 || q.Lines[0].Quantity != 3 {
+		t.Errorf("unexpected quote %+v", q)
+	}
+}
+
+func TestCalculateSeasonAndWeekend(t *testing.T) {
+	rules := []Rule{
+		{ID: "s", Name: "Summer", Kind: KindSeason, StartDate: date("2025-07-05"), EndDate: date("2025-08-31"), Percent: intp(20), Active: true},
+		{ID: "w", Name: "Weekend", Kind: KindDayOfWeek, DaysOfWeek: []int{0, 6}, DailyRateCents: intp(15000), Active: true},
+	}
+	// Fri (before season), Sat, Sun, Mon
+	q := Calculate(rules, request("2025-07-04T10:00:00Z", 4))
+
+	want := []Line{
+		{Kind: LineRental, Description: "Daily rate", Quantity: 1, UnitAmountCents: 10000, AmountCents: 10000},
+		{Kind: LineRental, Description: "Daily rate (Summer, Weekend)", Quantity: 2, UnitAmountCents: 15000, AmountCents: 30000},
+		{Kind: LineRental, Description: "Daily rate (Summer)", Quantity: 1, UnitAmountCents: 12000, AmountCents: 12000},
+	}
+	if len(q.Lines) != len(want) {
+		t.Fatalf("lines = %+v", q.Lines)
+	}
+	for i := range want {
+		if q.Lines[i] != want[i] {
+			t.Errorf("line %d = %+v, want %+v", i, q.Lines[i], want[i])
+		}
+	}
+	if q.TotalCents != 52000 {
+		t.Errorf("total = %d", q.TotalCents)
+	}
+}
+
+func TestCalculateHighestPrioritySeasonWins(t *testing.T) {
+	rules := []Rule{
+		{Name: "Summer", Kind: KindSeason, StartDate: date("2025-07-01"), EndDate: date("2025-08-31"), Percent: intp(20), Active: true},
+		{Name: "Festival", Kind: KindSeason, StartDate: date("2025-07-07"), EndDate: date("2025-07-07"), DailyRateCents: intp(30000), Priority: 10, Active: true},
+	}
+	q := Calculate(rules, request("2025-07-07T10:00:00Z", 2))
+	if q.TotalCents != 30000+12000 {
+		t.Errorf("total = %d, lines %+v", q.TotalCents, q.Lines)
+	}
+}
+
+func TestCalculateLengthTiers(t *testing.T) {
+	rules := []Rule{
+		{ID: "wk", Name: "Weekly rate", Kind: KindLengthTier, MinDays: intp(7), Percent: intp(-10), Active: true},
+		{ID: "mo", Name: "Monthly rate", Kind: KindLengthTier, MinDays: intp(28), Percent: intp(-25), Active: true},
+	}
+	cases := map[int]int{
+		6:  60000,
+		7:  70000 - 7000,
+		30: 300000 - 75000,
+	}
+	for days, want := range cases {
+		q := Calculate(rules, request("2025-07-07T10:00:00Z", days))
+		if q.TotalCents != want {
+			t.Errorf("%d days: total = %d, want %d (%+v)", days, q.TotalCents, want, q.Lines)
+		}
+	}
+}
+
+func TestCalculateBlackoutAndMinimum(t *testing.T) {
+	rules := []Rule{
+		{ID: "nye", Name: "New Year's Eve", Kind: KindBlackout, StartDate: date("2025-12-31"), EndDate: date("2025-12-31"), AmountCents: intp(5000), Active: true},
+		{Name: "Three days minimum", Kind: KindMinimumLength, MinDays: intp(3), Active: true},
+	}
+
+	q := Calculate(rules, request("2025-12-30T10:00:00Z", 2))
+	if q.TotalCents != 25000 || q.Lines[len(q.Lines)-1].Kind != LineSurcharge {
+		t.Errorf("blackout not charged once: %+v", q)
+	}
+	if err := q.Bookable(); !errors.Is(err, ErrMinimumLength) {
+		t.Errorf("Bookable() = %v", err)
+	}
+
+	q = Calculate(rules, request("2026-01-02T10:00:00Z", 3))
+	if q.TotalCents != 30000 || q.Bookable() != nil {
+		t.Errorf("outside blackout: %+v, %v", q, q.Bookable())
+	}
+}
+
+func TestCalculateScopesRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "Other car", Kind: KindDayOfWeek, CarID: strp("car-2"), DaysOfWeek: []int{0, 1, 2, 3, 4, 5, 6}, Percent: intp(50), Active: true},
+		{Name: "Desk only", Kind: KindDayOfWeek, Channel: strp(ChannelDashboard), DaysOfWeek: []int{0, 1, 2, 3, 4, 5, 6}, Percent: intp(50), Active: true},
+		{Name: "Paused", Kind: KindDayOfWeek, DaysOfWeek: []int{0, 1, 2, 3, 4, 5, 6}, Percent: intp(50), Active: false},
+	}
+	if q := Calculate(rules, request("2025-07-07T10:00:00Z", 1)); q.TotalCents != 10000 {
+		t.Errorf("rule applied out of scope: %+v", q.Lines)
+	}
+}
+
+func TestPercentOfRounds(t *testing.T) {
+	if got := percentOf(999, 10); got != 100 {
+		t.Errorf("percentOf(999, 10) = %d", got)
+	}
+	if got := percentOf(999, -10); got != -100 {
+		t.Errorf("percentOf(999, -10) = %d", got)
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	valid := []Rule{
+		{Name: "Summer", Kind: KindSeason, StartDate: date("2025-07-01"), EndDate: date("2025-08-31"), Percent: intp(20)},
+		{Name: "Weekend", Kind: KindDayOfWeek, DaysOfWeek: []int{0, 6}, DailyRateCents: intp(12000)},
+		{Name: "Weekly", Kind: KindLengthTier, MinDays: intp(7), Percent: intp(-10)},
+		{Name: "Minimum", Kind: KindMinimumLength, MinDays: intp(2)},
+		{Name: "NYE", Kind: KindBlackout, StartDate: date("2025-12-31"), EndDate: date("2025-12-31"), AmountCents: intp(5000)},
+	}
+	for _, r := range valid {
+		if err := r.Validate(); err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+		}
+	}
+
+	invalid := []Rule{
+		{Kind: KindMinimumLength, MinDays: intp(2)},
+		{Name: "Both", Kind: KindSeason, StartDate: date("2025-07-01"), EndDate: date("2025-08-31"), Percent: intp(20), DailyRateCents: intp(1)},
+		{Name: "Backwards", Kind: KindSeason, StartDate: date("2025-08-31"), EndDate: date("2025-07-01"), Percent: intp(20)},
+		{Name: "Free", Kind: KindDayOfWeek, DaysOfWeek: []int{6}, Percent: intp(-100)},
+		{Name: "Day 7", Kind: KindDayOfWeek, DaysOfWeek: []int{7}, Percent: intp(10)},
+		{Name: "Tier", Kind: KindLengthTier, MinDays: intp(1), Percent: intp(-10)},
+		{Name: "NYE", Kind: KindBlackout, StartDate: date("2025-12-31"), EndDate: date("2025-12-31")},
+		{Name: "Channel", Kind: KindMinimumLength, MinDays: intp(2), Channel: strp("phone")},
+		{Name: "Kind", Kind: "coupon"},
+	}
+	for _, r := range invalid {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s (%s) accepted", r.Name, r.Kind)
+		}
+	}
+}
+
+func TestMileageOverage(t *testing.T) {
+	m := Allowance(12000, intp(200), 25, 3)
+
+	if _, ok, err := m.Overage(12600); ok || err != nil {
+		t.Errorf("within allowance: ok=%v err=%v", ok, err)
+	}
+	line, ok, err := m.Overage(12750)
+	if !ok || err != nil || line.Kind != LineMileage || line.Quantity != 150 || line.AmountCents != 150*25 {
+		t.Errorf("overage = %+v, %v, %v", line, ok, err)
+	}
+	if _, _, err := m.Overage(11999); !errors.Is(err, ErrOdometerBackwards) {
+		t.Errorf("lower reading: %v", err)
+	}
+
+	unlimited := Allowance(12000, nil, 25, 3)
+	if _, ok, err := unlimited.Overage(20000); ok || err != nil {
+		t.Errorf("unlimited: ok=%v err=%v", ok, err)
+	}
+	if _, _, err := unlimited.Overage(100); !errors.Is(err, ErrOdometerBackwards) {
+		t.Errorf("unlimited still checks the reading: %v", err)
+	}
+}
+
+func TestCaptureSettlementOverHold(t *testing.T) {
+	// A 3-day rental authorised at its booked total, returned 150 km over
+	rental := Line{Kind: LineRental, Quantity: 3, UnitAmountCents: 10000, AmountCents: 30000}
+	overage, _, _ := Allowance(12000, intp(200), 25, 3).Overage(12750)
+	settled := Total([]Line{rental, overage})
+
+	if got, owed := Capture(settled, rental.AmountCents); got != 30000 || owed != overage.AmountCents {
+		t.Errorf("settlement %d over a %d hold: capture %d, outstanding %d", settled, rental.AmountCents, got, owed)
+	}
+	if got, owed := Capture(settled, settled); got != settled || owed != 0 {
+		t.Errorf("settlement equal to the hold: capture %d, outstanding %d", got, owed)
+	}
+	if got, owed := Capture(rental.AmountCents, settled); got != rental.AmountCents || owed != 0 {
+		t.Errorf("settlement under the hold: capture %d, outstanding %d", got, owed)
+	}
+	// An authorisation that expired holds nothing; all of it is owed
+	if got, owed := Capture(settled, 0); got != 0 || owed != settled {
+		t.Errorf("nothing held: capture %d, outstanding %d", got, owed)
+	}
+}
+
+func TestRefuel(t *testing.T) {
+	s := Settings{RefuelCentsPerEighth: 1500, RechargeCentsPerPercent: 40}
+
+	line, ok := s.Refuel(FuelDiesel, 8, 5)
+	if !ok || line.Kind != LineRefuel || line.Quantity != 3 || line.AmountCents != 4500 {
+		t.Errorf("diesel = %+v, %v", line, ok)
+	}
+	line, ok = s.Refuel(FuelElectric, 90, 30)
+	if !ok || line.Quantity != 60 || line.AmountCents != 2400 {
+		t.Errorf("electric = %+v, %v", line, ok)
+	}
+	if _, ok := s.Refuel(FuelPetrol, 4, 6); ok {
+		t.Error("charged for returning fuller")
+	}
+	if _, ok := (Settings{}).Refuel(FuelPetrol, 8, 0); ok {
+		t.Error("charged without a price")
+	}
+
+	if ValidateLevel(FuelPetrol, 9) == nil || ValidateLevel(FuelElectric, 9) != nil || ValidateLevel(FuelElectric, 101) == nil {
+		t.Error("levels not validated against the fuel type")
+	}
+}
+
+func TestHoldCoversSettlementFromDeposit(t *testing.T) {
+	overage, _, _ := Allowance(12000, intp(200), 25, 3).Overage(12750)
+	held := Hold(30000, 5000)
+	if held != 35000 {
+		t.Fatalf("Hold = %d", held)
+	}
+	if _, owed := Capture(30000+overage.AmountCents, held); owed != 0 {
+		t.Errorf("overage within the deposit left %d outstanding", owed)
+	}
+	if _, owed := Capture(30000+overage.AmountCents+5000, held); owed != overage.AmountCents {
+		t.Errorf("charges beyond the deposit left %d outstanding", owed)
+	}
+	if Hold(30000, -1) != 30000 {
+		t.Errorf("negative deposit widened the hold")
+	}
+}
@@ -0,0 +1,82 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Fuel types. Electric cars are read as battery percentage, the rest in
+// eighths of a tank.
+const (
+	FuelPetrol   = "petrol"
+	FuelDiesel   = "diesel"
+	FuelHybrid   = "hybrid"
+	FuelElectric = "electric"
+)
+
+// LineRefuel charges for returning a car with less fuel or charge than at pickup.
+const LineRefuel = "refuel"
+
+// Settings are the tenant's prices that are not rules.
+type Settings struct {
+	RefuelCentsPerEighth    int `json:"refuel_cents_per_eighth"`
+	RechargeCentsPerPercent int `json:"recharge_cents_per_percent"`
+}
+
+func (s Settings) Validate() error {
+	if s.RefuelCentsPerEighth < 0 || s.RechargeCentsPerPercent < 0 {
+		return errors.New("prices cannot be negative")
+	}
+	return nil
+}
+
+// ValidFuelType reports whether t is one of the Fuel* types.
+func ValidFuelType(t string) bool {
+	switch t {
+	case FuelPetrol, FuelDiesel, FuelHybrid, FuelElectric:
+		return true
+	}
+	return false
+}
+
+// FullLevel is the reading of a full tank (8 eighths) or battery (100%).
+func FullLevel(fuelType string) int {
+	if fuelType == FuelElectric {
+		return 100
+	}
+	return 8
+}
+
+// ValidateLevel checks a fuel or charge reading for the car's fuel type.
+func ValidateLevel(fuelType string, level int) error {
+	if level < 0 || level > FullLevel(fuelType) {
+		if fuelType == FuelElectric {
+			return errors.New("charge level must be between 0 and 100 percent")
+		}
+		return errors.New("fuel level must be between 0 and 8 eighths")
+	}
+	return nil
+}
+
+// Refuel returns the line for topping the car back up to its pickup level.
+// ok is false when it came back at least as full, or the price is not set.
+func (s Settings) Refuel(fuelType string, startLevel, finalLevel int) (line Line, ok bool) {
+	missing := startLevel - finalLevel
+	if missing <= 0 {
+		return Line{}, false
+	}
+
+	line = Line{Kind: LineRefuel, Quantity: missing}
+	if fuelType == FuelElectric {
+		line.Description = fmt.Sprintf("Recharge (%d%% to %d%%, per %%)", finalLevel, startLevel)
+		line.UnitAmountCents = s.RechargeCentsPerPercent
+	} else {
+		line.Description = fmt.Sprintf("Refuel (%d/8 to %d/8, per eighth)", finalLevel, startLevel)
+		line.UnitAmountCents = s.RefuelCentsPerEighth
+	}
+	if line.UnitAmountCents == 0 {
+		return Line{}, false
+	}
+	line.AmountCents = missing * line.UnitAmountCents
+	return line, true
+}
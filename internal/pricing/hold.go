@@ -0,0 +1,24 @@
+package pricing
+
+// Capture splits a rental's settled total into what its payment can still
+// capture and the balance left outstanding. Mileage, refuelling and damage
+// are added after the card was authorised, and Stripe refuses to capture more
+// than it holds; the return completes either way.
+func Capture(totalCents, capturableCents int) (captureCents, outstandingCents int) {
+	if capturableCents < 0 {
+		capturableCents = 0
+	}
+	if totalCents <= capturableCents {
+		return totalCents, 0
+	}
+	return capturableCents, totalCents - capturableCents
+}
+
+// Hold is what to authorise on the card for a booking: its total and the
+// deposit on top, the margin that settlement charges are captured from.
+func Hold(totalCents, depositCents int) int {
+	if depositCents < 0 {
+		depositCents = 0
+	}
+	return totalCents + depositCents
+}
@@ -0,0 +1,50 @@
+package pricing
+
+import (
+	"errors"
+	"fmt"
+)
+
+// LineMileage charges the distance driven beyond a rental's allowance.
+const LineMileage = "mileage"
+
+var ErrOdometerBackwards = errors.New("odometer reading is lower than the reading at pickup")
+
+// Mileage is the distance allowance a rental was handed over with.
+type Mileage struct {
+	StartOdometer     int
+	IncludedKm        *int // nil: unlimited
+	OverageCentsPerKm int
+}
+
+// Allowance builds the allowance for a rental of days from a car's per-day terms.
+func Allowance(startOdometer int, includedKmPerDay *int, overageCentsPerKm, days int) Mileage {
+	m := Mileage{StartOdometer: startOdometer, OverageCentsPerKm: overageCentsPerKm}
+	if includedKmPerDay != nil {
+		km := *includedKmPerDay * days
+		m.IncludedKm = &km
+	}
+	return m
+}
+
+// Overage checks the final reading and returns the line for any distance
+// beyond the allowance. ok is false when nothing is owed.
+func (m Mileage) Overage(finalOdometer int) (line Line, ok bool, err error) {
+	if finalOdometer < m.StartOdometer {
+		return Line{}, false, fmt.Errorf("%w (%d km)", ErrOdometerBackwards, m.StartOdometer)
+	}
+	if m.IncludedKm == nil || m.OverageCentsPerKm == 0 {
+		return Line{}, false, nil
+	}
+	excess := finalOdometer - m.StartOdometer - *m.IncludedKm
+	if excess <= 0 {
+		return Line{}, false, nil
+	}
+	return Line{
+		Kind:            LineMileage,
+		Description:     fmt.Sprintf("Distance over %d km allowance (km)", *m.IncludedKm),
+		Quantity:        excess,
+		UnitAmountCents: m.OverageCentsPerKm,
+		AmountCents:     excess * m.OverageCentsPerKm,
+	}, true, nil
+}
@@ -0,0 +1,123 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrCarNotFound = errors.New("car not found")
+
+const ruleColumns = `id, name, kind, car_id, channel, start_date, end_date, COALESCE(days_of_week, '{}'),
+	min_days, percent, daily_rate_cents, amount_cents, priority, active`
+
+func scanRule(row pgx.Row, r *Rule) error {
+	return row.Scan(&r.ID, &r.Name, &r.Kind, &r.CarID, &r.Channel, &r.StartDate, &r.EndDate, &r.DaysOfWeek,
+		&r.MinDays, &r.Percent, &r.DailyRateCents, &r.AmountCents, &r.Priority, &r.Active)
+}
+
+// LoadRules returns the tenant's rules, active or not, in priority order.
+func LoadRules(ctx context.Context, tx pgx.Tx) ([]Rule, error) {
+	rows, err := tx.Query(ctx, `SELECT `+ruleColumns+` FROM pricing_rules ORDER BY priority DESC, created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []Rule{}
+	for rows.Next() {
+		var r Rule
+		if err := scanRule(rows, &r); err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
+// QuoteCar prices a rental of one car with the tenant's current rules.
+func QuoteCar(ctx context.Context, tx pgx.Tx, carID string, start, end time.Time, channel string) (Quote, error) {
+	var rate int
+	err := tx.QueryRow(ctx, "SELECT daily_rate_cents FROM cars WHERE id = $1", carID).Scan(&rate)
+	if err == pgx.ErrNoRows {
+		return Quote{}, ErrCarNotFound
+	}
+	if err != nil {
+		return Quote{}, err
+	}
+	rules, err := LoadRules(ctx, tx)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Calculate(rules, Request{CarID: carID, DailyRateCents: rate, Start: start, End: end, Channel: channel}), nil
+}
+
+// SaveCharges appends lines to a booking's charges. The lines quoted at
+// booking time are frozen this way: later rule changes do not reprice it.
+func SaveCharges(ctx context.Context, tx pgx.Tx, bookingID string, lines []Line) error {
+	for _, l := range lines {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO booking_charges (booking_id, position, kind, description, quantity, unit_amount_cents, amount_cents, rule_id, included)
+			VALUES ($1, (SELECT COALESCE(MAX(position) + 1, 0) FROM booking_charges WHERE booking_id = $1), $2, $3, $4, $5, $6, $7, $8)
+		`, bookingID, l.Kind, l.Description, l.Quantity, l.UnitAmountCents, l.AmountCents, l.RuleID, l.Included)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Charges returns a booking's charges in the order they were added.
+func Charges(ctx context.Context, tx pgx.Tx, bookingID string) ([]Line, error) {
+	rows, err := tx.Query(ctx, `
+		SELECT kind, description, quantity, unit_amount_cents, amount_cents, rule_id, included
+		FROM booking_charges WHERE booking_id = $1 ORDER BY position
+	`, bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []Line
+	for rows.Next() {
+		var l Line
+		if err := rows.Scan(&l.Kind, &l.Description, &l.Quantity, &l.UnitAmountCents, &l.AmountCents, &l.RuleID, &l.Included); err != nil {
+			return nil, err
+		}
+		lines = append(lines, l)
+	}
+	return lines, rows.Err()
+}
+
+// DeleteCharges removes a booking's charges of a kind, to be worked out again.
+func DeleteCharges(ctx context.Context, tx pgx.Tx, bookingID, kind string) error {
+	_, err := tx.Exec(ctx, "DELETE FROM booking_charges WHERE booking_id = $1 AND kind = $2", bookingID, kind)
+	return err
+}
+
+// LoadSettings returns the tenant's settings; all prices are zero until saved.
+func LoadSettings(ctx context.Context, tx pgx.Tx) (Settings, error) {
+	var s Settings
+	err := tx.QueryRow(ctx, `
+		SELECT refuel_cents_per_eighth, recharge_cents_per_percent FROM pricing_settings
+	`).Scan(&s.RefuelCentsPerEighth, &s.RechargeCentsPerPercent)
+	if err == pgx.ErrNoRows {
+		return Settings{}, nil
+	}
+	return s, err
+}
+
+// SaveSettings replaces the tenant's settings.
+func SaveSettings(ctx context.Context, tx pgx.Tx, s Settings) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO pricing_settings (id, refuel_cents_per_eighth, recharge_cents_per_percent)
+		VALUES (true, $1, $2)
+		ON CONFLICT (id) DO UPDATE
+		SET refuel_cents_per_eighth = EXCLUDED.refuel_cents_per_eighth,
+		    recharge_cents_per_percent = EXCLUDED.recharge_cents_per_percent,
+		    updated_at = NOW()
+	`, s.RefuelCentsPerEighth, s.RechargeCentsPerPercent)
+	return err
+}
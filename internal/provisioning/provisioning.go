@@ -0,0 +1,172 @@
+package provisioning
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"rental-saas/internal/migrate"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrInvalidRequest = errors.New("invalid provisioning request")
+	ErrTenantExists   = errors.New("tenant already exists")
+)
+
+// Subdomains double as schema names, so keep them to a safe identifier subset.
+var subdomainPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{1,30}[a-z0-9]$`)
+
+var reservedSubdomains = map[string]bool{
+	"public": true,
+	"admin":  true,
+	"api":    true,
+	"app":    true,
+	"www":    true,
+}
+
+type TenantRequest struct {
+	Name          string
+	Subdomain     string
+	AdminEmail    string
+	AdminPassword string
+}
+
+type TenantResult struct {
+	TenantID    string `json:"tenant_id"`
+	SchemaName  string `json:"schema_name"`
+	Subdomain   string `json:"subdomain"`
+	AdminUserID string `json:"admin_user_id"`
+}
+
+// SchemaName maps a subdomain to the Postgres schema that holds the tenant's data.
+func SchemaName(subdomain string) string {
+	return "tenant_" + strings.ReplaceAll(subdomain, "-", "_")
+}
+
+func (req *TenantRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Subdomain = strings.ToLower(strings.TrimSpace(req.Subdomain))
+	req.AdminEmail = strings.ToLower(strings.TrimSpace(req.AdminEmail))
+
+	if req.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRequest)
+	}
+	if !subdomainPattern.MatchString(req.Subdomain) || reservedSubdomains[req.Subdomain] {
+		return fmt.Errorf("%w: subdomain %q is not allowed", ErrInvalidRequest, req.Subdomain)
+	}
+	if !strings.Contains(req.AdminEmail, "@") {
+		return fmt.Errorf("%w: admin email is invalid", ErrInvalidRequest)
+	}
+	if len(req.AdminPassword) < 8 {
+		return fmt.Errorf("%w: admin password must be at least 8 characters", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// ProvisionTenant registers the tenant, creates its schema, applies every tenant
+// migration inside it and seeds the first owner user. Everything runs in a single
+// transaction (Postgres DDL is transactional), so a failure at any step leaves
+// no trace behind.
+func ProvisionTenant(ctx context.Context, db *pgxpool.Pool, req TenantRequest) (*TenantResult, error) {
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(req.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash admin password: %w", err)
+	}
+
+	result := &TenantResult{
+		SchemaName: SchemaName(req.Subdomain),
+		Subdomain:  req.Subdomain,
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO public.tenants (name, subdomain, schema_name)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, req.Name, req.Subdomain, result.SchemaName).Scan(&result.TenantID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("%w: %s", ErrTenantExists, req.Subdomain)
+		}
+		return nil, fmt.Errorf("failed to register tenant: %w", err)
+	}
+
+	ident := pgx.Identifier{result.SchemaName}.Sanitize()
+	if _, err := tx.Exec(ctx, "CREATE SCHEMA "+ident); err != nil {
+		return nil, fmt.Errorf("failed to create schema: %w", err)
+	}
+
+	migrator, err := migrate.New(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.ApplyTenant(ctx, tx, result.SchemaName); err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(ctx, `
+		INSERT INTO users (email, password_hash, role)
+		VALUES ($1, $2, 'owner')
+		RETURNING id
+	`, req.AdminEmail, string(passwordHash)).Scan(&result.AdminUserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin user: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// CreatePlatformAdmin seeds a row in public.users_global so the operator can
+// log in to the platform API and provision tenants over HTTP.
+func CreatePlatformAdmin(ctx context.Context, db *pgxpool.Pool, email, password, role string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return "", fmt.Errorf("%w: email is invalid", ErrInvalidRequest)
+	}
+	if len(password) < 8 {
+		return "", fmt.Errorf("%w: password must be at least 8 characters", ErrInvalidRequest)
+	}
+	if role != "admin" && role != "support" {
+		return "", fmt.Errorf("%w: role must be admin or support", ErrInvalidRequest)
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	var id string
+	err = db.QueryRow(ctx, `
+		INSERT INTO public.users_global (email, password_hash, role)
+		VALUES ($1, $2, $3)
+		RETURNING id
+	`, email, string(passwordHash), role).Scan(&id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return "", fmt.Errorf("%w: platform user %s already exists", ErrInvalidRequest, email)
+		}
+		return "", err
+	}
+	return id, nil
+}
@@ -0,0 +1,163 @@
+// Package refund gives money back on a captured payment, all of what is left
+// or part of it. A refund is recorded before Stripe is asked for it, under the
+// idempotency key the client sent with the request, so a request retried
+// after a lost response returns the refund it already made instead of making
+// another. Stripe settles a refund later or at once; once it succeeds, the
+// booking's invoice is credited for the amount. Refunds Stripe reports that
+// are not recorded here, made from the Stripe dashboard or by a request that
+// rolled back after reaching Stripe, are recorded and credited the same way.
+package refund
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// Payment statuses a refund moves a payment between.
+const (
+	PaymentCaptured          = "captured"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+)
+
+// ReasonStripe is the reason recorded for a refund made from the Stripe
+// dashboard, which gives none.
+const ReasonStripe = "Refunded in Stripe"
+
+var (
+	ErrNotFound      = errors.New("refund not found")
+	ErrNoPayment     = errors.New("booking has no payment")
+	ErrNotRefundable = errors.New("only a captured payment can be refunded")
+	ErrTooLarge      = errors.New("refund exceeds what is left to refund on the payment")
+)
+
+type Refund struct {
+	ID          string `json:"id"`
+	PaymentID   string `json:"payment_id"`
+	BookingID   string `json:"booking_id"`
+	AmountCents int    `json:"amount_cents"`
+	Currency    string `json:"currency"`
+	Reason      string `json:"reason"`
+	Status      string `json:"status"`
+	// StripeRefundID is nil until Stripe has accepted the refund.
+	StripeRefundID *string `json:"provider_reference"`
+	// CreditNoteID is the credit note issued once the refund succeeded.
+	CreditNoteID *string   `json:"credit_note_id"`
+	CreatedBy    *string   `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+
+	idempotencyKey string
+}
+
+// IdempotencyKey is the key the refund is requested from Stripe with.
+func (r Refund) IdempotencyKey() string {
+	return r.idempotencyKey
+}
+
+// Key is the idempotency key of a refund of a payment requested with the
+// client's key; Stripe keys are shared by all tenants on the account.
+func Key(paymentID, clientKey string) string {
+	return "refund_" + paymentID + "_" + clientKey
+}
+
+// ValidateKey checks the idempotency key a client sends with a refund.
+func ValidateKey(key string) error {
+	if strings.TrimSpace(key) == "" {
+		return errors.New("Idempotency-Key header is required")
+	}
+	if len(key) > 200 {
+		return errors.New("Idempotency-Key must be at most 200 characters")
+	}
+	return nil
+}
+
+// Remote is a refund of a payment as Stripe reports it.
+type Remote struct {
+	StripeRefundID string
+	AmountCents    int
+	// Status is ours, see FromStripe.
+	Status string
+	// Key and Reason are from the metadata of a refund requested here; both
+	// are empty for one made from the Stripe dashboard.
+	Key    string
+	Reason string
+}
+
+// Payment is a booking's payment as far as refunds are concerned.
+type Payment struct {
+	ID             string
+	BookingID      string
+	StripeIntentID string
+	Status         string
+	AmountCents    int
+	RefundedCents  int
+	Currency       string
+}
+
+// Left is how much of the payment can still be refunded.
+func (p Payment) Left() int {
+	if p.Status != PaymentCaptured && p.Status != PaymentPartiallyRefunded {
+		return 0
+	}
+	return p.AmountCents - p.RefundedCents
+}
+
+// Check validates a refund of amountCents, 0 meaning all that is left, and
+// returns the amount to refund.
+func (p Payment) Check(amountCents int) (int, error) {
+	if p.Status != PaymentCaptured && p.Status != PaymentPartiallyRefunded {
+		return 0, ErrNotRefundable
+	}
+	if amountCents == 0 {
+		amountCents = p.Left()
+	}
+	if amountCents <= 0 || amountCents > p.Left() {
+		return 0, fmt.Errorf("%w: %d left", ErrTooLarge, p.Left())
+	}
+	return amountCents, nil
+}
+
+// PaymentStatus is the status of a captured payment with refundedCents of
+// it given back.
+func PaymentStatus(amountCents, refundedCents int) string {
+	switch {
+	case refundedCents <= 0:
+		return PaymentCaptured
+	case refundedCents < amountCents:
+		return PaymentPartiallyRefunded
+	}
+	return PaymentRefunded
+}
+
+// FromStripe maps a Stripe refund status onto ours: requires_action stays
+// pending, canceled is failed.
+func FromStripe(status string) string {
+	switch status {
+	case "succeeded":
+		return StatusSucceeded
+	case "failed", "canceled":
+		return StatusFailed
+	}
+	return StatusPending
+}
+
+// ValidateReason checks the reason staff give for a refund; it is shown on
+// the credit note.
+func ValidateReason(reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return errors.New("reason is required")
+	}
+	if len(reason) > 500 {
+		return errors.New("reason must be at most 500 characters")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package refund
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	p := Payment{Status: PaymentCaptured, AmountCents: 18000}
+	if amount, err := p.Check(0); err != nil || amount != 18000 {
+		t.Errorf("full refund: %d, %v", amount, err)
+	}
+	if amount, err := p.Check(5000); err != nil || amount != 5000 {
+		t.Errorf("partial refund: %d, %v", amount, err)
+	}
+
+	p.Status, p.RefundedCents = PaymentPartiallyRefunded, 5000
+	if amount, err := p.Check(0); err != nil || amount != 13000 {
+		t.Errorf("rest: %d, %v", amount, err)
+	}
+	if _, err := p.Check(13001); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("over-refund: %v", err)
+	}
+
+	p.Status, p.RefundedCents = PaymentRefunded, 18000
+	if _, err := p.Check(0); !errors.Is(err, ErrNotRefundable) {
+		t.Errorf("refunded payment: %v", err)
+	}
+	for _, status := range []string{"pending_auth", "authorized", "voided", "failed"} {
+		p := Payment{Status: status, AmountCents: 18000}
+		if _, err := p.Check(100); !errors.Is(err, ErrNotRefundable) {
+			t.Errorf("%s payment: %v", status, err)
+		}
+	}
+}
+
+func TestPaymentStatus(t *testing.T) {
+	cases := map[int]string{0: PaymentCaptured, 1: PaymentPartiallyRefunded, 17999: PaymentPartiallyRefunded, 18000: PaymentRefunded}
+	for refunded, want := range cases {
+		if got := PaymentStatus(18000, refunded); got != want {
+			t.Errorf("%d refunded: %s, want %s", refunded, got, want)
+		}
+	}
+}
+
+func TestFromStripe(t *testing.T) {
+	cases := map[string]string{
+		"succeeded": StatusSucceeded, "pending": StatusPending, "requires_action": StatusPending,
+		"failed": StatusFailed, "canceled": StatusFailed,
+	}
+	for stripe, want := range cases {
+		if got := FromStripe(stripe); got != want {
+			t.Errorf("%s: %s, want %s", stripe, got, want)
+		}
+	}
+}
+
+func TestValidateReason(t *testing.T) {
+	if ValidateReason(" ") == nil || ValidateReason(strings.Repeat("x", 501)) == nil {
+		t.Error("accepted an empty or long reason")
+	}
+	if err := ValidateReason("Damage estimate revised"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if Key("pay-1", "k1") == Key("pay-2", "k1") || Key("pay-1", "k1") == Key("pay-1", "k2") {
+		t.Error("keys of different payments or requests collide")
+	}
+	if Key("pay-1", "k1") != Key("pay-1", "k1") {
+		t.Error("a repeated request gets a new key")
+	}
+	if ValidateKey(" ") == nil || ValidateKey(strings.Repeat("x", 201)) == nil {
+		t.Error("accepted an empty or long key")
+	}
+	if err := ValidateKey("3f0c9a62-refund"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,227 @@
+package refund
+
+import (
+	"context"
+	"time"
+
+	"rental-saas/internal/invoice"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const columns = `r.id, r.payment_id, p.booking_id, r.amount_cents, p.currency, r.reason, r.status,
+	r.stripe_refund_id, r.credit_note_id, r.created_by, r.created_at, r.updated_at, r.idempotency_key`
+
+const from = `refunds r JOIN payments p ON p.id = r.payment_id`
+
+func scan(row pgx.Row, r *Refund) error {
+	return row.Scan(&r.ID, &r.PaymentID, &r.BookingID, &r.AmountCents, &r.Currency, &r.Reason, &r.Status,
+		&r.StripeRefundID, &r.CreditNoteID, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt, &r.idempotencyKey)
+}
+
+// LockPayment locks and returns a booking's payment.
+func LockPayment(ctx context.Context, tx pgx.Tx, bookingID string) (Payment, error) {
+	var p Payment
+	err := tx.QueryRow(ctx, `
+		SELECT id, booking_id, stripe_intent_id, status, amount_cents, refunded_cents, currency
+		FROM payments WHERE booking_id = $1
+		FOR UPDATE
+	`, bookingID).Scan(&p.ID, &p.BookingID, &p.StripeIntentID, &p.Status, &p.AmountCents, &p.RefundedCents, &p.Currency)
+	if err == pgx.ErrNoRows {
+		return p, ErrNoPayment
+	}
+	return p, err
+}
+
+// Create records a pending refund of amountCents on a payment locked with
+// LockPayment, which Check has validated, under the idempotency key from Key,
+// and counts it as refunded on the payment until it fails.
+func Create(ctx context.Context, tx pgx.Tx, p *Payment, amountCents int, reason, key string, createdBy *string) (Refund, error) {
+	r := Refund{PaymentID: p.ID, BookingID: p.BookingID, AmountCents: amountCents, Currency: p.Currency,
+		Reason: reason, Status: StatusPending, CreatedBy: createdBy, idempotencyKey: key}
+	err := tx.QueryRow(ctx, `
+		INSERT INTO refunds (payment_id, amount_cents, reason, status, idempotency_key, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at, updated_at
+	`, r.PaymentID, r.AmountCents, r.Reason, r.Status, r.idempotencyKey, r.CreatedBy).Scan(&r.ID, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return r, err
+	}
+	return r, setRefunded(ctx, tx, p, p.RefundedCents+amountCents)
+}
+
+// Record stores the reference of the refund Stripe made for r.
+func Record(ctx context.Context, tx pgx.Tx, r *Refund, stripeRefundID string) error {
+	_, err := tx.Exec(ctx, "UPDATE refunds SET stripe_refund_id = $1, updated_at = NOW() WHERE id = $2", stripeRefundID, r.ID)
+	if err != nil {
+		return err
+	}
+	r.StripeRefundID = &stripeRefundID
+	return nil
+}
+
+// SetStatus moves a refund to status and reports whether it changed, so a
+// redelivered Stripe event is only acted on once. A failed refund no longer
+// counts as refunded on its payment.
+func SetStatus(ctx context.Context, tx pgx.Tx, r *Refund, status string) (bool, error) {
+	if r.Status == status {
+		return false, nil
+	}
+	_, err := tx.Exec(ctx, "UPDATE refunds SET status = $1, updated_at = NOW() WHERE id = $2", status, r.ID)
+	if err != nil {
+		return false, err
+	}
+	previous := r.Status
+	r.Status = status
+	if status != StatusFailed && previous != StatusFailed {
+		return true, nil
+	}
+
+	// Failing releases the amount; a refund that Stripe revives takes it again
+	p, err := LockPayment(ctx, tx, r.BookingID)
+	if err != nil {
+		return true, err
+	}
+	refunded := p.RefundedCents - r.AmountCents
+	if previous == StatusFailed {
+		refunded = p.RefundedCents + r.AmountCents
+	}
+	if refunded < 0 {
+		refunded = 0
+	}
+	return true, setRefunded(ctx, tx, &p, refunded)
+}
+
+// Credit issues the credit note for a refund that succeeded against the
+// booking's open invoice, for the refund's amount or what is left to credit
+// if that is less. Bookings without an open invoice get none.
+func Credit(ctx context.Context, tx pgx.Tx, r *Refund, at time.Time) error {
+	if r.CreditNoteID != nil || r.Status != StatusSucceeded {
+		return nil
+	}
+	inv, err := invoice.Open(ctx, tx, r.BookingID)
+	if err == invoice.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	amount := r.AmountCents
+	if left := inv.TotalCents - inv.CreditedCents; left < amount {
+		amount = left
+	}
+	if amount <= 0 {
+		return nil
+	}
+	cn, err := invoice.Credit(ctx, tx, inv.ID, amount, r.Reason, at)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "UPDATE refunds SET credit_note_id = $1 WHERE id = $2", cn.ID, r.ID); err != nil {
+		return err
+	}
+	r.CreditNoteID = &cn.ID
+	return nil
+}
+
+// ByKey returns the refund recorded under an idempotency key from Key.
+func ByKey(ctx context.Context, tx pgx.Tx, key string) (Refund, error) {
+	var r Refund
+	err := scan(tx.QueryRow(ctx, "SELECT "+columns+" FROM "+from+" WHERE r.idempotency_key = $1", key), &r)
+	if err == pgx.ErrNoRows {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// ByStripeID locks and returns the refund Stripe knows by stripeRefundID.
+func ByStripeID(ctx context.Context, tx pgx.Tx, stripeRefundID string) (Refund, error) {
+	var r Refund
+	err := scan(tx.QueryRow(ctx, "SELECT "+columns+" FROM "+from+" WHERE r.stripe_refund_id = $1 FOR UPDATE OF r", stripeRefundID), &r)
+	if err == pgx.ErrNoRows {
+		return r, ErrNotFound
+	}
+	return r, err
+}
+
+// List returns a booking's refunds, oldest first.
+func List(ctx context.Context, tx pgx.Tx, bookingID string) ([]Refund, error) {
+	rows, err := tx.Query(ctx, "SELECT "+columns+" FROM "+from+" WHERE p.booking_id = $1 ORDER BY r.created_at", bookingID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	refunds := []Refund{}
+	for rows.Next() {
+		var r Refund
+		if err := scan(rows, &r); err != nil {
+			return nil, err
+		}
+		refunds = append(refunds, r)
+	}
+	return refunds, rows.Err()
+}
+
+// Sync records the refunds Stripe reports on the payment with the given
+// intent that are not recorded here yet, and returns them. A refund made from
+// the Stripe dashboard is recorded with ReasonStripe; one requested here whose
+// request rolled back after reaching Stripe is recorded under its own key, so
+// a retry of the request finds it. Each counts as refunded on the payment,
+// and one that succeeded is credited. Refunds already recorded are settled
+// through SetStatus; failed ones Stripe reports are left out.
+func Sync(ctx context.Context, tx pgx.Tx, stripeIntentID string, remote []Remote, at time.Time) ([]Refund, error) {
+	var p Payment
+	err := tx.QueryRow(ctx, `
+		SELECT id, booking_id, stripe_intent_id, status, amount_cents, refunded_cents, currency
+		FROM payments WHERE stripe_intent_id = $1
+		FOR UPDATE
+	`, stripeIntentID).Scan(&p.ID, &p.BookingID, &p.StripeIntentID, &p.Status, &p.AmountCents, &p.RefundedCents, &p.Currency)
+	if err == pgx.ErrNoRows {
+		return nil, ErrNoPayment
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.Status != PaymentCaptured && p.Status != PaymentPartiallyRefunded && p.Status != PaymentRefunded {
+		return nil, nil
+	}
+
+	var recorded []Refund
+	for _, re := range remote {
+		if re.Status == StatusFailed || re.AmountCents <= 0 {
+			continue
+		}
+		if _, err := ByStripeID(ctx, tx, re.StripeRefundID); err != ErrNotFound {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		key, reason := re.Key, re.Reason
+		if key == "" {
+			key, reason = "stripe_"+re.StripeRefundID, ReasonStripe
+		}
+		r, err := Create(ctx, tx, &p, re.AmountCents, reason, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := Record(ctx, tx, &r, re.StripeRefundID); err != nil {
+			return nil, err
+		}
+		if _, err := SetStatus(ctx, tx, &r, re.Status); err != nil {
+			return nil, err
+		}
+		if err := Credit(ctx, tx, &r, at); err != nil {
+			return nil, err
+		}
+		recorded = append(recorded, r)
+	}
+	return recorded, nil
+}
+
+func setRefunded(ctx context.Context, tx pgx.Tx, p *Payment, refundedCents int) error {
+	p.RefundedCents, p.Status = refundedCents, PaymentStatus(p.AmountCents, refundedCents)
+	_, err := tx.Exec(ctx, "UPDATE payments SET refunded_cents = $1, status = $2 WHERE id = $3", p.RefundedCents, p.Status, p.ID)
+	return err
+}
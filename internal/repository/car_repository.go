@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"rental-saas/internal/middleware"
 	"rental-saas/internal/models"
+	"rental-saas/internal/webhooks"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,9 +21,9 @@ func NewCarRepository(db *pgxpool.Pool) *CarRepository {
 
 func (r *CarRepository) Create(ctx context.Context, car *models.Car) error {
 	query := `
-		INSERT INTO cars (make, model, license_plate, status, image_url)
-		VALUES ($1, $2, $3, $4, $5)
-		RETURNING id, created_at, updated_at
+		INSERT INTO cars (make, model, license_plate, status, image_url, daily_rate_cents, included_km_per_day, overage_cents_per_km, fuel_type, branch_id, class_id)
+		VALUES ($1, $2, $3, $4, $5, COALESCE(NULLIF($6, 0), 5000), $7, $8, COALESCE(NULLIF($9, ''), 'petrol'), $10, $11)
+		RETURNING id, daily_rate_cents, fuel_type, created_at, updated_at
 	`
 	// Note: The search_path is assumed to be set by the middleware or we need to set it here.
 	// Since we are using a pool, we can't easily set it for the session without checking out a connection.
@@ -48,8 +49,8 @@ func (r *CarRepository) Create(ctx context.Context, car *models.Car) error {
 		return fmt.Errorf("failed to set search_path: %w", err)
 	}
 
-	err = tx.QueryRow(ctx, query, car.Make, car.Model, car.LicensePlate, car.Status, car.ImageURL).
-		Scan(&car.ID, &car.CreatedAt, &car.UpdatedAt)
+	err = tx.QueryRow(ctx, query, car.Make, car.Model, car.LicensePlate, car.Status, car.ImageURL, car.DailyRateCents, car.IncludedKmPerDay, car.OverageCentsPerKm, car.FuelType, car.BranchID, car.ClassID).
+		Scan(&car.ID, &car.DailyRateCents, &car.FuelType, &car.CreatedAt, &car.UpdatedAt)
 	if err != nil {
 		return err
 	}
@@ -58,7 +59,7 @@ func (r *CarRepository) Create(ctx context.Context, car *models.Car) error {
 }
 
 func (r *CarRepository) List(ctx context.Context) ([]models.Car, error) {
-	query := `SELECT id, make, model, license_plate, status, image_url, created_at, updated_at FROM cars`
+	query := `SELECT id, make, model, license_plate, status, daily_rate_cents, COALESCE(image_url, ''), odometer, included_km_per_day, overage_cents_per_km, fuel_type, energy_level, branch_id, class_id, created_at, updated_at FROM cars`
 
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
@@ -84,7 +85,7 @@ func (r *CarRepository) List(ctx context.Context) ([]models.Car, error) {
 	var cars []models.Car
 	for rows.Next() {
 		var c models.Car
-		if err := rows.Scan(&c.ID, &c.Make, &c.Model, &c.LicensePlate, &c.Status, &c.ImageURL, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Make, &c.Model, &c.LicensePlate, &c.Status, &c.DailyRateCents, &c.ImageURL, &c.Odometer, &c.IncludedKmPerDay, &c.OverageCentsPerKm, &c.FuelType, &c.EnergyLevel, &c.BranchID, &c.ClassID, &c.CreatedAt, &c.UpdatedAt); err != nil {
 			return nil, err
 		}
 		cars = append(cars, c)
@@ -94,7 +95,7 @@ func (r *CarRepository) List(ctx context.Context) ([]models.Car, error) {
 }
 
 func (r *CarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Car, error) {
-	query := `SELECT id, make, model, license_plate, status, image_url, created_at, updated_at FROM cars WHERE id = $1`
+	query := `SELECT id, make, model, license_plate, status, daily_rate_cents, COALESCE(image_url, ''), odometer, included_km_per_day, overage_cents_per_km, fuel_type, energy_level, branch_id, class_id, created_at, updated_at FROM cars WHERE id = $1`
 
 	tx, err := r.DB.Begin(ctx)
 	if err != nil {
@@ -112,7 +113,7 @@ func (r *CarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Car,
 	}
 
 	var car models.Car
-	err = tx.QueryRow(ctx, query, id).Scan(&car.ID, &car.Make, &car.Model, &car.LicensePlate, &car.Status, &car.ImageURL, &car.CreatedAt, &car.UpdatedAt)
+	err = tx.QueryRow(ctx, query, id).Scan(&car.ID, &car.Make, &car.Model, &car.LicensePlate, &car.Status, &car.DailyRateCents, &car.ImageURL, &car.Odometer, &car.IncludedKmPerDay, &car.OverageCentsPerKm, &car.FuelType, &car.EnergyLevel, &car.BranchID, &car.ClassID, &car.CreatedAt, &car.UpdatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -123,8 +124,11 @@ func (r *CarRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Car,
 func (r *CarRepository) Update(ctx context.Context, car *models.Car) error {
 	query := `
 		UPDATE cars 
-		SET make = $1, model = $2, license_plate = $3, status = $4, image_url = $5, updated_at = NOW()
-		WHERE id = $6
+		SET make = $1, model = $2, license_plate = $3, status = $4, image_url = $5, daily_rate_cents = $6,
+		    included_km_per_day = $7, overage_cents_per_km = $8, fuel_type = $9, branch_id = $10, class_id = $11, updated_at = NOW(),
+		    -- A reading in eighths means nothing as a percentage, and the reverse
+		    energy_level = CASE WHEN fuel_type = $9 THEN energy_level END
+		WHERE id = $12
 		RETURNING updated_at
 	`
 
@@ -143,10 +147,19 @@ func (r *CarRepository) Update(ctx context.Context, car *models.Car) error {
 		return fmt.Errorf("failed to set search_path: %w", err)
 	}
 
-	err = tx.QueryRow(ctx, query, car.Make, car.Model, car.LicensePlate, car.Status, car.ImageURL, car.ID).Scan(&car.UpdatedAt)
+	var previous string
+	if err := tx.QueryRow(ctx, "SELECT status FROM cars WHERE id = $1 FOR UPDATE", car.ID).Scan(&previous); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(ctx, query, car.Make, car.Model, car.LicensePlate, car.Status, car.ImageURL, car.DailyRateCents, car.IncludedKmPerDay, car.OverageCentsPerKm, car.FuelType, car.BranchID, car.ClassID, car.ID).Scan(&car.UpdatedAt)
 	if err != nil {
 		return err
 	}
 
+	if err := webhooks.EmitCarStatusChanged(ctx, tx, car.ID.String(), previous, string(car.Status), "manual"); err != nil {
+		return err
+	}
+
 	return tx.Commit(ctx)
 }
@@ -11,6 +11,18 @@ import (
 	"github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+// PrivateDir is the directory under a tenant's prefix that the bucket policy
+// keeps out of public reads: objects named <tenant>/documents/... are only
+// served through GetFile.
+const PrivateDir = "documents"
+
+// policy lets anyone read the bucket's objects, car photos, except those
+// under PrivateDir.
+const policy = `{"Version": "2012-10-17","Statement": [
+	{"Action": ["s3:GetObject"],"Effect": "Allow","Principal": {"AWS": ["*"]},"Resource": ["arn:aws:s3:::%[1]s/*"]},
+	{"Action": ["s3:GetObject"],"Effect": "Deny","Principal": {"AWS": ["*"]},"Resource": ["arn:aws:s3:::%[1]s/*/` + PrivateDir + `/*"]}
+]}`
+
 type MinioClient struct {
 	Client *minio.Client
 	Bucket string
@@ -37,12 +49,12 @@ func NewMinioClient(endpoint, accessKey, secretKey, bucket string) (*MinioClient
 		if err != nil {
 			return nil, err
 		}
-		// Set public policy for read access (simplified for dev)
-		policy := fmt.Sprintf(`{"Version": "2012-10-17","Statement": [{"Action": ["s3:GetObject"],"Effect": "Allow","Principal": {"AWS": ["*"]},"Resource": ["arn:aws:s3:::%s/*"]}]}`, bucket)
-		err = minioClient.SetBucketPolicy(ctx, bucket, policy)
-		if err != nil {
-			log.Printf("Failed to set bucket policy: %v", err)
-		}
+	}
+	// Set public policy for read access (simplified for dev). It is set on
+	// every start so buckets made before PrivateDir keep documents private too.
+	err = minioClient.SetBucketPolicy(ctx, bucket, fmt.Sprintf(policy, bucket))
+	if err != nil {
+		log.Printf("Failed to set bucket policy: %v", err)
 	}
 
 	return &MinioClient{
@@ -64,3 +76,22 @@ func (m *MinioClient) UploadFile(ctx context.Context, objectName string, reader
 	
 	return fmt.Sprintf("%s/%s/%s", baseURL, m.Bucket, info.Key), nil
 }
+
+// GetFile opens an object for reading, for files served through the API
+// rather than by their public URL.
+func (m *MinioClient) GetFile(ctx context.Context, objectName string) (io.ReadCloser, int64, error) {
+	obj, err := m.Client.GetObject(ctx, m.Bucket, objectName, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, 0, err
+	}
+	return obj, info.Size, nil
+}
+
+func (m *MinioClient) DeleteFile(ctx context.Context, objectName string) error {
+	return m.Client.RemoveObject(ctx, m.Bucket, objectName, minio.RemoveObjectOptions{})
+}
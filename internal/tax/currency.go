@@ -0,0 +1,36 @@
+package tax
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minorUnits lists the currencies Stripe supports whose minor unit is not a
+// hundredth; amounts in them have that many decimals.
+var minorUnits = map[string]int{
+	"bif": 0, "clp": 0, "djf": 0, "gnf": 0, "jpy": 0, "kmf": 0, "krw": 0, "mga": 0,
+	"pyg": 0, "rwf": 0, "ugx": 0, "vnd": 0, "vuv": 0, "xaf": 0, "xof": 0, "xpf": 0,
+	"bhd": 3, "jod": 3, "kwd": 3, "omr": 3, "tnd": 3,
+}
+
+// Format shows an amount in the currency's minor unit as, e.g., "180.00 EUR".
+func Format(amount int, currency string) string {
+	code := strings.ToUpper(currency)
+	digits, ok := minorUnits[currency]
+	if !ok {
+		digits = 2
+	}
+	if digits == 0 {
+		return fmt.Sprintf("%d %s", amount, code)
+	}
+
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	unit := 1
+	for i := 0; i < digits; i++ {
+		unit *= 10
+	}
+	return fmt.Sprintf("%s%d.%0*d %s", sign, amount/unit, digits, amount%unit, code)
+}
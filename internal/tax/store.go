@@ -0,0 +1,112 @@
+package tax
+
+import (
+	"context"
+	"encoding/json"
+
+	"rental-saas/internal/pricing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Load returns the tenant's current configuration, Default until saved.
+func Load(ctx context.Context, tx pgx.Tx) (Config, error) {
+	c := Default()
+	err := tx.QueryRow(ctx, "SELECT currency, rounding, round_per FROM tax_settings").Scan(&c.Currency, &c.Rounding, &c.RoundPer)
+	if err != nil && err != pgx.ErrNoRows {
+		return c, err
+	}
+
+	rows, err := tx.Query(ctx, "SELECT id, name, basis_points, inclusive, item_types FROM tax_rates ORDER BY position")
+	if err != nil {
+		return c, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r Rate
+		if err := rows.Scan(&r.ID, &r.Name, &r.BasisPoints, &r.Inclusive, &r.ItemTypes); err != nil {
+			return c, err
+		}
+		c.Rates = append(c.Rates, r)
+	}
+	return c, rows.Err()
+}
+
+// Save replaces the tenant's configuration. Bookings already made keep the
+// one they were priced with.
+func Save(ctx context.Context, tx pgx.Tx, c Config) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO tax_settings (id, currency, rounding, round_per)
+		VALUES (true, $1, $2, $3)
+		ON CONFLICT (id) DO UPDATE
+		SET currency = EXCLUDED.currency, rounding = EXCLUDED.rounding, round_per = EXCLUDED.round_per,
+		    updated_at = NOW()
+	`, c.Currency, c.Rounding, c.RoundPer)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM tax_rates"); err != nil {
+		return err
+	}
+	for i, r := range c.Rates {
+		itemTypes := r.ItemTypes
+		if itemTypes == nil {
+			itemTypes = []string{}
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO tax_rates (position, name, basis_points, inclusive, item_types) VALUES ($1, $2, $3, $4, $5)
+		`, i, r.Name, r.BasisPoints, r.Inclusive, itemTypes)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Freeze keeps the configuration a new booking was priced with on it.
+func Freeze(ctx context.Context, tx pgx.Tx, bookingID string, c Config) error {
+	frozen, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, "UPDATE bookings SET tax_config = $1 WHERE id = $2", frozen, bookingID)
+	return err
+}
+
+// ForBooking returns the configuration the booking was priced with. Bookings
+// made before taxes were priced, and their payments taken, in US dollars
+// untaxed: Default, whatever the tenant has switched to since.
+func ForBooking(ctx context.Context, tx pgx.Tx, bookingID string) (Config, error) {
+	var frozen []byte
+	if err := tx.QueryRow(ctx, "SELECT tax_config FROM bookings WHERE id = $1", bookingID).Scan(&frozen); err != nil {
+		return Config{}, err
+	}
+	if frozen != nil {
+		var c Config
+		err := json.Unmarshal(frozen, &c)
+		return c, err
+	}
+	return Default(), nil
+}
+
+// Settle works the booking's tax lines out again over all its charges, once
+// settlement has added its own, and returns the configuration and the
+// charges with the new tax lines.
+func Settle(ctx context.Context, tx pgx.Tx, bookingID string) (Config, []pricing.Line, error) {
+	c, err := ForBooking(ctx, tx, bookingID)
+	if err != nil {
+		return c, nil, err
+	}
+	if err := pricing.DeleteCharges(ctx, tx, bookingID, pricing.LineTax); err != nil {
+		return c, nil, err
+	}
+	charges, err := pricing.Charges(ctx, tx, bookingID)
+	if err != nil {
+		return c, nil, err
+	}
+	lines := c.Lines(charges)
+	if err := pricing.SaveCharges(ctx, tx, bookingID, lines); err != nil {
+		return c, nil, err
+	}
+	return c, append(charges, lines...), nil
+}
@@ -0,0 +1,231 @@
+// Package tax holds a tenant's currency and tax rates and computes the tax
+// lines of a booking's charges. Each rate applies to item types (rental,
+// extras, damage) and is either exclusive, added on top of the price, or
+// inclusive, already contained in it. A booking keeps the configuration it
+// was priced with, so settlement taxes the charges added at return, such as
+// damage, at the same rates as the rental.
+package tax
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"rental-saas/internal/pricing"
+)
+
+// Item types a rate applies to. Every charge is one of them: extras and
+// damage are their own, anything else (days, fees, mileage, refuelling) is
+// rental.
+const (
+	ItemRental = "rental"
+	ItemExtra  = "extra"
+	ItemDamage = "damage"
+)
+
+// Rounding modes for tax amounts, in the currency's minor unit.
+const (
+	RoundHalfUp   = "half_up" // halves away from zero
+	RoundHalfEven = "half_even"
+	RoundDown     = "down" // towards zero
+)
+
+// What is rounded: each charge's tax, or each rate's total.
+const (
+	RoundPerLine  = "line"
+	RoundPerTotal = "total"
+)
+
+var currencyCode = regexp.MustCompile(`^[a-z]{3}$`)
+
+// ItemType is the item type of a charge of the given line kind.
+func ItemType(kind string) string {
+	switch kind {
+	case pricing.LineExtra:
+		return ItemExtra
+	case pricing.LineDamage:
+		return ItemDamage
+	}
+	return ItemRental
+}
+
+type Rate struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	// BasisPoints is the rate in hundredths of a percent: 2000 is 20%.
+	BasisPoints int  `json:"basis_points"`
+	Inclusive   bool `json:"inclusive"`
+	// ItemTypes are the item types taxed; empty means all of them.
+	ItemTypes []string `json:"item_types"`
+}
+
+func (r Rate) Validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("tax rate name is required")
+	}
+	if r.BasisPoints <= 0 || r.BasisPoints > 10000 {
+		return errors.New("basis_points must be between 1 and 10000")
+	}
+	for _, t := range r.ItemTypes {
+		if t != ItemRental && t != ItemExtra && t != ItemDamage {
+			return fmt.Errorf("unknown item type %q", t)
+		}
+	}
+	return nil
+}
+
+func (r Rate) appliesTo(itemType string) bool {
+	if len(r.ItemTypes) == 0 {
+		return true
+	}
+	for _, t := range r.ItemTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Label is how the rate is shown on a tax line, e.g. "VAT 20% (included)".
+func (r Rate) Label() string {
+	label := fmt.Sprintf("%s %d", r.Name, r.BasisPoints/100)
+	if frac := r.BasisPoints % 100; frac != 0 {
+		label += strings.TrimRight(fmt.Sprintf(".%02d", frac), "0")
+	}
+	label += "%"
+	if r.Inclusive {
+		label += " (included)"
+	}
+	return label
+}
+
+// Config is a tenant's currency, rounding and tax rates.
+type Config struct {
+	// Currency is the ISO 4217 code, lower case as Stripe takes it. Amounts
+	// are in its minor unit.
+	Currency string `json:"currency"`
+	Rounding string `json:"rounding"`
+	RoundPer string `json:"round_per"`
+	Rates    []Rate `json:"rates"`
+}
+
+// Default is the configuration of a tenant that has not saved one.
+func Default() Config {
+	return Config{Currency: "usd", Rounding: RoundHalfUp, RoundPer: RoundPerLine, Rates: []Rate{}}
+}
+
+func (c Config) Validate() error {
+	if !currencyCode.MatchString(c.Currency) {
+		return errors.New("currency must be a three-letter ISO 4217 code")
+	}
+	if c.Rounding != RoundHalfUp && c.Rounding != RoundHalfEven && c.Rounding != RoundDown {
+		return errors.New("rounding must be half_up, half_even or down")
+	}
+	if c.RoundPer != RoundPerLine && c.RoundPer != RoundPerTotal {
+		return errors.New("round_per must be line or total")
+	}
+	for _, r := range c.Rates {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Lines returns one tax line per rate that taxes any of the charges; tax
+// lines among the charges are left out. The price of a charge taxed by
+// inclusive rates contains them, so every rate is worked out on the price
+// net of those: inclusive lines only show what was already charged, and
+// exclusive ones add to the total.
+func (c Config) Lines(charges []pricing.Line) []pricing.Line {
+	exact := make([]*big.Rat, len(c.Rates))
+	rounded := make([]int, len(c.Rates))
+	for i := range exact {
+		exact[i] = new(big.Rat)
+	}
+
+	for _, l := range charges {
+		if l.Kind == pricing.LineTax {
+			continue
+		}
+		item := ItemType(l.Kind)
+		inclusive := 0
+		for _, r := range c.Rates {
+			if r.Inclusive && r.appliesTo(item) {
+				inclusive += r.BasisPoints
+			}
+		}
+		for i, r := range c.Rates {
+			if !r.appliesTo(item) {
+				continue
+			}
+			amount := big.NewRat(int64(l.AmountCents)*int64(r.BasisPoints), int64(10000+inclusive))
+			if c.RoundPer == RoundPerLine {
+				rounded[i] += round(amount, c.Rounding)
+			} else {
+				exact[i].Add(exact[i], amount)
+			}
+		}
+	}
+
+	var lines []pricing.Line
+	for i, r := range c.Rates {
+		amount := rounded[i]
+		if c.RoundPer != RoundPerLine {
+			amount = round(exact[i], c.Rounding)
+		}
+		if amount == 0 {
+			continue
+		}
+		lines = append(lines, pricing.Line{
+			Kind: pricing.LineTax, Description: r.Label(), Quantity: 1,
+			UnitAmountCents: amount, AmountCents: amount, Included: r.Inclusive,
+		})
+	}
+	return lines
+}
+
+// Apply replaces the quote's tax lines with the ones for its other lines and
+// sets its currency.
+func (c Config) Apply(q *pricing.Quote) {
+	charges := q.Lines[:0:0]
+	for _, l := range q.Lines {
+		if l.Kind != pricing.LineTax {
+			charges = append(charges, l)
+		}
+	}
+	q.Lines = charges
+	q.Currency = c.Currency
+	for _, l := range c.Lines(charges) {
+		q.Add(l)
+	}
+	q.TotalCents = pricing.Total(q.Lines)
+}
+
+// Amount sums the tax lines among charges that add to the total.
+func Amount(charges []pricing.Line) int {
+	total := 0
+	for _, l := range charges {
+		if l.Kind == pricing.LineTax && !l.Included {
+			total += l.AmountCents
+		}
+	}
+	return total
+}
+
+// round rounds x to a whole minor unit.
+func round(x *big.Rat, mode string) int {
+	num, den := x.Num(), x.Denom()
+	q, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 && mode != RoundDown {
+		twice := new(big.Int).Abs(rem)
+		twice.Lsh(twice, 1)
+		cmp := twice.Cmp(den)
+		if cmp > 0 || (cmp == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)) {
+			q.Add(q, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return int(q.Int64())
+}
@@ -0,0 +1,136 @@
+package tax
+
+import (
+	"strings"
+	"testing"
+
+	"rental-saas/internal/pricing"
+)
+
+func charge(kind string, cents int) pricing.Line {
+	return pricing.Line{Kind: kind, Quantity: 1, UnitAmountCents: cents, AmountCents: cents}
+}
+
+func TestLinesExclusive(t *testing.T) {
+	c := Default()
+	c.Rates = []Rate{
+		{Name: "Sales tax", BasisPoints: 825},
+		{Name: "Tourism levy", BasisPoints: 500, ItemTypes: []string{ItemRental}},
+	}
+	lines := c.Lines([]pricing.Line{
+		charge(pricing.LineRental, 30000),
+		charge(pricing.LineDiscount, -3000),
+		charge(pricing.LineExtra, 2000),
+		charge(pricing.LineTax, 999), // an earlier tax line is not taxed
+	})
+	if len(lines) != 2 {
+		t.Fatalf("got %d tax lines, want 2: %+v", len(lines), lines)
+	}
+	// 8.25% of 300, -30 and 20 rounded per line: 24.75 - 2.48 + 1.65
+	if lines[0].AmountCents != 2392 || lines[0].Description != "Sales tax 8.25%" || lines[0].Included {
+		t.Errorf("sales tax: %+v", lines[0])
+	}
+	if lines[1].AmountCents != 1350 {
+		t.Errorf("levy on rental only: %+v", lines[1])
+	}
+}
+
+func TestLinesInclusive(t *testing.T) {
+	c := Default()
+	c.Rates = []Rate{{Name: "VAT", BasisPoints: 2000, Inclusive: true}}
+	lines := c.Lines([]pricing.Line{charge(pricing.LineRental, 12000)})
+	if len(lines) != 1 || lines[0].AmountCents != 2000 || !lines[0].Included || lines[0].Description != "VAT 20% (included)" {
+		t.Fatalf("got %+v", lines)
+	}
+	if total := pricing.Total(append([]pricing.Line{charge(pricing.LineRental, 12000)}, lines...)); total != 12000 {
+		t.Errorf("included tax added to the total: %d", total)
+	}
+
+	// An exclusive rate on an inclusive price is worked out on the net price
+	c.Rates = append(c.Rates, Rate{Name: "Levy", BasisPoints: 1000})
+	lines = c.Lines([]pricing.Line{charge(pricing.LineRental, 12000)})
+	if lines[1].AmountCents != 1000 {
+		t.Errorf("levy: %+v", lines[1])
+	}
+}
+
+func TestRounding(t *testing.T) {
+	// 5% of 10, 10 and 30 cents: 0.5, 0.5 and 1.5 cents
+	charges := []pricing.Line{charge(pricing.LineRental, 10), charge(pricing.LineRental, 10), charge(pricing.LineRental, 30)}
+	cases := []struct {
+		rounding, per string
+		want          int
+	}{
+		{RoundHalfUp, RoundPerLine, 4},
+		{RoundHalfEven, RoundPerLine, 2},
+		{RoundDown, RoundPerLine, 1},
+		{RoundHalfUp, RoundPerTotal, 3}, // 2.5
+		{RoundHalfEven, RoundPerTotal, 2},
+		{RoundDown, RoundPerTotal, 2},
+	}
+	for _, tc := range cases {
+		c := Config{Currency: "usd", Rounding: tc.rounding, RoundPer: tc.per, Rates: []Rate{{Name: "Tax", BasisPoints: 500}}}
+		lines := c.Lines(charges)
+		if len(lines) != 1 || lines[0].AmountCents != tc.want {
+			t.Errorf("%s per %s: got %+v, want %d", tc.rounding, tc.per, lines, tc.want)
+		}
+	}
+
+	// Refunds and discounts round symmetrically
+	c := Config{Currency: "usd", Rounding: RoundHalfUp, RoundPer: RoundPerLine, Rates: []Rate{{Name: "Tax", BasisPoints: 500}}}
+	if lines := c.Lines([]pricing.Line{charge(pricing.LineDiscount, -30)}); lines[0].AmountCents != -2 {
+		t.Errorf("negative half: %+v", lines)
+	}
+}
+
+func TestApply(t *testing.T) {
+	c := Default()
+	c.Currency = "eur"
+	c.Rates = []Rate{{Name: "VAT", BasisPoints: 1000}}
+	q := pricing.Quote{}
+	q.Add(charge(pricing.LineRental, 10000))
+	c.Apply(&q)
+	q.Add(charge(pricing.LineExtra, 1000))
+	c.Apply(&q) // again, after an extra: the old tax line is replaced
+
+	if q.TotalCents != 12100 || q.Currency != "eur" || len(q.Lines) != 3 {
+		t.Errorf("got %+v", q)
+	}
+	if Amount(q.Lines) != 1100 {
+		t.Errorf("tax amount %d", Amount(q.Lines))
+	}
+}
+
+func TestValidate(t *testing.T) {
+	// Keyed by what the error must mention
+	cases := map[string]Config{
+		"currency":         {Currency: "EURO", Rounding: RoundHalfUp, RoundPer: RoundPerLine},
+		"rounding":         {Currency: "eur", Rounding: "up", RoundPer: RoundPerLine},
+		"round_per":        {Currency: "eur", Rounding: RoundHalfUp, RoundPer: "invoice"},
+		"tax rate name":    {Currency: "eur", Rounding: RoundHalfUp, RoundPer: RoundPerLine, Rates: []Rate{{BasisPoints: 2000}}},
+		"basis_points":     {Currency: "eur", Rounding: RoundHalfUp, RoundPer: RoundPerLine, Rates: []Rate{{Name: "VAT"}}},
+		`item type "fuel"`: {Currency: "eur", Rounding: RoundHalfUp, RoundPer: RoundPerLine, Rates: []Rate{{Name: "VAT", BasisPoints: 2000, ItemTypes: []string{"fuel"}}}},
+	}
+	for want, c := range cases {
+		if err := c.Validate(); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%+v: %v, want an error about %s", c, err, want)
+		}
+	}
+	if err := Default().Validate(); err != nil {
+		t.Errorf("default rejected: %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	cases := map[string]string{
+		Format(18000, "eur"): "180.00 EUR",
+		Format(-5, "usd"):    "-0.05 USD",
+		Format(1200, "jpy"):  "1200 JPY",
+		Format(12345, "kwd"): "12.345 KWD",
+	}
+	for got, want := range cases {
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+}
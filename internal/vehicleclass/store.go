@@ -0,0 +1,152 @@
+package vehicleclass
+
+import (
+	"context"
+	"time"
+
+	"rental-saas/internal/availability"
+	"rental-saas/internal/branch"
+	"rental-saas/internal/pricing"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const Columns = `id, name, description, daily_rate_cents, seats, image_url, active`
+
+func Scan(row pgx.Row, c *Class) error {
+	return row.Scan(&c.ID, &c.Name, &c.Description, &c.DailyRateCents, &c.Seats, &c.ImageURL, &c.Active)
+}
+
+// Load returns an active class.
+func Load(ctx context.Context, tx pgx.Tx, id string) (Class, error) {
+	var c Class
+	if _, err := uuid.Parse(id); err != nil {
+		return c, ErrNotFound
+	}
+	err := Scan(tx.QueryRow(ctx, "SELECT "+Columns+" FROM vehicle_classes WHERE id = $1 AND active", id), &c)
+	if err == pgx.ErrNoRows {
+		return c, ErrNotFound
+	}
+	return c, err
+}
+
+// FreeCars returns the cars of the class free for [start, end), and with a
+// pickup branch only those that will be there at start.
+func FreeCars(ctx context.Context, tx pgx.Tx, classID string, start, end time.Time, pickupBranchID *string) ([]string, error) {
+	ids, err := availability.FreeCarIDs(ctx, tx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	if ids, err = availability.ClassCarIDs(ctx, tx, ids, classID); err != nil {
+		return nil, err
+	}
+	if pickupBranchID != nil {
+		return branch.CarsAt(ctx, tx, ids, *pickupBranchID, start)
+	}
+	return ids, nil
+}
+
+// Check returns the class's availability over [start, end) from a pickup
+// branch, or across branches without one.
+func Check(ctx context.Context, tx pgx.Tx, classID string, start, end time.Time, pickupBranchID *string) (Availability, error) {
+	free, err := FreeCars(ctx, tx, classID, start, end, pickupBranchID)
+	if err != nil {
+		return Availability{}, err
+	}
+	held, err := availability.UnassignedClassBookings(ctx, tx, classID, start, end, pickupBranchID, "")
+	if err != nil {
+		return Availability{}, err
+	}
+	return newAvailability(len(free), held), nil
+}
+
+// Reserve locks the class and checks that a unit of it is left for a booking
+// over [start, end) on the route, completing the route as branch.Resolve does:
+// drop-off defaults to pickup, and both must be open. The caller inserts the
+// booking without a car right after.
+func Reserve(ctx context.Context, tx pgx.Tx, classID string, start, end time.Time, r branch.Route) (Class, branch.Route, error) {
+	if err := availability.ValidateRange(start, end); err != nil {
+		return Class{}, r, err
+	}
+	if _, err := uuid.Parse(classID); err != nil {
+		return Class{}, r, ErrNotFound
+	}
+	err := availability.LockClass(ctx, tx, classID)
+	if err == pgx.ErrNoRows {
+		return Class{}, r, ErrNotFound
+	}
+	if err != nil {
+		return Class{}, r, err
+	}
+	c, err := Load(ctx, tx, classID) // inactive classes take no new bookings
+	if err != nil {
+		return c, r, err
+	}
+
+	if r.PickupBranchID == nil && r.DropoffBranchID != nil {
+		return c, r, ErrPickupRequired
+	}
+	if r.DropoffBranchID == nil {
+		r.DropoffBranchID = r.PickupBranchID
+	}
+	if err := branch.CheckHours(ctx, tx, r, start, end); err != nil {
+		return c, r, err
+	}
+
+	a, err := Check(ctx, tx, classID, start, end, r.PickupBranchID)
+	if err != nil {
+		return c, r, err
+	}
+	if a.Available < 1 {
+		return c, r, availability.ErrClassFull
+	}
+	return c, r, nil
+}
+
+// Quote prices a rental of the class at its daily rate with the tenant's
+// current rules; rules for a particular car do not apply.
+func Quote(ctx context.Context, tx pgx.Tx, c Class, start, end time.Time, channel string) (pricing.Quote, error) {
+	rules, err := pricing.LoadRules(ctx, tx)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	return pricing.Calculate(rules, pricing.Request{DailyRateCents: c.DailyRateCents, Start: start, End: end, Channel: channel}), nil
+}
+
+// Candidates returns the cars of the class that could be assigned a booking
+// over [start, end) picked up at pickupBranchID, for BestFit.
+func Candidates(ctx context.Context, tx pgx.Tx, classID string, start, end time.Time, pickupBranchID *string) ([]Candidate, error) {
+	ids, err := FreeCars(ctx, tx, classID, start, end, pickupBranchID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT c.id, c.odometer,
+		       (SELECT MAX(b.end_time) FROM bookings b
+		        WHERE b.car_id = c.id AND b.status = ANY($2) AND b.end_time <= $3),
+		       n.start_time, n.pickup_branch_id
+		FROM cars c
+		LEFT JOIN LATERAL (
+			SELECT b.start_time, b.pickup_branch_id FROM bookings b
+			WHERE b.car_id = c.id AND b.status = ANY($2) AND b.start_time >= $4
+			ORDER BY b.start_time LIMIT 1
+		) n ON true
+		WHERE c.id = ANY($1)
+	`, ids, availability.BlockingStatuses, start, end)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cands []Candidate
+	for rows.Next() {
+		var c Candidate
+		if err := rows.Scan(&c.CarID, &c.Odometer, &c.PrevEnd, &c.NextStart, &c.NextPickupBranchID); err != nil {
+			return nil, err
+		}
+		cands = append(cands, c)
+	}
+	return cands, rows.Err()
+}
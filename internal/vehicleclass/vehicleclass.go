@@ -0,0 +1,121 @@
+// Package vehicleclass groups interchangeable cars into classes ("Compact",
+// "SUV") that customers book instead of a particular car. A class booking
+// holds one unit of the class's capacity until staff assign it a car, by hand
+// or by best fit, some time before pickup.
+package vehicleclass
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound       = errors.New("vehicle class not found")
+	ErrCarNotInClass  = errors.New("car does not belong to the booking's vehicle class")
+	ErrNoCarFits      = errors.New("no car of the class can take the booking")
+	ErrPickupRequired = errors.New("pickup_branch_id is required with dropoff_branch_id")
+)
+
+type Class struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	Description    string `json:"description"`
+	DailyRateCents int    `json:"daily_rate_cents"`
+	Seats          *int   `json:"seats"`
+	ImageURL       string `json:"image_url"`
+	Active         bool   `json:"active"`
+}
+
+func (c Class) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return errors.New("name is required")
+	}
+	if c.DailyRateCents <= 0 {
+		return errors.New("daily_rate_cents must be positive")
+	}
+	if c.Seats != nil && *c.Seats <= 0 {
+		return errors.New("seats must be positive")
+	}
+	return nil
+}
+
+// Availability is a class's capacity over a period: the cars of the class
+// free for all of it, less the class bookings overlapping it that are still
+// waiting for a car.
+type Availability struct {
+	Free      int `json:"free"`
+	Held      int `json:"held"`
+	Available int `json:"available"`
+}
+
+func newAvailability(free, held int) Availability {
+	a := Availability{Free: free, Held: held, Available: free - held}
+	if a.Available < 0 {
+		a.Available = 0
+	}
+	return a
+}
+
+// Candidate is a free car of the class and the bookings on either side of
+// the period it would be assigned for.
+type Candidate struct {
+	CarID    string
+	Odometer int
+	// PrevEnd is when the car's last booking before the period ends, nil if none.
+	PrevEnd *time.Time
+	// NextStart is when its next booking after the period starts, nil if none,
+	// and NextPickupBranchID where that booking picks it up.
+	NextStart          *time.Time
+	NextPickupBranchID *string
+}
+
+// BestFit picks the car that leaves the least idle time around [start, end):
+// cars booked on both sides come first, then on one side, and between those
+// the smallest gaps win, then the lowest odometer. Fully free cars are kept
+// for rentals that need them. A car whose next booking picks it up elsewhere
+// than dropoffBranchID is skipped.
+func BestFit(cands []Candidate, start, end time.Time, dropoffBranchID *string) (string, bool) {
+	type scored struct {
+		c    Candidate
+		open int
+		idle time.Duration
+	}
+	var fits []scored
+	for _, c := range cands {
+		if c.NextPickupBranchID != nil && dropoffBranchID != nil && *c.NextPickupBranchID != *dropoffBranchID {
+			continue
+		}
+		s := scored{c: c}
+		if c.PrevEnd == nil {
+			s.open++
+		} else {
+			s.idle += start.Sub(*c.PrevEnd)
+		}
+		if c.NextStart == nil {
+			s.open++
+		} else {
+			s.idle += c.NextStart.Sub(end)
+		}
+		fits = append(fits, s)
+	}
+	if len(fits) == 0 {
+		return "", false
+	}
+
+	sort.Slice(fits, func(i, j int) bool {
+		a, b := fits[i], fits[j]
+		if a.open != b.open {
+			return a.open < b.open
+		}
+		if a.idle != b.idle {
+			return a.idle < b.idle
+		}
+		if a.c.Odometer != b.c.Odometer {
+			return a.c.Odometer < b.c.Odometer
+		}
+		return a.c.CarID < b.c.CarID
+	})
+	return fits[0].c.CarID, true
+}
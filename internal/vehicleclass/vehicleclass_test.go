@@ -0,0 +1,94 @@
+package vehicleclass
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBestFit(t *testing.T) {
+	start := time.Date(2025, 6, 10, 10, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 3)
+	at := func(d time.Duration) *time.Time { t := start.Add(d); return &t }
+	after := func(d time.Duration) *time.Time { t := end.Add(d); return &t }
+	branchA, branchB := "a", "b"
+
+	cases := map[string]struct {
+		cands   []Candidate
+		dropoff *string
+		want    string
+	}{
+		"booked on both sides beats one side": {
+			cands: []Candidate{
+				{CarID: "one-side", PrevEnd: at(0)},
+				{CarID: "both", PrevEnd: at(-48 * time.Hour), NextStart: after(48 * time.Hour)},
+			},
+			want: "both",
+		},
+		"fully free car is kept back": {
+			cands: []Candidate{
+				{CarID: "free"},
+				{CarID: "after-gap", NextStart: after(30 * 24 * time.Hour)},
+			},
+			want: "after-gap",
+		},
+		"smallest idle time": {
+			cands: []Candidate{
+				{CarID: "loose", PrevEnd: at(-24 * time.Hour), NextStart: after(24 * time.Hour)},
+				{CarID: "tight", PrevEnd: at(-2 * time.Hour), NextStart: after(time.Hour)},
+			},
+			want: "tight",
+		},
+		"lowest odometer breaks ties": {
+			cands: []Candidate{
+				{CarID: "worn", Odometer: 90000},
+				{CarID: "fresh", Odometer: 1200},
+			},
+			want: "fresh",
+		},
+		"next pickup elsewhere is skipped": {
+			cands: []Candidate{
+				{CarID: "needed-at-b", PrevEnd: at(0), NextStart: after(0), NextPickupBranchID: &branchB},
+				{CarID: "free"},
+			},
+			dropoff: &branchA,
+			want:    "free",
+		},
+	}
+	for name, c := range cases {
+		got, ok := BestFit(c.cands, start, end, c.dropoff)
+		if !ok || got != c.want {
+			t.Errorf("%s: got %q (%v), want %q", name, got, ok, c.want)
+		}
+	}
+
+	if _, ok := BestFit(nil, start, end, nil); ok {
+		t.Error("picked a car from no candidates")
+	}
+}
+
+func TestAvailability(t *testing.T) {
+	if a := newAvailability(3, 1); a.Available != 2 {
+		t.Errorf("3 free, 1 held: %d available", a.Available)
+	}
+	// Held bookings can outnumber free cars when cars leave the class
+	if a := newAvailability(1, 2); a.Available != 0 {
+		t.Errorf("1 free, 2 held: %d available", a.Available)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	zero := 0
+	cases := map[string]Class{
+		"name is required":                  {DailyRateCents: 4000},
+		"daily_rate_cents must be positive": {Name: "Compact"},
+		"seats must be positive":            {Name: "Compact", DailyRateCents: 4000, Seats: &zero},
+	}
+	for want, c := range cases {
+		if err := c.Validate(); err == nil || err.Error() != want {
+			t.Errorf("%+v: %v, want %q", c, err, want)
+		}
+	}
+	if err := (Class{Name: "SUV", DailyRateCents: 9000}).Validate(); err != nil {
+		t.Errorf("valid class rejected: %v", err)
+	}
+}
@@ -0,0 +1,231 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"rental-saas/internal/database"
+	"rental-saas/pkg/webhooksig"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// MaxAttempts is how often the worker tries a delivery before marking it
+	// failed. With the backoff below that spans roughly eight hours.
+	MaxAttempts = 10
+
+	// An endpoint that has failed DisableAfterFailures attempts in a row and
+	// has not succeeded for DisableAfter is disabled automatically.
+	DisableAfterFailures = 20
+	DisableAfter         = 24 * time.Hour
+
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 4 * time.Hour
+	responseSnippet = 1024
+)
+
+var ErrDeliveryNotFound = errors.New("webhook delivery not found")
+
+// Backoff is the wait before retrying after the given (1-based) failed attempt.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := baseBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if d >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return d
+}
+
+type target struct {
+	DeliveryID string
+	WebhookID  string
+	EventID    string
+	URL        string
+	Secret     string
+	// PreviousSecret is set while a rotated-out secret is still in its overlap window
+	PreviousSecret string
+	EventType      string
+	Body           []byte
+	Attempt        int
+}
+
+// Result describes one HTTP attempt. Err is set for transport errors and
+// non-2xx responses alike.
+type Result struct {
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"-"`
+	LatencyMS  int64         `json:"latency_ms"`
+	Snippet    string        `json:"response_snippet,omitempty"`
+	Err        error         `json:"-"`
+	Error      string        `json:"error,omitempty"`
+}
+
+func (r Result) OK() bool { return r.Err == nil }
+
+func newHTTPClient() *http.Client {
+	// No proxy: the dialer must see the receiver's address to vet it
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: 5 * time.Second, Control: dialControl}).DialContext
+	return &http.Client{
+		Transport: transport,
+		Timeout:   10 * time.Second,
+		// A redirect would resend the payload somewhere the tenant never configured
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func send(ctx context.Context, client *http.Client, t target) Result {
+	req, err := http.NewRequestWithContext(ctx, "POST", t.URL, bytes.NewReader(t.Body))
+	if err != nil {
+		return finish(Result{}, err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "RentalSaaS-Webhook/1.0")
+	req.Header.Set("X-Rental-Event", t.EventType)
+	req.Header.Set(webhooksig.EventIDHeader, t.EventID)
+	req.Header.Set(webhooksig.DeliveryHeader, t.DeliveryID)
+
+	// Signed fresh on every attempt; during a secret rotation both secrets sign, new one first
+	secrets := []string{t.Secret}
+	if t.PreviousSecret != "" {
+		secrets = append(secrets, t.PreviousSecret)
+	}
+	req.Header.Set(webhooksig.SignatureHeader, webhooksig.Header(time.Now(), t.DeliveryID, t.Body, secrets...))
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	res := Result{Latency: time.Since(start)}
+	if err != nil {
+		return finish(res, err)
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, responseSnippet))
+	res.Snippet = cleanSnippet(snippet)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return finish(res, fmt.Errorf("receiver responded %d", resp.StatusCode))
+	}
+	return finish(res, nil)
+}
+
+func finish(r Result, err error) Result {
+	r.Err = err
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.LatencyMS = r.Latency.Milliseconds()
+	return r
+}
+
+// cleanSnippet makes a response body safe for a TEXT column.
+func cleanSnippet(b []byte) string {
+	s := strings.ToValidUTF8(string(b), string(utf8.RuneError))
+	return strings.ReplaceAll(s, "\x00", "")
+}
+
+// record stores the attempt and moves the delivery and its endpoint forward.
+// Manual redeliveries never reschedule or give up on a delivery. Test events
+// say nothing about how real deliveries fare, so they leave the endpoint's
+// failure count, and with it the automatic disabling, alone.
+func record(ctx context.Context, tenantID string, t target, res Result, manual bool) error {
+	return database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO webhook_delivery_attempts (delivery_id, attempt, manual, status_code, latency_ms, response_snippet, error)
+			VALUES ($1, $2, $3, NULLIF($4, 0), $5, NULLIF($6, ''), NULLIF($7, ''))
+		`, t.DeliveryID, t.Attempt, manual, res.StatusCode, res.LatencyMS, res.Snippet, res.Error)
+		if err != nil {
+			return err
+		}
+
+		if res.OK() {
+			_, err = tx.Exec(ctx, `
+				UPDATE webhook_deliveries SET status = 'succeeded', delivered_at = NOW(), last_error = NULL
+				WHERE id = $1
+			`, t.DeliveryID)
+			if err != nil || t.EventType == EventTest {
+				return err
+			}
+			_, err = tx.Exec(ctx, `
+				UPDATE webhooks SET consecutive_failures = 0, failing_since = NULL, last_success_at = NOW()
+				WHERE id = $1
+			`, t.WebhookID)
+			return err
+		}
+
+		if manual {
+			_, err = tx.Exec(ctx, `UPDATE webhook_deliveries SET last_error = $2 WHERE id = $1`, t.DeliveryID, res.Error)
+		} else if t.Attempt >= MaxAttempts {
+			_, err = tx.Exec(ctx, `
+				UPDATE webhook_deliveries SET status = 'failed', last_error = $2 WHERE id = $1
+			`, t.DeliveryID, res.Error)
+		} else {
+			_, err = tx.Exec(ctx, `
+				UPDATE webhook_deliveries SET last_error = $2, next_attempt_at = $3 WHERE id = $1
+			`, t.DeliveryID, res.Error, time.Now().Add(Backoff(t.Attempt)))
+		}
+		if err != nil || t.EventType == EventTest {
+			return err
+		}
+
+		_, err = tx.Exec(ctx, `
+			UPDATE webhooks
+			SET consecutive_failures = consecutive_failures + 1,
+			    failing_since = COALESCE(failing_since, NOW()),
+			    disabled_at = CASE
+			        WHEN consecutive_failures + 1 >= $2 AND COALESCE(failing_since, NOW()) <= NOW() - make_interval(secs => $3)
+			        THEN NOW() ELSE disabled_at END,
+			    disabled_reason = CASE
+			        WHEN consecutive_failures + 1 >= $2 AND COALESCE(failing_since, NOW()) <= NOW() - make_interval(secs => $3)
+			        THEN 'too many consecutive failures' ELSE disabled_reason END
+			WHERE id = $1
+		`, t.WebhookID, DisableAfterFailures, DisableAfter.Seconds())
+		return err
+	})
+}
+
+// Redeliver sends a delivery again right away, whatever its status, and logs
+// the attempt as manual. Disabled or paused endpoints are still attempted: an
+// operator pressing the button wants to see whether the receiver is back.
+func Redeliver(ctx context.Context, tenantID, deliveryID string) (Result, error) {
+	var t target
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			UPDATE webhook_deliveries d SET attempts = d.attempts + 1
+			FROM webhooks w, webhook_events e
+			WHERE d.id = $1 AND w.id = d.webhook_id AND e.id = d.event_id
+			RETURNING d.id, d.attempts, w.id, w.url, w.secret_key, `+previousSecretColumn+`, e.id, e.event_type, e.payload::text
+		`, deliveryID).Scan(&t.DeliveryID, &t.Attempt, &t.WebhookID, &t.URL, &t.Secret, &t.PreviousSecret, &t.EventID, &t.EventType, &t.Body)
+		if err == pgx.ErrNoRows {
+			return ErrDeliveryNotFound
+		}
+		return err
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := send(ctx, newHTTPClient(), t)
+	if err := record(ctx, tenantID, t, res, true); err != nil {
+		return res, err
+	}
+	return res, nil
+}
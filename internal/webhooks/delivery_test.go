@@ -0,0 +1,91 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"rental-saas/pkg/webhooksig"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		10: maxBackoff,
+		50: maxBackoff,
+	}
+	for attempt, want := range cases {
+		if got := Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempt, got, want)
+		}
+	}
+}
+
+func TestSendSignsAndRecordsResponse(t *testing.T) {
+	body := []byte(`{"event":"booking.created","data":{}}`)
+	var gotSig, gotDelivery, gotEventID string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get("X-Rental-Signature")
+		gotDelivery = r.Header.Get("X-Rental-Delivery")
+		gotEventID = r.Header.Get("X-Rental-Event-ID")
+		io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("ok\x00" + strings.Repeat("x", 2*responseSnippet)))
+	}))
+	defer srv.Close()
+
+	res := send(context.Background(), newHTTPClient(), target{
+		DeliveryID: "d1", EventID: "e1", URL: srv.URL, Secret: "s3cret", EventType: "booking.created", Body: body,
+	})
+	if !res.OK() || res.StatusCode != http.StatusAccepted {
+		t.Fatalf("expected success, got %+v", res)
+	}
+	if len(res.Snippet) > responseSnippet || strings.Contains(res.Snippet, "\x00") {
+		t.Errorf("snippet not truncated/cleaned: %d bytes", len(res.Snippet))
+	}
+
+	if err := webhooksig.Verify(gotSig, "d1", body, "s3cret", 0); err != nil {
+		t.Errorf("signature %q: %v", gotSig, err)
+	}
+	if !strings.HasPrefix(gotSig, "t=") {
+		t.Errorf("signature header has no timestamp: %q", gotSig)
+	}
+	if gotDelivery != "d1" || gotEventID != "e1" {
+		t.Errorf("delivery header = %q, event ID header = %q", gotDelivery, gotEventID)
+	}
+}
+
+func TestSendTreatsNon2xxAndRedirectsAsFailure(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusFound} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status == http.StatusFound {
+				w.Header().Set("Location", "http://169.254.169.254/")
+			}
+			w.WriteHeader(status)
+		}))
+		res := send(context.Background(), newHTTPClient(), target{URL: srv.URL, Body: []byte("{}")})
+		srv.Close()
+
+		if res.OK() || res.StatusCode != status {
+			t.Errorf("status %d: expected failure with that code, got %+v", status, res)
+		}
+	}
+}
+
+func TestSendTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	res := send(context.Background(), newHTTPClient(), target{URL: url, Body: []byte("{}")})
+	if res.OK() || res.StatusCode != 0 || res.Error == "" {
+		t.Errorf("expected transport error, got %+v", res)
+	}
+}
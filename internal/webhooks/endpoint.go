@@ -0,0 +1,228 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"rental-saas/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// EventTest is sent by the "send test event" button. It cannot be subscribed
+// to and goes only to the endpoint being tested.
+const EventTest = "webhook.test"
+
+const (
+	// DefaultSecretOverlap is how long the previous secret keeps signing
+	// deliveries after a rotation; MaxSecretOverlap bounds what can be asked for.
+	DefaultSecretOverlap = 24 * time.Hour
+	MaxSecretOverlap     = 7 * 24 * time.Hour
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	errBlockedAddress  = errors.New("webhook endpoint resolves to a private or reserved address")
+)
+
+// AllowPrivateNetworks lets endpoints live on loopback and private addresses,
+// for local development against a receiver on the same machine. Set with
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true; never in production.
+var AllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+// Ranges not covered by the net.IP predicates below.
+var reservedNets = mustParseCIDRs(
+	"0.0.0.0/8",     // "this" network
+	"100.64.0.0/10", // carrier-grade NAT
+	"192.0.0.0/24",  // IETF protocol assignments
+	"198.18.0.0/15", // benchmarking
+	"240.0.0.0/4",   // reserved, incl. broadcast
+	"64:ff9b::/96",  // NAT64, reaches IPv4 space
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = n
+	}
+	return nets
+}
+
+func blockedIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return true
+	}
+	for _, n := range reservedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateURL checks that an endpoint URL is absolute http(s) and that its host
+// only resolves to public addresses. The dialer checks again at send time, so
+// a DNS record changed after registration cannot be used to reach the inside.
+func ValidateURL(ctx context.Context, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	if AllowPrivateNetworks {
+		return nil
+	}
+
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if blockedIP(ip) {
+			return errBlockedAddress
+		}
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("cannot resolve %s", host)
+	}
+	for _, a := range addrs {
+		if blockedIP(a.IP) {
+			return errBlockedAddress
+		}
+	}
+	return nil
+}
+
+// dialControl refuses connections to blocked addresses after DNS resolution.
+func dialControl(network, address string, _ syscall.RawConn) error {
+	if AllowPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+		return errBlockedAddress
+	}
+	return nil
+}
+
+// ValidateEvents checks a subscription list against the Catalogue and returns
+// it without duplicates.
+func ValidateEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, errors.New("at least one event is required")
+	}
+	var out, unknown []string
+	seen := map[string]bool{}
+	for _, e := range events {
+		if _, ok := Lookup(e); !ok {
+			unknown = append(unknown, e)
+			continue
+		}
+		if !seen[e] {
+			seen[e] = true
+			out = append(out, e)
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown events: %s", strings.Join(unknown, ", "))
+	}
+	return out, nil
+}
+
+// SendTest sends a webhook.test event to one endpoint right away, paused or
+// not, and logs it like any delivery. A failed test is not retried.
+func SendTest(ctx context.Context, tenantID, webhookID string) (Result, error) {
+	var t target
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		var exists bool
+		err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1)", webhookID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrWebhookNotFound
+		}
+
+		eventID, err := insertEvent(ctx, tx, EventTest, map[string]string{
+			"webhook_id": webhookID,
+			"message":    "Test event sent from the dashboard.",
+		})
+		if err != nil {
+			return err
+		}
+
+		// Inserted as failed so the worker never picks it up; a successful
+		// send marks it succeeded.
+		return tx.QueryRow(ctx, `
+			WITH d AS (
+				INSERT INTO webhook_deliveries (webhook_id, event_id, status, attempts)
+				VALUES ($1, $2, 'failed', 1)
+				RETURNING id, attempts, webhook_id, event_id
+			)
+			SELECT d.id, d.attempts, w.id, w.url, w.secret_key, `+previousSecretColumn+`, e.id, e.event_type, e.payload::text
+			FROM d
+			JOIN webhooks w ON w.id = d.webhook_id
+			JOIN webhook_events e ON e.id = d.event_id
+		`, webhookID, eventID).Scan(&t.DeliveryID, &t.Attempt, &t.WebhookID, &t.URL, &t.Secret, &t.PreviousSecret, &t.EventID, &t.EventType, &t.Body)
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	res := send(ctx, newHTTPClient(), t)
+	if err := record(ctx, tenantID, t, res, true); err != nil {
+		return res, err
+	}
+	return res, nil
+}
+
+// previousSecretColumn selects the previous secret while its overlap window
+// is open, and an empty string otherwise. Queries alias webhooks as w.
+const previousSecretColumn = `CASE WHEN w.previous_secret_expires_at > NOW() THEN COALESCE(w.previous_secret_key, '') ELSE '' END`
+
+// insertEvent stores the envelope receivers get. Its id is the event ID, the
+// same for every endpoint and every retry, for receivers to dedupe on.
+func insertEvent(ctx context.Context, tx pgx.Tx, eventType string, payload interface{}) (string, error) {
+	eventID := uuid.NewString()
+	envelope := map[string]interface{}{
+		"id":        eventID,
+		"event":     eventType,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+		"data":      payload,
+	}
+	if spec, ok := Lookup(eventType); ok {
+		envelope["version"] = spec.Version
+	} else if eventType == EventTest {
+		envelope["version"] = 1
+	}
+	body, err := json.Marshal(envelope)
+	if err != nil {
+		return "", fmt.Errorf("webhooks: marshal %s payload: %w", eventType, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO webhook_events (id, event_type, payload) VALUES ($1, $2, $3)
+	`, eventID, eventType, body)
+	if err != nil {
+		return "", err
+	}
+	return eventID, nil
+}
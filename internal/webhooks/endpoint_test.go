@@ -0,0 +1,153 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"rental-saas/internal/database"
+	"rental-saas/pkg/webhooksig"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// The tests deliver to httptest servers on loopback; the ones exercising the
+// SSRF checks switch them back on with blockPrivateNetworks.
+func TestMain(m *testing.M) {
+	AllowPrivateNetworks = true
+	os.Exit(m.Run())
+}
+
+func blockPrivateNetworks(t *testing.T) {
+	AllowPrivateNetworks = false
+	t.Cleanup(func() { AllowPrivateNetworks = true })
+}
+
+func TestValidateURL(t *testing.T) {
+	blockPrivateNetworks(t)
+
+	cases := map[string]bool{
+		"https://93.184.216.34/hooks":      true,
+		"http://93.184.216.34:8080/hooks":  true,
+		"ftp://93.184.216.34/":             false,
+		"/relative/path":                   false,
+		"https://user:pw@93.184.216.34/":   false,
+		"http://127.0.0.1:8080/":           false,
+		"http://10.1.2.3/":                 false,
+		"http://172.16.0.1/":               false,
+		"http://192.168.1.1/":              false,
+		"http://169.254.169.254/latest":    false,
+		"http://100.64.0.1/":               false,
+		"http://0.0.0.0/":                  false,
+		"http://[::1]/":                    false,
+		"http://[fd00::1]/":                false,
+		"http://[::ffff:127.0.0.1]/":       false,
+		"http://[64:ff9b::a9fe:a9fe]/":     false,
+		"https://[2606:4700:4700::1111]/x": true,
+	}
+	for raw, ok := range cases {
+		err := ValidateURL(context.Background(), raw)
+		if (err == nil) != ok {
+			t.Errorf("ValidateURL(%q) = %v, want ok=%v", raw, err, ok)
+		}
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	var hit bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = true }))
+	defer srv.Close()
+	blockPrivateNetworks(t)
+
+	res := send(context.Background(), newHTTPClient(), target{URL: srv.URL, Body: []byte("{}")})
+	if res.OK() || hit || !errors.Is(res.Err, errBlockedAddress) {
+		t.Errorf("expected the dialer to refuse loopback, got hit=%v %+v", hit, res)
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	got, err := ValidateEvents([]string{EventBookingCreated, EventPaymentCaptured, EventBookingCreated})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+	if _, err := ValidateEvents(nil); err == nil {
+		t.Error("empty list accepted")
+	}
+	_, err = ValidateEvents([]string{EventBookingCreated, "booking.exploded", EventTest})
+	if err == nil || !strings.Contains(err.Error(), "booking.exploded") || !strings.Contains(err.Error(), EventTest) {
+		t.Errorf("unknown events not reported: %v", err)
+	}
+}
+
+func TestSendSignsWithBothSecretsDuringRotation(t *testing.T) {
+	body := []byte(`{"event":"booking.created"}`)
+	var gotSig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSig = r.Header.Get("X-Rental-Signature")
+	}))
+	defer srv.Close()
+
+	send(context.Background(), newHTTPClient(), target{DeliveryID: "d1", URL: srv.URL, Secret: "new", PreviousSecret: "old", Body: body})
+	for _, secret := range []string{"new", "old"} {
+		if err := webhooksig.Verify(gotSig, "d1", body, secret, 0); err != nil {
+			t.Errorf("secret %q: %v (header %q)", secret, err, gotSig)
+		}
+	}
+	if strings.Count(gotSig, "v1=") != 2 {
+		t.Errorf("expected two signatures, got %q", gotSig)
+	}
+}
+
+func TestSendTestIsLoggedAndNotRetried(t *testing.T) {
+	if err := database.Connect(); err != nil {
+		t.Skip("Skipping test: Database not available")
+	}
+	ctx := context.Background()
+	tenantID := "test_tenant"
+
+	var got map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var webhookID string
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			INSERT INTO webhooks (url, events, secret_key, active) VALUES ($1, $2, 'secret', false) RETURNING id
+		`, srv.URL, []string{EventBookingCreated}).Scan(&webhookID)
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	// Paused endpoints can still be tested
+	res, err := SendTest(ctx, tenantID, webhookID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.OK() || res.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected the 500 to be reported, got %+v", res)
+	}
+	if got["event"] != EventTest || got["id"] == nil {
+		t.Errorf("receiver got %v", got)
+	}
+
+	var status string
+	database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, "SELECT status FROM webhook_deliveries WHERE webhook_id = $1", webhookID).Scan(&status)
+	})
+	if status != "failed" {
+		t.Errorf("failed test delivery left as %q; the worker would retry it", status)
+	}
+
+	if _, err := SendTest(ctx, tenantID, "00000000-0000-0000-0000-000000000000"); err != ErrWebhookNotFound {
+		t.Errorf("unknown webhook: got %v", err)
+	}
+}
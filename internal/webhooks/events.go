@@ -0,0 +1,229 @@
+package webhooks
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Event types tenants can subscribe to. Payloads are documented in WEBHOOKS.md;
+// any change to a payload that is not purely additive bumps its Version.
+const (
+	EventBookingCreated     = "booking.created"
+	EventBookingConfirmed   = "booking.confirmed"
+	EventBookingCancelled   = "booking.cancelled"
+	EventBookingCompleted   = "booking.completed"
+	EventBookingCarAssigned = "booking.car_assigned"
+
+	EventPaymentAuthorized = "payment.authorized"
+	EventPaymentCaptured   = "payment.captured"
+	EventPaymentRefunded   = "payment.refunded"
+
+	EventRefundCreated   = "refund.created"
+	EventRefundSucceeded = "refund.succeeded"
+	EventRefundFailed    = "refund.failed"
+
+	EventCarStatusChanged = "car.status_changed"
+	EventCarServiceDue    = "car.service_due"
+
+	EventCustomerCreated = "customer.created"
+)
+
+type EventSpec struct {
+	Type        string `json:"type"`
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+}
+
+// Catalogue lists every event in the order they are documented.
+var Catalogue = []EventSpec{
+	{EventBookingCreated, 1, "A booking was made from the dashboard or the booking widget."},
+	{EventBookingConfirmed, 1, "A pending booking was confirmed after its payment was authorized."},
+	{EventBookingCancelled, 1, "A booking was cancelled before pickup, or closed as a no-show."},
+	{EventBookingCompleted, 1, "The car was returned and the final amount captured."},
+	{EventBookingCarAssigned, 1, "A car was assigned to a vehicle class booking, or the booking moved to another car."},
+	{EventPaymentAuthorized, 1, "The customer's card was authorized for the booking."},
+	{EventPaymentCaptured, 1, "The final rental amount was captured."},
+	{EventPaymentRefunded, 1, "Money was refunded to the customer."},
+	{EventRefundCreated, 1, "Staff asked Stripe to refund all or part of a captured payment."},
+	{EventRefundSucceeded, 1, "A refund reached the customer and its credit note was issued."},
+	{EventRefundFailed, 1, "Stripe could not complete a refund; the amount can be refunded again."},
+	{EventCarStatusChanged, 1, "A car moved between available, rented, inspecting and maintenance."},
+	{EventCarServiceDue, 1, "A service plan became due soon or overdue."},
+	{EventCustomerCreated, 1, "A customer record was created, by staff or by a widget booking."},
+}
+
+// Lookup returns the spec for an event type.
+func Lookup(eventType string) (EventSpec, bool) {
+	for _, spec := range Catalogue {
+		if spec.Type == eventType {
+			return spec, true
+		}
+	}
+	return EventSpec{}, false
+}
+
+// BookingPayload is the data of every booking.* event.
+type BookingPayload struct {
+	ID                 string     `json:"id"`
+	Status             string     `json:"status"`
+	CarID              *string    `json:"car_id"` // nil until a class booking is assigned a car
+	ClassID            *string    `json:"class_id"`
+	CustomerID         string     `json:"customer_id"`
+	StartTime          time.Time  `json:"start_time"`
+	EndTime            time.Time  `json:"end_time"`
+	TotalAmountCents   int        `json:"total_amount_cents"`
+	DepositAmountCents int        `json:"deposit_amount_cents"`
+	PickupBranchID     *string    `json:"pickup_branch_id"`
+	DropoffBranchID    *string    `json:"dropoff_branch_id"`
+	Source             string     `json:"source,omitempty"` // booking.created only: dashboard or widget
+	CreatedAt          time.Time  `json:"created_at"`
+	ConfirmedAt        *time.Time `json:"confirmed_at"`
+	PickedUpAt         *time.Time `json:"picked_up_at"`
+	ReturnedAt         *time.Time `json:"returned_at"`
+	CancelledAt        *time.Time `json:"cancelled_at"`
+	CancellationReason *string    `json:"cancellation_reason"`
+}
+
+// PaymentPayload is the data of every payment.* event.
+type PaymentPayload struct {
+	ID                  string `json:"id"`
+	BookingID           string `json:"booking_id"`
+	Status              string `json:"status"`
+	AmountCents         int    `json:"amount_cents"`
+	Currency            string `json:"currency"`
+	RefundedAmountCents *int   `json:"refunded_amount_cents,omitempty"` // payment.refunded only
+	// OutstandingAmountCents is what a return charged beyond the card's hold
+	OutstandingAmountCents int    `json:"outstanding_amount_cents"`
+	ProviderReference      string `json:"provider_reference"` // Stripe PaymentIntent ID
+}
+
+// RefundPayload is the data of every refund.* event.
+type RefundPayload struct {
+	ID                string    `json:"id"`
+	PaymentID         string    `json:"payment_id"`
+	BookingID         string    `json:"booking_id"`
+	Status            string    `json:"status"`
+	AmountCents       int       `json:"amount_cents"`
+	Currency          string    `json:"currency"`
+	Reason            string    `json:"reason"`
+	CreditNoteID      *string   `json:"credit_note_id"`
+	ProviderReference *string   `json:"provider_reference"` // Stripe Refund ID
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type CarStatusPayload struct {
+	ID             string `json:"id"`
+	LicensePlate   string `json:"license_plate"`
+	PreviousStatus string `json:"previous_status"`
+	Status         string `json:"status"`
+	Reason         string `json:"reason"` // pickup, return, manual, maintenance
+}
+
+// ServiceDuePayload is sent once per service plan each time it comes due.
+type ServiceDuePayload struct {
+	CarID         string  `json:"car_id"`
+	LicensePlate  string  `json:"license_plate"`
+	ServicePlanID string  `json:"service_plan_id"`
+	PlanName      string  `json:"plan_name"`
+	State         string  `json:"state"` // due_soon or overdue
+	Odometer      int     `json:"odometer"`
+	DueOdometer   *int    `json:"due_odometer"`
+	DueDate       *string `json:"due_date"`
+}
+
+type CustomerPayload struct {
+	ID        string    `json:"id"`
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Phone     *string   `json:"phone"`
+	Source    string    `json:"source"` // dashboard or widget
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// EmitBooking loads the booking as the transaction sees it and enqueues the event.
+func EmitBooking(ctx context.Context, tx pgx.Tx, eventType, bookingID, source string) error {
+	var p BookingPayload
+	err := tx.QueryRow(ctx, `
+		SELECT id, status, car_id, class_id, customer_id, start_time, end_time,
+		       COALESCE(total_amount_cents, 0), COALESCE(deposit_amount_cents, 0),
+		       pickup_branch_id, dropoff_branch_id, created_at,
+		       confirmed_at, picked_up_at, returned_at, cancelled_at, NULLIF(cancellation_reason, '')
+		FROM bookings WHERE id = $1
+	`, bookingID).Scan(&p.ID, &p.Status, &p.CarID, &p.ClassID, &p.CustomerID, &p.StartTime, &p.EndTime,
+		&p.TotalAmountCents, &p.DepositAmountCents, &p.PickupBranchID, &p.DropoffBranchID, &p.CreatedAt,
+		&p.ConfirmedAt, &p.PickedUpAt, &p.ReturnedAt, &p.CancelledAt, &p.CancellationReason)
+	if err != nil {
+		return err
+	}
+	p.Source = source
+	_, err = Enqueue(ctx, tx, eventType, p)
+	return err
+}
+
+// EmitPayment enqueues a payment.* event for the payment with the given Stripe intent.
+func EmitPayment(ctx context.Context, tx pgx.Tx, eventType, stripeIntentID string) error {
+	var p PaymentPayload
+	var refunded int
+	err := tx.QueryRow(ctx, `
+		SELECT id, booking_id, status, amount_cents, currency, refunded_cents, outstanding_cents, stripe_intent_id
+		FROM payments WHERE stripe_intent_id = $1
+	`, stripeIntentID).Scan(&p.ID, &p.BookingID, &p.Status, &p.AmountCents, &p.Currency, &refunded, &p.OutstandingAmountCents, &p.ProviderReference)
+	if err != nil {
+		return err
+	}
+	if eventType == EventPaymentRefunded {
+		p.RefundedAmountCents = &refunded
+	}
+	_, err = Enqueue(ctx, tx, eventType, p)
+	return err
+}
+
+// EmitRefund loads the refund as the transaction sees it and enqueues the event.
+func EmitRefund(ctx context.Context, tx pgx.Tx, eventType, refundID string) error {
+	var p RefundPayload
+	err := tx.QueryRow(ctx, `
+		SELECT r.id, r.payment_id, p.booking_id, r.status, r.amount_cents, p.currency, r.reason,
+		       r.credit_note_id, r.stripe_refund_id, r.created_at
+		FROM refunds r JOIN payments p ON p.id = r.payment_id
+		WHERE r.id = $1
+	`, refundID).Scan(&p.ID, &p.PaymentID, &p.BookingID, &p.Status, &p.AmountCents, &p.Currency, &p.Reason,
+		&p.CreditNoteID, &p.ProviderReference, &p.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = Enqueue(ctx, tx, eventType, p)
+	return err
+}
+
+// EmitCarStatusChanged enqueues car.status_changed; it is a no-op when the status did not change.
+func EmitCarStatusChanged(ctx context.Context, tx pgx.Tx, carID, previous, current, reason string) error {
+	if previous == current {
+		return nil
+	}
+	p := CarStatusPayload{ID: carID, PreviousStatus: previous, Status: current, Reason: reason}
+	if err := tx.QueryRow(ctx, "SELECT license_plate FROM cars WHERE id = $1", carID).Scan(&p.LicensePlate); err != nil {
+		return err
+	}
+	_, err := Enqueue(ctx, tx, EventCarStatusChanged, p)
+	return err
+}
+
+func EmitServiceDue(ctx context.Context, tx pgx.Tx, p ServiceDuePayload) error {
+	_, err := Enqueue(ctx, tx, EventCarServiceDue, p)
+	return err
+}
+
+func EmitCustomerCreated(ctx context.Context, tx pgx.Tx, customerID, source string) error {
+	p := CustomerPayload{Source: source}
+	err := tx.QueryRow(ctx, `
+		SELECT id, first_name, last_name, email, phone, created_at FROM customers WHERE id = $1
+	`, customerID).Scan(&p.ID, &p.FirstName, &p.LastName, &p.Email, &p.Phone, &p.CreatedAt)
+	if err != nil {
+		return err
+	}
+	_, err = Enqueue(ctx, tx, EventCustomerCreated, p)
+	return err
+}
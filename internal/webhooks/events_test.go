@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalogueIsDocumented(t *testing.T) {
+	doc, err := os.ReadFile("../../WEBHOOKS.md")
+	if err != nil {
+		t.Fatalf("read WEBHOOKS.md: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for _, spec := range Catalogue {
+		if seen[spec.Type] {
+			t.Errorf("%s listed twice", spec.Type)
+		}
+		seen[spec.Type] = true
+		if spec.Version < 1 {
+			t.Errorf("%s: version %d", spec.Type, spec.Version)
+		}
+		if !strings.Contains(string(doc), "`"+spec.Type+"`") {
+			t.Errorf("%s is not documented in WEBHOOKS.md", spec.Type)
+		}
+		if got, ok := Lookup(spec.Type); !ok || got != spec {
+			t.Errorf("Lookup(%q) = %v, %v", spec.Type, got, ok)
+		}
+	}
+	if _, ok := Lookup("booking.exploded"); ok {
+		t.Error("Lookup found an unknown event")
+	}
+}
+
+// Every payload field must appear in the documented example, so a field
+// added here without a doc update fails the build.
+func TestPayloadFieldsAreDocumented(t *testing.T) {
+	doc, err := os.ReadFile("../../WEBHOOKS.md")
+	if err != nil {
+		t.Fatalf("read WEBHOOKS.md: %v", err)
+	}
+
+	for _, payload := range []interface{}{BookingPayload{}, PaymentPayload{}, RefundPayload{}, CarStatusPayload{}, ServiceDuePayload{}, CustomerPayload{}} {
+		typ := reflect.TypeOf(payload)
+		for i := 0; i < typ.NumField(); i++ {
+			name := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+			if !strings.Contains(string(doc), `"`+name+`":`) {
+				t.Errorf("%s.%s (%q) is not in WEBHOOKS.md", typ.Name(), typ.Field(i).Name, name)
+			}
+		}
+	}
+}
+
+func TestBookingPayloadOmitsSourceUnlessCreated(t *testing.T) {
+	b, err := json.Marshal(BookingPayload{ID: "b1", Status: "confirmed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	json.Unmarshal(b, &m)
+	if _, ok := m["source"]; ok {
+		t.Errorf("source present without a value: %s", b)
+	}
+	if v, ok := m["confirmed_at"]; !ok || v != nil {
+		t.Errorf("confirmed_at should be an explicit null: %s", b)
+	}
+}
@@ -0,0 +1,160 @@
+package webhooks
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"rental-saas/internal/database"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Worker drains the webhook outbox of every tenant. Several API instances may
+// run one each: deliveries are claimed with SKIP LOCKED and a lease, so a
+// delivery held by a crashed worker is picked up again once the lease expires.
+type Worker struct {
+	DB          *pgxpool.Pool
+	Client      *http.Client
+	Interval    time.Duration
+	BatchSize   int
+	Concurrency int
+	Lease       time.Duration
+}
+
+func NewWorker(db *pgxpool.Pool) *Worker {
+	return &Worker{
+		DB:          db,
+		Client:      newHTTPClient(),
+		Interval:    5 * time.Second,
+		BatchSize:   50,
+		Concurrency: 8,
+		Lease:       2 * time.Minute,
+	}
+}
+
+// Run polls until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if n, err := w.RunOnce(ctx); err != nil {
+			log.Printf("webhooks: worker: %v\n", err)
+		} else if n > 0 {
+			log.Printf("webhooks: worker attempted %d deliveries\n", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce attempts every due delivery of every tenant (one batch each) and
+// returns how many were attempted.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	// The public schema doubles as the tenant for localhost in development
+	rows, err := w.DB.Query(ctx, `
+		SELECT schema_name FROM public.tenants
+		UNION
+		SELECT 'public' WHERE to_regclass('public.webhook_deliveries') IS NOT NULL
+		ORDER BY 1
+	`)
+	if err != nil {
+		return 0, err
+	}
+	var schemas []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		schemas = append(schemas, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, schema := range schemas {
+		n, err := w.ProcessTenant(ctx, schema)
+		total += n
+		if err != nil {
+			log.Printf("webhooks: tenant %s: %v\n", schema, err)
+		}
+	}
+	return total, nil
+}
+
+// ProcessTenant claims one batch of due deliveries in the schema and sends them.
+func (w *Worker) ProcessTenant(ctx context.Context, tenantID string) (int, error) {
+	targets, err := w.claim(ctx, tenantID)
+	if err != nil || len(targets) == 0 {
+		return 0, err
+	}
+
+	sem := make(chan struct{}, max(w.Concurrency, 1))
+	var wg sync.WaitGroup
+	for _, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			res := send(ctx, w.Client, t)
+			// Record even if ctx was cancelled mid-send; the lease covers a lost write
+			if err := record(context.WithoutCancel(ctx), tenantID, t, res, false); err != nil {
+				log.Printf("webhooks: tenant %s: record delivery %s: %v\n", tenantID, t.DeliveryID, err)
+			}
+		}(t)
+	}
+	wg.Wait()
+	return len(targets), nil
+}
+
+func (w *Worker) claim(ctx context.Context, tenantID string) ([]target, error) {
+	var targets []target
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		rows, err := tx.Query(ctx, `
+			WITH due AS (
+				SELECT d.id FROM webhook_deliveries d
+				JOIN webhooks w ON w.id = d.webhook_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW()
+				AND w.active = true AND w.disabled_at IS NULL
+				ORDER BY d.next_attempt_at
+				LIMIT $1
+				FOR UPDATE OF d SKIP LOCKED
+			), claimed AS (
+				UPDATE webhook_deliveries d
+				SET attempts = d.attempts + 1, next_attempt_at = NOW() + make_interval(secs => $2)
+				FROM due WHERE d.id = due.id
+				RETURNING d.id, d.attempts, d.webhook_id, d.event_id
+			)
+			SELECT c.id, c.attempts, w.id, w.url, w.secret_key, `+previousSecretColumn+`, e.id, e.event_type, e.payload::text
+			FROM claimed c
+			JOIN webhooks w ON w.id = c.webhook_id
+			JOIN webhook_events e ON e.id = c.event_id
+		`, w.BatchSize, w.Lease.Seconds())
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var t target
+			if err := rows.Scan(&t.DeliveryID, &t.Attempt, &t.WebhookID, &t.URL, &t.Secret, &t.PreviousSecret, &t.EventID, &t.EventType, &t.Body); err != nil {
+				return err
+			}
+			targets = append(targets, t)
+		}
+		return rows.Err()
+	})
+	return targets, err
+}
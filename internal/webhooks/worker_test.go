@@ -0,0 +1,96 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"rental-saas/internal/database"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func TestOutboxRetriesUntilDelivered(t *testing.T) {
+	if err := database.Connect(); err != nil {
+		t.Skip("Skipping test: Database not available")
+	}
+	ctx := context.Background()
+	tenantID := "test_tenant"
+
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	eventType := fmt.Sprintf("test.outbox_%d", time.Now().UnixNano())
+	var webhookID, eventID string
+	err := database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, `
+			INSERT INTO webhooks (url, events, secret_key) VALUES ($1, $2, 'secret') RETURNING id
+		`, srv.URL, []string{eventType}).Scan(&webhookID)
+		if err != nil {
+			return err
+		}
+		eventID, err = Enqueue(ctx, tx, eventType, map[string]string{"hello": "world"})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+
+	w := NewWorker(database.DB)
+	w.Client = srv.Client()
+	deliveryStatus := func() (status string, attempts int) {
+		database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+			return tx.QueryRow(ctx, `
+				SELECT status, attempts FROM webhook_deliveries WHERE webhook_id = $1 AND event_id = $2
+			`, webhookID, eventID).Scan(&status, &attempts)
+		})
+		return
+	}
+
+	// First attempt fails and is rescheduled into the future
+	if _, err := w.ProcessTenant(ctx, tenantID); err != nil {
+		t.Fatal(err)
+	}
+	if status, attempts := deliveryStatus(); status != "pending" || attempts != 1 {
+		t.Fatalf("after failure: status=%s attempts=%d", status, attempts)
+	}
+
+	// Not due yet: nothing is sent
+	w.ProcessTenant(ctx, tenantID)
+	if calls.Load() != 1 {
+		t.Fatalf("retried before backoff elapsed: %d calls", calls.Load())
+	}
+
+	// Pull the retry forward and let it succeed
+	database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, `UPDATE webhook_deliveries SET next_attempt_at = NOW() WHERE webhook_id = $1`, webhookID)
+		return err
+	})
+	w.ProcessTenant(ctx, tenantID)
+	if status, attempts := deliveryStatus(); status != "succeeded" || attempts != 2 {
+		t.Fatalf("after retry: status=%s attempts=%d", status, attempts)
+	}
+
+	var logged int
+	database.RunInTenantScope(ctx, tenantID, func(tx pgx.Tx) error {
+		return tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM webhook_delivery_attempts a
+			JOIN webhook_deliveries d ON d.id = a.delivery_id
+			WHERE d.webhook_id = $1
+		`, webhookID).Scan(&logged)
+	})
+	if logged != 2 {
+		t.Errorf("expected 2 logged attempts, got %d", logged)
+	}
+}
@@ -0,0 +1,11 @@
+// Package migrations embeds the SQL schema files so they ship inside the binary.
+//
+// public/ holds migrations for the shared public schema (tenant registry,
+// platform users). tenant/ holds migrations that are applied inside every
+// tenant schema.
+package migrations
+
+import "embed"
+
+//go:embed public/*.sql tenant/*.sql
+var FS embed.FS
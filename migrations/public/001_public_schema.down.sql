@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS users_global;
+DROP TABLE IF EXISTS tenants;
@@ -0,0 +1,4 @@
+-- btree_gist lets tenant exclusion constraints mix uuid equality with range overlap.
+-- Extensions are database-wide, so this lives in the public scope and runs before
+-- the tenant migrations that rely on it.
+CREATE EXTENSION IF NOT EXISTS btree_gist;
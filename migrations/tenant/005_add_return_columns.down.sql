@@ -0,0 +1,5 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS damage_cost_cents;
+ALTER TABLE bookings DROP COLUMN IF EXISTS final_odometer;
+
+ALTER TABLE cars DROP COLUMN IF EXISTS odometer;
+ALTER TABLE cars DROP COLUMN IF EXISTS daily_rate_cents;
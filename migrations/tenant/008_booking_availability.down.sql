@@ -0,0 +1,5 @@
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_no_overlap;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_valid_range;
+
+ALTER TABLE bookings ALTER COLUMN deposit_amount_cents DROP DEFAULT;
+ALTER TABLE bookings ALTER COLUMN total_amount_cents DROP DEFAULT;
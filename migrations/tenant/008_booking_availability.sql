@@ -0,0 +1,17 @@
+-- Availability is derived from overlapping booking ranges instead of cars.status,
+-- so a car can carry any number of future bookings. Ranges are half-open
+-- ([start, end)), which lets one rental end exactly when the next begins.
+
+-- The handlers have always written cars.year and created bookings before a
+-- price is known; bring the schema in line with that.
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS year INTEGER;
+ALTER TABLE bookings ALTER COLUMN total_amount_cents SET DEFAULT 0;
+ALTER TABLE bookings ALTER COLUMN deposit_amount_cents SET DEFAULT 0;
+
+ALTER TABLE bookings ADD CONSTRAINT bookings_valid_range CHECK (end_time > start_time);
+
+-- Only bookings that still hold the car take part; cancelled and completed
+-- bookings free their slot.
+ALTER TABLE bookings ADD CONSTRAINT bookings_no_overlap
+    EXCLUDE USING gist (car_id WITH =, tstzrange(start_time, end_time, '[)') WITH &&)
+    WHERE (status IN ('pending', 'confirmed', 'active'));
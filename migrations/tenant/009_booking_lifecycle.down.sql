@@ -0,0 +1,10 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS cancellation_reason;
+ALTER TABLE bookings DROP COLUMN IF EXISTS cancelled_at;
+ALTER TABLE bookings DROP COLUMN IF EXISTS returned_at;
+ALTER TABLE bookings DROP COLUMN IF EXISTS picked_up_at;
+ALTER TABLE bookings DROP COLUMN IF EXISTS confirmed_at;
+
+UPDATE bookings SET status = 'cancelled' WHERE status = 'no_show';
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_status_check;
+ALTER TABLE bookings ADD CONSTRAINT bookings_status_check
+    CHECK (status IN ('pending', 'confirmed', 'active', 'completed', 'cancelled'));
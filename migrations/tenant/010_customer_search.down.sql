@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS bookings_customer_idx;
+DROP INDEX IF EXISTS customers_driver_license_idx;
+DROP INDEX IF EXISTS customers_phone_idx;
+DROP INDEX IF EXISTS customers_name_idx;
+
+ALTER TABLE customers DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,7 @@
+-- Staff search customers by name, email, phone or licence.
+ALTER TABLE customers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
+
+CREATE INDEX IF NOT EXISTS customers_name_idx ON customers (lower(last_name), lower(first_name));
+CREATE INDEX IF NOT EXISTS customers_phone_idx ON customers (phone);
+CREATE INDEX IF NOT EXISTS customers_driver_license_idx ON customers (lower(driver_license_id));
+CREATE INDEX IF NOT EXISTS bookings_customer_idx ON bookings (customer_id);
@@ -0,0 +1,7 @@
+-- Role-based access control: owner, manager, agent, read_only.
+-- Legacy 'admin' users become owners, anything else (the old 'staff' default) agents.
+UPDATE users SET role = 'owner' WHERE role = 'admin';
+UPDATE users SET role = 'agent' WHERE role NOT IN ('owner', 'manager', 'agent', 'read_only');
+
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'agent';
+ALTER TABLE users ADD CONSTRAINT users_role_check CHECK (role IN ('owner', 'manager', 'agent', 'read_only'));
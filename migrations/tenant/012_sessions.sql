@@ -0,0 +1,18 @@
+-- Server-side sessions backing refresh tokens. Access tokens carry the session
+-- id (sid) and are rejected once the session is revoked or expired.
+CREATE TABLE IF NOT EXISTS sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash TEXT NOT NULL UNIQUE,
+    previous_token_hash TEXT,
+    user_agent TEXT,
+    ip_address TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    revoked_reason TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id) WHERE revoked_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_sessions_previous_token_hash ON sessions (previous_token_hash);
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS user_tokens;
+
+ALTER TABLE users DROP COLUMN IF EXISTS password_changed_at;
+ALTER TABLE users DROP COLUMN IF EXISTS invited_at;
+DELETE FROM users WHERE password_hash IS NULL;
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS webhook_delivery_attempts;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_events;
+
+ALTER TABLE webhooks DROP COLUMN IF EXISTS disabled_reason;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS disabled_at;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS last_success_at;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS failing_since;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS consecutive_failures;
@@ -0,0 +1,3 @@
+ALTER TABLE webhooks DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS previous_secret_expires_at;
+ALTER TABLE webhooks DROP COLUMN IF EXISTS previous_secret_key;
@@ -0,0 +1,7 @@
+-- Secret rotation: after a rotation the old secret keeps signing deliveries
+-- (alongside the new one) until previous_secret_expires_at, so receivers can
+-- switch over without dropping events.
+
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret_key TEXT;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS previous_secret_expires_at TIMESTAMP WITH TIME ZONE;
+ALTER TABLE webhooks ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP WITH TIME ZONE DEFAULT NOW();
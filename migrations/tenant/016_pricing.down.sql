@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS booking_charges;
+DROP TABLE IF EXISTS pricing_rules;
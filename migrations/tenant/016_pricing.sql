@@ -0,0 +1,35 @@
+-- Tenant pricing rules and the itemised charges frozen on each booking.
+-- See internal/pricing for how rules combine.
+
+CREATE TABLE IF NOT EXISTS pricing_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    kind TEXT NOT NULL CHECK (kind IN ('season', 'day_of_week', 'length_tier', 'minimum_length', 'blackout_surcharge')),
+    car_id UUID REFERENCES cars(id) ON DELETE CASCADE, -- NULL: every car
+    channel TEXT CHECK (channel IN ('dashboard', 'widget')), -- NULL: every channel
+    start_date DATE,
+    end_date DATE,
+    days_of_week INTEGER[], -- 0 = Sunday
+    min_days INTEGER,
+    percent INTEGER, -- +20 = 20% more, -10 = 10% off
+    daily_rate_cents INTEGER,
+    amount_cents INTEGER,
+    priority INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS booking_charges (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL DEFAULT 1,
+    unit_amount_cents INTEGER NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    rule_id UUID REFERENCES pricing_rules(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (booking_id, position)
+);
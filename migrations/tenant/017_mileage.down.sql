@@ -0,0 +1,6 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS overage_cents_per_km;
+ALTER TABLE bookings DROP COLUMN IF EXISTS included_km;
+ALTER TABLE bookings DROP COLUMN IF EXISTS start_odometer;
+
+ALTER TABLE cars DROP COLUMN IF EXISTS overage_cents_per_km;
+ALTER TABLE cars DROP COLUMN IF EXISTS included_km_per_day;
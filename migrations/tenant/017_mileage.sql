@@ -0,0 +1,11 @@
+-- Mileage allowance: each car has an included distance per day (NULL means
+-- unlimited) and a rate for every kilometre beyond it. Pickup copies the
+-- reading and the allowance for the booked period onto the booking, so the
+-- return is charged on the terms the car was handed over with.
+
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS included_km_per_day INTEGER CHECK (included_km_per_day >= 0);
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS overage_cents_per_km INTEGER NOT NULL DEFAULT 0 CHECK (overage_cents_per_km >= 0);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS start_odometer INTEGER;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS included_km INTEGER;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS overage_cents_per_km INTEGER;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS pricing_settings;
+
+ALTER TABLE bookings DROP COLUMN IF EXISTS final_energy_level;
+ALTER TABLE bookings DROP COLUMN IF EXISTS start_energy_level;
+
+ALTER TABLE cars DROP COLUMN IF EXISTS energy_level;
+ALTER TABLE cars DROP COLUMN IF EXISTS fuel_type;
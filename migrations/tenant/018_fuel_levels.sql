@@ -0,0 +1,19 @@
+-- Fuel and charge levels. Combustion and hybrid cars are read in eighths of a
+-- tank (0-8), electric cars as battery state of charge (0-100%). Pickup and
+-- return record the level on the booking; a car brought back lower is charged
+-- at the tenant's refuel or recharge price.
+
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS fuel_type TEXT NOT NULL DEFAULT 'petrol'
+    CHECK (fuel_type IN ('petrol', 'diesel', 'hybrid', 'electric'));
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS energy_level INTEGER CHECK (energy_level BETWEEN 0 AND 100); -- last recorded
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS start_energy_level INTEGER;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS final_energy_level INTEGER;
+
+-- One row per tenant, created on first save
+CREATE TABLE IF NOT EXISTS pricing_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    refuel_cents_per_eighth INTEGER NOT NULL DEFAULT 0 CHECK (refuel_cents_per_eighth >= 0),
+    recharge_cents_per_percent INTEGER NOT NULL DEFAULT 0 CHECK (recharge_cents_per_percent >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
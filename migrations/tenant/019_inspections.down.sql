@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS inspection_photos;
+DROP TABLE IF EXISTS damage_items;
+DROP TABLE IF EXISTS inspections;
@@ -0,0 +1,35 @@
+-- Damage inspections recorded at pickup and return. Reports are not edited
+-- after they are saved; the return report is compared with the pickup report
+-- and only damage that is new, or worse, is billed (see internal/inspection).
+
+CREATE TABLE IF NOT EXISTS inspections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    car_id UUID NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('pickup', 'return')),
+    notes TEXT NOT NULL DEFAULT '',
+    inspected_by UUID,
+    inspected_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (booking_id, kind)
+);
+
+CREATE TABLE IF NOT EXISTS damage_items (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inspection_id UUID NOT NULL REFERENCES inspections(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    location TEXT NOT NULL,
+    severity TEXT NOT NULL CHECK (severity IN ('minor', 'moderate', 'severe')),
+    description TEXT NOT NULL DEFAULT '',
+    estimated_cost_cents INTEGER NOT NULL DEFAULT 0 CHECK (estimated_cost_cents >= 0),
+    UNIQUE (inspection_id, position)
+);
+
+CREATE TABLE IF NOT EXISTS inspection_photos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    inspection_id UUID NOT NULL REFERENCES inspections(id) ON DELETE CASCADE,
+    damage_item_id UUID REFERENCES damage_items(id) ON DELETE CASCADE, -- NULL: general photo
+    url TEXT NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_inspection_photos_inspection ON inspection_photos(inspection_id);
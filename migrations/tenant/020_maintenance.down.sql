@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS work_orders;
+DROP TABLE IF EXISTS service_plans;
@@ -0,0 +1,43 @@
+-- Maintenance: service plans say how often a car is serviced, work orders
+-- record each service. A scheduled or in-progress work order holds the car for
+-- its scheduled window the way a booking does (see internal/availability).
+
+CREATE TABLE IF NOT EXISTS service_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    car_id UUID NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    interval_km INTEGER CHECK (interval_km > 0),
+    interval_months INTEGER CHECK (interval_months > 0),
+    last_service_odometer INTEGER NOT NULL DEFAULT 0,
+    last_service_date DATE NOT NULL DEFAULT CURRENT_DATE,
+    due_alerted_at TIMESTAMP WITH TIME ZONE, -- car.service_due sent; cleared by the next service
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (interval_km IS NOT NULL OR interval_months IS NOT NULL)
+);
+
+CREATE INDEX IF NOT EXISTS idx_service_plans_car ON service_plans(car_id);
+
+CREATE TABLE IF NOT EXISTS work_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    car_id UUID NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    service_plan_id UUID REFERENCES service_plans(id) ON DELETE SET NULL,
+    status TEXT NOT NULL DEFAULT 'scheduled' CHECK (status IN ('scheduled', 'in_progress', 'closed', 'cancelled')),
+    description TEXT NOT NULL,
+    vendor TEXT NOT NULL DEFAULT '',
+    cost_cents INTEGER NOT NULL DEFAULT 0 CHECK (cost_cents >= 0),
+    scheduled_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    scheduled_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE,
+    closed_at TIMESTAMP WITH TIME ZONE,
+    odometer INTEGER, -- reading when closed
+    notes TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (scheduled_end > scheduled_start)
+);
+
+CREATE INDEX IF NOT EXISTS idx_work_orders_car_window ON work_orders
+    USING gist (car_id, tstzrange(scheduled_start, scheduled_end, '[)'))
+    WHERE (status IN ('scheduled', 'in_progress'));
@@ -0,0 +1,25 @@
+-- Compliance documents: registration, insurance and inspection certificates
+-- per car. The file lives in object storage under object_name, in a directory
+-- the bucket's public policy excludes; it is only served through the API. A car whose mandatory documents of
+-- some kind have all expired cannot be booked (see internal/availability).
+
+CREATE TABLE IF NOT EXISTS car_documents (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    car_id UUID NOT NULL REFERENCES cars(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL CHECK (kind IN ('registration', 'insurance', 'inspection_certificate', 'other')),
+    title TEXT NOT NULL DEFAULT '',
+    object_name TEXT NOT NULL,
+    filename TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+    issued_on DATE,
+    expires_on DATE, -- NULL never expires
+    mandatory BOOLEAN NOT NULL DEFAULT true,
+    warned_stage TEXT, -- last expiry warning sent; cleared when expires_on changes
+    uploaded_by UUID,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (expires_on IS NULL OR issued_on IS NULL OR expires_on >= issued_on)
+);
+
+CREATE INDEX IF NOT EXISTS idx_car_documents_car ON car_documents(car_id, kind);
+CREATE INDEX IF NOT EXISTS idx_car_documents_expiry ON car_documents(expires_on) WHERE expires_on IS NOT NULL;
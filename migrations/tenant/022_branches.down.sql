@@ -0,0 +1,6 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS dropoff_branch_id;
+ALTER TABLE bookings DROP COLUMN IF EXISTS pickup_branch_id;
+DROP INDEX IF EXISTS idx_cars_branch;
+ALTER TABLE cars DROP COLUMN IF EXISTS branch_id;
+DROP TABLE IF EXISTS one_way_fees;
+DROP TABLE IF EXISTS branches;
@@ -0,0 +1,36 @@
+-- Branches: the locations a tenant rents from. A car is at one branch at a
+-- time, and a booking is picked up at one branch and dropped off at the same
+-- or another; one-way rentals are only offered between pairs with a fee
+-- (which may be zero). Columns are nullable so single-lot tenants keep working.
+
+CREATE TABLE IF NOT EXISTS branches (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL,
+    address TEXT NOT NULL DEFAULT '',
+    latitude DOUBLE PRECISION CHECK (latitude BETWEEN -90 AND 90),
+    longitude DOUBLE PRECISION CHECK (longitude BETWEEN -180 AND 180),
+    timezone TEXT NOT NULL DEFAULT 'UTC',
+    opening_hours JSONB NOT NULL DEFAULT '{}', -- weekday => {"open": "08:00", "close": "18:00"}; empty is always open
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((latitude IS NULL) = (longitude IS NULL))
+);
+
+CREATE TABLE IF NOT EXISTS one_way_fees (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    from_branch_id UUID NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    to_branch_id UUID NOT NULL REFERENCES branches(id) ON DELETE CASCADE,
+    fee_cents INTEGER NOT NULL CHECK (fee_cents >= 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    UNIQUE (from_branch_id, to_branch_id),
+    CHECK (from_branch_id <> to_branch_id)
+);
+
+-- The branch the car is at; while rented, the one it was picked up from
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS branch_id UUID REFERENCES branches(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_cars_branch ON cars(branch_id);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS pickup_branch_id UUID REFERENCES branches(id) ON DELETE SET NULL;
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS dropoff_branch_id UUID REFERENCES branches(id) ON DELETE SET NULL;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_bookings_unassigned;
+ALTER TABLE bookings DROP CONSTRAINT IF EXISTS bookings_car_or_class;
+ALTER TABLE bookings DROP COLUMN IF EXISTS class_id;
+DROP INDEX IF EXISTS idx_cars_class;
+ALTER TABLE cars DROP COLUMN IF EXISTS class_id;
+DROP TABLE IF EXISTS vehicle_classes;
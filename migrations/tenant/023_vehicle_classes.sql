@@ -0,0 +1,24 @@
+-- Vehicle classes: groups of interchangeable cars ("Compact", "SUV") with a
+-- class-level daily rate. A booking can be made against a class and get its
+-- car later, before pickup; until then it holds one unit of the class's
+-- capacity (see internal/availability and internal/vehicleclass).
+
+CREATE TABLE IF NOT EXISTS vehicle_classes (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    daily_rate_cents INTEGER NOT NULL CHECK (daily_rate_cents > 0),
+    seats INTEGER CHECK (seats > 0),
+    image_url TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE cars ADD COLUMN IF NOT EXISTS class_id UUID REFERENCES vehicle_classes(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_cars_class ON cars(class_id);
+
+-- A class booking has no car_id until one is assigned; it keeps its class_id after.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS class_id UUID REFERENCES vehicle_classes(id) ON DELETE RESTRICT;
+ALTER TABLE bookings ADD CONSTRAINT bookings_car_or_class CHECK (car_id IS NOT NULL OR class_id IS NOT NULL);
+CREATE INDEX IF NOT EXISTS idx_bookings_unassigned ON bookings(class_id, start_time) WHERE car_id IS NULL;
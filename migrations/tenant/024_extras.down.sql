@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_booking_extras_extra;
+DROP TABLE IF EXISTS booking_extras;
+DROP TABLE IF EXISTS extras;
@@ -0,0 +1,26 @@
+-- Rental extras: child seats, GPS units, extra drivers, snow chains. An extra
+-- is priced per day or once per rental. One with an inventory is held, like a
+-- car, by the blocking bookings that overlap: it runs out when their
+-- quantities reach the inventory (see internal/extras).
+
+CREATE TABLE IF NOT EXISTS extras (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name TEXT NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    price_cents INTEGER NOT NULL CHECK (price_cents >= 0),
+    price_per TEXT NOT NULL CHECK (price_per IN ('day', 'rental')),
+    inventory INTEGER CHECK (inventory >= 0), -- NULL: unlimited
+    active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The extras a booking takes; their price is frozen in booking_charges.
+CREATE TABLE IF NOT EXISTS booking_extras (
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE CASCADE,
+    extra_id UUID NOT NULL REFERENCES extras(id) ON DELETE RESTRICT,
+    quantity INTEGER NOT NULL CHECK (quantity > 0),
+    PRIMARY KEY (booking_id, extra_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_booking_extras_extra ON booking_extras(extra_id);
@@ -0,0 +1,5 @@
+ALTER TABLE payments DROP COLUMN IF EXISTS currency;
+ALTER TABLE booking_charges DROP COLUMN IF EXISTS included;
+ALTER TABLE bookings DROP COLUMN IF EXISTS tax_config;
+DROP TABLE IF EXISTS tax_rates;
+DROP TABLE IF EXISTS tax_settings;
@@ -0,0 +1,31 @@
+-- Currency and taxes. A tenant prices in one currency and charges any number
+-- of tax rates, each on some item types (rental, extra, damage) and either on
+-- top of the price or included in it. A booking keeps the configuration it
+-- was priced with in tax_config; its tax lines are booking_charges of kind
+-- 'tax', worked out again over all its charges at settlement (see internal/tax).
+
+-- One row per tenant, created on first save
+CREATE TABLE IF NOT EXISTS tax_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    currency TEXT NOT NULL DEFAULT 'usd' CHECK (currency ~ '^[a-z]{3}$'),
+    rounding TEXT NOT NULL DEFAULT 'half_up' CHECK (rounding IN ('half_up', 'half_even', 'down')),
+    round_per TEXT NOT NULL DEFAULT 'line' CHECK (round_per IN ('line', 'total')),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS tax_rates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    position INTEGER NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    basis_points INTEGER NOT NULL CHECK (basis_points BETWEEN 1 AND 10000), -- 2000 = 20%
+    inclusive BOOLEAN NOT NULL DEFAULT false,
+    item_types TEXT[] NOT NULL DEFAULT '{}' CHECK (item_types <@ ARRAY['rental', 'extra', 'damage']), -- empty: all
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS tax_config JSONB; -- NULL: priced before taxes, untaxed
+
+-- Included lines (inclusive taxes) are shown but not added to the total
+ALTER TABLE booking_charges ADD COLUMN IF NOT EXISTS included BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS currency TEXT NOT NULL DEFAULT 'usd';
@@ -0,0 +1,7 @@
+DROP TRIGGER IF EXISTS invoice_lines_immutable ON invoice_lines;
+DROP TRIGGER IF EXISTS invoices_immutable ON invoices;
+DROP FUNCTION IF EXISTS invoices_immutable();
+DROP TABLE IF EXISTS invoice_lines;
+DROP TABLE IF EXISTS invoices;
+DROP TABLE IF EXISTS invoice_counters;
+DROP TABLE IF EXISTS invoice_settings;
@@ -0,0 +1,70 @@
+-- Invoices and credit notes as immutable records. Each is numbered from a
+-- per-tenant series taken in the issuing transaction, so numbers have no
+-- gaps, and copies its lines, customer and currency when it is issued. A
+-- wrong invoice is reversed by a credit note that references it, never
+-- edited (see internal/invoice).
+
+-- One row per tenant, created on first save
+CREATE TABLE IF NOT EXISTS invoice_settings (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    prefix TEXT NOT NULL DEFAULT 'INV-',
+    credit_note_prefix TEXT NOT NULL DEFAULT 'CN-',
+    yearly_reset BOOLEAN NOT NULL DEFAULT true,
+    digits INTEGER NOT NULL DEFAULT 4 CHECK (digits BETWEEN 1 AND 10),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- The last number of each series; year is 0 for series that do not reset
+CREATE TABLE IF NOT EXISTS invoice_counters (
+    kind TEXT NOT NULL CHECK (kind IN ('invoice', 'credit_note')),
+    year INTEGER NOT NULL,
+    last_number INTEGER NOT NULL,
+    PRIMARY KEY (kind, year)
+);
+
+CREATE TABLE IF NOT EXISTS invoices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind TEXT NOT NULL CHECK (kind IN ('invoice', 'credit_note')),
+    number TEXT NOT NULL UNIQUE,
+    booking_id UUID NOT NULL REFERENCES bookings(id) ON DELETE RESTRICT,
+    credits_invoice_id UUID UNIQUE REFERENCES invoices(id) ON DELETE RESTRICT, -- credit notes only
+    customer_name TEXT NOT NULL,
+    customer_email TEXT NOT NULL,
+    vehicle TEXT NOT NULL,
+    period_start TIMESTAMP WITH TIME ZONE NOT NULL,
+    period_end TIMESTAMP WITH TIME ZONE NOT NULL,
+    currency TEXT NOT NULL,
+    subtotal_cents INTEGER NOT NULL,
+    tax_cents INTEGER NOT NULL,
+    total_cents INTEGER NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK ((kind = 'credit_note') = (credits_invoice_id IS NOT NULL))
+);
+
+CREATE INDEX IF NOT EXISTS idx_invoices_booking ON invoices(booking_id, issued_at);
+
+CREATE TABLE IF NOT EXISTS invoice_lines (
+    invoice_id UUID NOT NULL REFERENCES invoices(id) ON DELETE RESTRICT,
+    position INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    description TEXT NOT NULL,
+    quantity INTEGER NOT NULL,
+    unit_amount_cents INTEGER NOT NULL,
+    amount_cents INTEGER NOT NULL,
+    included BOOLEAN NOT NULL DEFAULT false,
+    PRIMARY KEY (invoice_id, position)
+);
+
+CREATE OR REPLACE FUNCTION invoices_immutable() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'invoices cannot be changed once issued; issue a credit note instead';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS invoices_immutable ON invoices;
+CREATE TRIGGER invoices_immutable BEFORE UPDATE OR DELETE ON invoices
+    FOR EACH ROW EXECUTE FUNCTION invoices_immutable();
+DROP TRIGGER IF EXISTS invoice_lines_immutable ON invoice_lines;
+CREATE TRIGGER invoice_lines_immutable BEFORE UPDATE OR DELETE ON invoice_lines
+    FOR EACH ROW EXECUTE FUNCTION invoices_immutable();
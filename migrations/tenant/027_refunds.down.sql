@@ -0,0 +1,26 @@
+-- Before 027 an invoice had at most one credit note. Issued documents cannot
+-- be changed or merged, so a tenant that has credited an invoice in parts
+-- cannot go back; refuse before touching anything.
+DO $$
+DECLARE
+    n INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO n FROM (
+        SELECT credits_invoice_id FROM invoices
+        WHERE credits_invoice_id IS NOT NULL
+        GROUP BY credits_invoice_id HAVING COUNT(*) > 1
+    ) credited;
+    IF n > 0 THEN
+        RAISE EXCEPTION 'cannot revert 027_refunds: % invoice(s) have more than one credit note', n;
+    END IF;
+END;
+$$;
+
+DROP INDEX IF EXISTS idx_invoices_credits;
+ALTER TABLE invoices ADD CONSTRAINT invoices_credits_invoice_id_key UNIQUE (credits_invoice_id);
+DROP TABLE IF EXISTS refunds;
+ALTER TABLE payments DROP COLUMN IF EXISTS refunded_cents;
+UPDATE payments SET status = 'captured' WHERE status IN ('partially_refunded', 'refunded');
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('pending_auth', 'authorized', 'captured', 'voided', 'failed'));
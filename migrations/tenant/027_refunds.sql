@@ -0,0 +1,30 @@
+-- Refunds of captured payments, in full or in part, made through Stripe.
+-- payments.refunded_cents is what Stripe has refunded or is refunding on
+-- the payment, including refunds made from the Stripe dashboard.
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check
+    CHECK (status IN ('pending_auth', 'authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'failed'));
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS refunded_cents INTEGER NOT NULL DEFAULT 0 CHECK (refunded_cents >= 0);
+
+CREATE TABLE IF NOT EXISTS refunds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    payment_id UUID NOT NULL REFERENCES payments(id) ON DELETE RESTRICT,
+    amount_cents INTEGER NOT NULL CHECK (amount_cents > 0),
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'succeeded', 'failed')),
+    -- Sent to Stripe, so a retried request cannot refund twice
+    idempotency_key TEXT NOT NULL UNIQUE,
+    stripe_refund_id TEXT UNIQUE,
+    -- The credit note issued once the refund succeeded
+    credit_note_id UUID REFERENCES invoices(id) ON DELETE RESTRICT,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refunds_payment ON refunds(payment_id, created_at);
+
+-- An invoice can be credited in parts, one credit note per refund
+ALTER TABLE invoices DROP CONSTRAINT IF EXISTS invoices_credits_invoice_id_key;
+CREATE INDEX IF NOT EXISTS idx_invoices_credits ON invoices(credits_invoice_id);
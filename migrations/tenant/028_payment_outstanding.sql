@@ -0,0 +1,4 @@
+-- What a return charged beyond what the card still held, and so could not
+-- be captured. It is owed by the customer and collected outside Stripe's
+-- authorisation.
+ALTER TABLE payments ADD COLUMN IF NOT EXISTS outstanding_cents INTEGER NOT NULL DEFAULT 0 CHECK (outstanding_cents >= 0);
@@ -0,0 +1,81 @@
+package webhooksig
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	signedAt := time.Unix(1735725600, 0)
+	body := []byte(`{"id":"evt_1","event":"booking.created"}`)
+	header := Header(signedAt, "dlv_1", body, "new", "old")
+
+	defer func(orig func() time.Time) { now = orig }(now)
+	now = func() time.Time { return signedAt.Add(time.Minute) }
+
+	cases := []struct {
+		name     string
+		header   string
+		delivery string
+		body     string
+		secret   string
+		want     error
+	}{
+		{"current secret", header, "dlv_1", string(body), "new", nil},
+		{"previous secret", header, "dlv_1", string(body), "old", nil},
+		{"wrong secret", header, "dlv_1", string(body), "other", ErrMismatch},
+		{"tampered body", header, "dlv_1", `{"id":"evt_2"}`, "new", ErrMismatch},
+		{"other delivery", header, "dlv_2", string(body), "new", ErrMismatch},
+		{"moved timestamp", strings.Replace(header, "t=1735725600", "t=1735725660", 1), "dlv_1", string(body), "new", ErrMismatch},
+		{"missing", "", "dlv_1", string(body), "new", ErrNoSignature},
+		{"no timestamp", strings.SplitN(header, ",", 2)[1], "dlv_1", string(body), "new", ErrInvalid},
+		{"bad hex", "t=1735725600,v1=zz", "dlv_1", string(body), "new", ErrInvalid},
+		{"unknown scheme ignored", header + ",v9=abc", "dlv_1", string(body), "new", nil},
+	}
+	for _, c := range cases {
+		if err := Verify(c.header, c.delivery, []byte(c.body), c.secret, 0); err != c.want {
+			t.Errorf("%s: got %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestVerifyTolerance(t *testing.T) {
+	signedAt := time.Unix(1735725600, 0)
+	header := Header(signedAt, "dlv_1", []byte("{}"), "s")
+
+	defer func(orig func() time.Time) { now = orig }(now)
+	for offset, want := range map[time.Duration]error{
+		4 * time.Minute:  nil,
+		-4 * time.Minute: nil, // receiver clock behind
+		6 * time.Minute:  ErrOutsideRange,
+		-6 * time.Minute: ErrOutsideRange,
+	} {
+		now = func() time.Time { return signedAt.Add(offset) }
+		if err := Verify(header, "dlv_1", []byte("{}"), "s", 0); err != want {
+			t.Errorf("offset %v: got %v, want %v", offset, err, want)
+		}
+	}
+
+	now = func() time.Time { return signedAt.Add(time.Hour) }
+	if err := Verify(header, "dlv_1", []byte("{}"), "s", 2*time.Hour); err != nil {
+		t.Errorf("custom tolerance: %v", err)
+	}
+}
+
+func TestVerifyRequestRestoresBody(t *testing.T) {
+	body := `{"event":"webhook.test"}`
+	r := httptest.NewRequest("POST", "/hooks", strings.NewReader(body))
+	r.Header.Set(DeliveryHeader, "dlv_1")
+	r.Header.Set(SignatureHeader, Header(time.Now(), "dlv_1", []byte(body), "s"))
+
+	got, err := VerifyRequest(r, "s", 0)
+	if err != nil || string(got) != body {
+		t.Fatalf("got %q, %v", got, err)
+	}
+	again := make([]byte, len(body))
+	if n, _ := r.Body.Read(again); string(again[:n]) != body {
+		t.Errorf("body not restored: %q", again[:n])
+	}
+}
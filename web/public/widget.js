@@ -25,6 +25,9 @@
         .rw-content { padding: 15px; }
         .rw-title { margin: 0 0 10px; font-size: 18px; font-weight: bold; }
         .rw-price { color: #2c7a7b; font-weight: bold; font-size: 16px; margin-bottom: 10px; }
+        .rw-quote { font-size: 14px; margin-bottom: 15px; }
+        .rw-quote-line { display: flex; justify-content: space-between; color: #4a5568; }
+        .rw-quote-total { display: flex; justify-content: space-between; font-weight: bold; border-top: 1px solid #e2e8f0; margin-top: 5px; padding-top: 5px; }
         .rw-btn { background: #3182ce; color: white; border: none; padding: 10px 15px; border-radius: 4px; cursor: pointer; width: 100%; font-size: 14px; }
         .rw-btn:hover { background: #2c5282; }
         
@@ -67,7 +70,7 @@
             const card = document.createElement('div');
             card.className = 'rw-card';
 
-            const price = new Intl.NumberFormat('en-US', { style: 'currency', currency: 'USD' }).format(car.price_cents / 100);
+            const price = formatMoney(car.price_cents);
             const image = car.image_url || 'https://via.placeholder.com/300x200?text=No+Image';
 
             card.innerHTML = `
@@ -87,6 +90,10 @@
         container.appendChild(grid);
     }
 
+    function formatMoney(cents) {
+        return new Intl.NumberFormat('en-US', { style: 'currency', currency: 'USD' }).format(cents / 100);
+    }
+
     // 5. Booking Modal
     function openBookingModal(car) {
         const overlay = document.createElement('div');
@@ -115,6 +122,7 @@
                         <label class="rw-label">End Date</label>
                         <input type="date" name="end_date" class="rw-input" min="${tomorrow}" required>
                     </div>
+                    <div class="rw-quote" id="rw-quote"></div>
                     <div class="rw-actions">
                         <button type="button" class="rw-btn rw-btn-secondary" id="rw-cancel">Cancel</button>
                         <button type="submit" class="rw-btn">Submit Request</button>
@@ -128,6 +136,46 @@
         const close = () => document.body.removeChild(overlay);
         document.getElementById('rw-cancel').addEventListener('click', close);
 
+        // Price the chosen dates with the same rules the booking will be charged with
+        const form = document.getElementById('rw-booking-form');
+        const quoteBox = document.getElementById('rw-quote');
+        const submitBtn = form.querySelector('button[type="submit"]');
+        async function updateQuote() {
+            const start = form.elements.start_date.value;
+            const end = form.elements.end_date.value;
+            quoteBox.innerHTML = '';
+            submitBtn.disabled = false;
+            if (!start || !end || end <= start) return;
+
+            try {
+                const params = new URLSearchParams({
+                    tenant_id: tenantID,
+                    car_id: car.id,
+                    start_date: new Date(start).toISOString(),
+                    end_date: new Date(end).toISOString()
+                });
+                const res = await fetch(`${API_BASE}/quote?${params}`);
+                if (!res.ok) throw new Error(await res.text());
+                const quote = (await res.json()).data;
+
+                const lines = quote.lines.map(l => `
+                    <div class="rw-quote-line">
+                        <span>${l.description}${l.quantity > 1 ? ` × ${l.quantity}` : ''}</span>
+                        <span>${formatMoney(l.amount_cents)}</span>
+                    </div>`).join('');
+                quoteBox.innerHTML = lines + `
+                    <div class="rw-quote-total"><span>Total (${quote.days} days)</span><span>${formatMoney(quote.total_cents)}</span></div>`;
+                if (quote.minimum_days && quote.days < quote.minimum_days) {
+                    quoteBox.innerHTML += `<p style="color:red">Minimum rental is ${quote.minimum_days} days.</p>`;
+                    submitBtn.disabled = true;
+                }
+            } catch (err) {
+                console.error(err);
+            }
+        }
+        form.elements.start_date.addEventListener('change', updateQuote);
+        form.elements.end_date.addEventListener('change', updateQuote);
+
         document.getElementById('rw-booking-form').addEventListener('submit', async (e) => {
             e.preventDefault();
             const formData = new FormData(e.target);
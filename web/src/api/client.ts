@@ -22,14 +22,35 @@ client.interceptors.request.use(
     (error) => Promise.reject(error)
 );
 
-// Response Interceptor: Handle 401
+// Response Interceptor: on 401 try one refresh, then fall back to the login page.
+// Concurrent 401s share a single refresh so the rotated token is only spent once.
+let refreshing: Promise<void> | null = null;
+
 client.interceptors.response.use(
     (response) => response,
-    (error) => {
+    async (error) => {
+        const original = error.config;
+        const url: string = original?.url || '';
+        const isAuthCall = url.includes('/api/auth/');
+
         if (error.response && error.response.status === 401) {
             const authStore = useAuthStore();
-            authStore.logout();
-            router.push('/login');
+            if (!isAuthCall && !original._retried && authStore.refreshToken) {
+                original._retried = true;
+                try {
+                    refreshing = refreshing || authStore.refresh();
+                    await refreshing;
+                    return client(original);
+                } catch {
+                    // fall through to sign-out
+                } finally {
+                    refreshing = null;
+                }
+            }
+            if (!url.includes('/api/auth/login')) {
+                authStore.clearAuth();
+                router.push('/login');
+            }
         }
         return Promise.reject(error);
     }
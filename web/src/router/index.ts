@@ -11,6 +11,21 @@ const router = createRouter({
             name: 'login',
             component: LoginView,
         },
+        {
+            path: '/forgot-password',
+            name: 'forgot-password',
+            component: () => import('../views/SetPasswordView.vue'),
+        },
+        {
+            path: '/reset-password',
+            name: 'reset-password',
+            component: () => import('../views/SetPasswordView.vue'),
+        },
+        {
+            path: '/accept-invite',
+            name: 'accept-invite',
+            component: () => import('../views/SetPasswordView.vue'),
+        },
         {
             path: '/fleet',
             name: 'fleet',
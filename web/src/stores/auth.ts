@@ -10,35 +10,62 @@ interface User {
 
 export const useAuthStore = defineStore('auth', () => {
   const token = ref<string | null>(localStorage.getItem('token'));
+  const refreshToken = ref<string | null>(localStorage.getItem('refresh_token'));
   const user = ref<User | null>(JSON.parse(localStorage.getItem('user') || 'null'));
 
   const isAuthenticated = computed(() => !!token.value);
 
-  function setAuth(newToken: string, newUser: User) {
+  function setAuth(newToken: string, newUser: User, newRefreshToken?: string) {
     token.value = newToken;
     user.value = newUser;
     localStorage.setItem('token', newToken);
     localStorage.setItem('user', JSON.stringify(newUser));
+    if (newRefreshToken) {
+      refreshToken.value = newRefreshToken;
+      localStorage.setItem('refresh_token', newRefreshToken);
+    }
   }
 
-  function logout() {
+  function clearAuth() {
     token.value = null;
+    refreshToken.value = null;
     user.value = null;
     localStorage.removeItem('token');
+    localStorage.removeItem('refresh_token');
     localStorage.removeItem('user');
   }
 
   async function login(email: string, password: string) {
     const response = await client.post('/api/auth/login', { email, password });
-    setAuth(response.data.token, response.data.user);
+    setAuth(response.data.token, response.data.user, response.data.refresh_token);
+  }
+
+  // Exchanges the refresh token for a new pair; the old refresh token is dead afterwards.
+  async function refresh() {
+    if (!refreshToken.value) {
+      throw new Error('No refresh token');
+    }
+    const response = await client.post('/api/auth/refresh', { refresh_token: refreshToken.value });
+    setAuth(response.data.token, response.data.user, response.data.refresh_token);
+  }
+
+  async function logout() {
+    if (token.value) {
+      // Revoke the server-side session; clear local state regardless.
+      await client.post('/api/auth/logout').catch(() => {});
+    }
+    clearAuth();
   }
 
   return {
     token,
+    refreshToken,
     user,
     isAuthenticated,
     setAuth,
+    clearAuth,
     login,
+    refresh,
     logout,
   };
 });